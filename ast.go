@@ -0,0 +1,36 @@
+package jsonpath
+
+import (
+	"github.com/davidhoo/jsonpath/ast"
+)
+
+// Parse parses a JSONPath expression and returns its syntax tree.
+// Function calls at the top level of an expression (e.g. length($.a)) and
+// unions of queries ($.a | $.b) are not queries and are rejected. Of the
+// options, only parse limits such as WithMaxPathLength have an effect.
+func Parse(path string, opts ...Option) (*ast.Query, error) {
+	var o options
+	for _, opt := range opts {
+		if opt != nil {
+			opt.apply(&o)
+		}
+	}
+	tree, err := parsePath(path, o.parse)
+	if err != nil {
+		return nil, err
+	}
+	switch q := tree.(type) {
+	case *ast.Query:
+		return q, nil
+	case *ast.Union:
+		return nil, NewError(ErrSyntax, "union of queries is not a single query", path)
+	default:
+		return nil, NewError(ErrSyntax, "top-level function call is not a query", path)
+	}
+}
+
+// AST returns the syntax tree of the compiled expression. Each call
+// returns a new tree, so callers may modify it freely.
+func (c *Compiled) AST() (*ast.Query, error) {
+	return Parse(c.path, c.opts...)
+}
@@ -0,0 +1,394 @@
+// Package ast declares the types used to represent parsed JSONPath
+// expressions (RFC 9535).
+//
+// A tree is obtained from jsonpath.Parse. Every node renders back to
+// canonical JSONPath text through its String method, so a tree may be
+// inspected, rewritten and turned into a new expression string.
+//
+// Nodes produced by the parser record the byte offset at which they start
+// in the source text. Nodes built by hand may leave the offset at zero.
+package ast
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Node is implemented by every node in the tree
+type Node interface {
+	String() string
+	Pos() int // byte offset at which the node starts, see Offset
+}
+
+// Query is a JSONPath query: a root identifier followed by segments.
+// Relative queries (those starting with @) only occur inside filters.
+type Query struct {
+	Relative bool       // true for @, false for $
+	Segments []*Segment // segments applied in order
+	Offset   int
+}
+
+// Union is the non-standard union of whole queries, $.a | $.b, whose
+// nodelists are concatenated in order
+type Union struct {
+	Queries []*Query
+	Offset  int
+}
+
+// Segment selects children (or, for a descendant segment, descendants) of
+// each input node using one or more selectors
+type Segment struct {
+	Descendant bool       // true for a descendant segment (..)
+	Selectors  []Selector // selectors whose results are concatenated
+	Offset     int
+}
+
+// Selector is implemented by all selector nodes
+type Selector interface {
+	Node
+	selectorNode()
+}
+
+// NameSelector selects an object member by name
+type NameSelector struct {
+	Name   string
+	Offset int
+}
+
+// WildcardSelector selects all children of a node
+type WildcardSelector struct {
+	Offset int
+}
+
+// IndexSelector selects an array element; negative indices count from the end
+type IndexSelector struct {
+	Index  int
+	Offset int
+}
+
+// SliceSelector selects a range of array elements. Nil bounds take their
+// defaults; a nil Step means 1.
+type SliceSelector struct {
+	Start  *int
+	End    *int
+	Step   *int
+	Offset int
+}
+
+// FilterSelector selects the children for which Expr is true
+type FilterSelector struct {
+	Expr   Expr
+	Offset int
+}
+
+// FunctionSelector is the non-standard function segment (e.g. .length()),
+// which applies a function to the current node
+type FunctionSelector struct {
+	Name   string
+	Args   []Expr
+	Offset int
+}
+
+// ParentSelector is the non-standard parent segment (^), which selects
+// the node containing the current node
+type ParentSelector struct {
+	Offset int
+}
+
+// Expr is implemented by all filter expression nodes
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// OrExpr is a logical disjunction (a || b || ...)
+type OrExpr struct {
+	Operands []Expr
+	Offset   int
+}
+
+// AndExpr is a logical conjunction (a && b && ...)
+type AndExpr struct {
+	Operands []Expr
+	Offset   int
+}
+
+// NotExpr is a logical negation (!a)
+type NotExpr struct {
+	Expr   Expr
+	Offset int
+}
+
+// ComparisonExpr compares two values with ==, !=, <, <=, > or >=, or with
+// one of the non-standard operators: =~ tests whether the string Left
+// matches the regular expression Right, in and nin test whether Left
+// is or is not an element of the array Right, contains whether Right is an
+// element of the array Left or a substring of the string Left, and
+// subsetof and anyof whether all or any elements of the array Left are
+// elements of the array Right
+type ComparisonExpr struct {
+	Left   Expr
+	Op     string
+	Right  Expr
+	Offset int
+}
+
+// ArithmeticExpr is the non-standard arithmetic operation Left Op Right,
+// where Op is +, -, *, / or %
+type ArithmeticExpr struct {
+	Left   Expr
+	Op     string
+	Right  Expr
+	Offset int
+}
+
+// FunctionCall is a function expression, e.g. length(@.name)
+type FunctionCall struct {
+	Name   string
+	Args   []Expr
+	Offset int
+}
+
+// Literal is a JSON literal: string, float64, bool or nil. Arguments of a
+// function segment may also be JSON objects and arrays.
+type Literal struct {
+	Value  interface{}
+	Offset int
+}
+
+// Parameter is a named placeholder, e.g. {maxPrice}, whose value is bound
+// when the query is executed
+type Parameter struct {
+	Name   string
+	Offset int
+}
+
+func (*NameSelector) selectorNode()     {}
+func (*WildcardSelector) selectorNode() {}
+func (*IndexSelector) selectorNode()    {}
+func (*SliceSelector) selectorNode()    {}
+func (*FilterSelector) selectorNode()   {}
+func (*FunctionSelector) selectorNode() {}
+func (*ParentSelector) selectorNode()   {}
+
+// A query used as a filter operand is an existence test or, when singular,
+// a value to compare
+func (*Query) exprNode()          {}
+func (*OrExpr) exprNode()         {}
+func (*AndExpr) exprNode()        {}
+func (*NotExpr) exprNode()        {}
+func (*ComparisonExpr) exprNode() {}
+func (*ArithmeticExpr) exprNode() {}
+func (*FunctionCall) exprNode()   {}
+func (*Literal) exprNode()        {}
+func (*Parameter) exprNode()      {}
+
+// Pos returns the byte offset at which the node starts
+func (q *Query) Pos() int            { return q.Offset }
+func (u *Union) Pos() int            { return u.Offset }
+func (s *Segment) Pos() int          { return s.Offset }
+func (s *NameSelector) Pos() int     { return s.Offset }
+func (s *WildcardSelector) Pos() int { return s.Offset }
+func (s *IndexSelector) Pos() int    { return s.Offset }
+func (s *SliceSelector) Pos() int    { return s.Offset }
+func (s *FilterSelector) Pos() int   { return s.Offset }
+func (s *FunctionSelector) Pos() int { return s.Offset }
+func (s *ParentSelector) Pos() int   { return s.Offset }
+func (e *OrExpr) Pos() int           { return e.Offset }
+func (e *AndExpr) Pos() int          { return e.Offset }
+func (e *NotExpr) Pos() int          { return e.Offset }
+func (e *ComparisonExpr) Pos() int   { return e.Offset }
+func (e *ArithmeticExpr) Pos() int   { return e.Offset }
+func (e *FunctionCall) Pos() int     { return e.Offset }
+func (e *Literal) Pos() int          { return e.Offset }
+func (e *Parameter) Pos() int        { return e.Offset }
+
+// String renders the query as canonical JSONPath text
+func (q *Query) String() string {
+	var b strings.Builder
+	if q.Relative {
+		b.WriteString("@")
+	} else {
+		b.WriteString("$")
+	}
+	for _, seg := range q.Segments {
+		b.WriteString(seg.String())
+	}
+	return b.String()
+}
+
+// String renders the union as its queries separated by " | "
+func (u *Union) String() string {
+	parts := make([]string, len(u.Queries))
+	for i, q := range u.Queries {
+		parts[i] = q.String()
+	}
+	return strings.Join(parts, " | ")
+}
+
+// String renders the segment in bracket notation
+func (s *Segment) String() string {
+	if len(s.Selectors) == 1 {
+		switch sel := s.Selectors[0].(type) {
+		case *FunctionSelector:
+			return "." + sel.String()
+		case *ParentSelector:
+			return sel.String()
+		}
+	}
+	parts := make([]string, len(s.Selectors))
+	for i, sel := range s.Selectors {
+		parts[i] = sel.String()
+	}
+	prefix := ""
+	if s.Descendant {
+		prefix = ".."
+	}
+	return prefix + "[" + strings.Join(parts, ",") + "]"
+}
+
+func (s *NameSelector) String() string { return QuoteName(s.Name) }
+
+func (s *WildcardSelector) String() string { return "*" }
+
+func (s *IndexSelector) String() string { return strconv.Itoa(s.Index) }
+
+func (s *SliceSelector) String() string {
+	var b strings.Builder
+	if s.Start != nil {
+		b.WriteString(strconv.Itoa(*s.Start))
+	}
+	b.WriteString(":")
+	if s.End != nil {
+		b.WriteString(strconv.Itoa(*s.End))
+	}
+	if s.Step != nil && *s.Step != 1 {
+		b.WriteString(":")
+		b.WriteString(strconv.Itoa(*s.Step))
+	}
+	return b.String()
+}
+
+func (s *FilterSelector) String() string { return "?" + s.Expr.String() }
+
+func (s *FunctionSelector) String() string { return callString(s.Name, s.Args) }
+
+func (s *ParentSelector) String() string { return "^" }
+
+func (e *OrExpr) String() string { return joinExprs(e.Operands, " || ", false) }
+
+func (e *AndExpr) String() string { return joinExprs(e.Operands, " && ", true) }
+
+func (e *NotExpr) String() string {
+	switch e.Expr.(type) {
+	case *Query, *FunctionCall:
+		return "!" + e.Expr.String()
+	default:
+		return "!(" + e.Expr.String() + ")"
+	}
+}
+
+func (e *ComparisonExpr) String() string {
+	return e.Left.String() + " " + e.Op + " " + e.Right.String()
+}
+
+// String renders the operation, parenthesizing operands that would
+// otherwise bind differently
+func (e *ArithmeticExpr) String() string {
+	prec := arithmeticPrecedence(e.Op)
+	left, right := e.Left.String(), e.Right.String()
+	if l, ok := e.Left.(*ArithmeticExpr); ok && arithmeticPrecedence(l.Op) < prec {
+		left = "(" + left + ")"
+	}
+	if r, ok := e.Right.(*ArithmeticExpr); ok && arithmeticPrecedence(r.Op) <= prec {
+		right = "(" + right + ")"
+	}
+	return left + " " + e.Op + " " + right
+}
+
+func arithmeticPrecedence(op string) int {
+	if op == "+" || op == "-" {
+		return 1
+	}
+	return 2
+}
+
+func (e *FunctionCall) String() string { return callString(e.Name, e.Args) }
+
+func (e *Literal) String() string {
+	switch v := e.Value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case string:
+		return QuoteName(v)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = (&Literal{Value: item}).String()
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = QuoteName(k) + ": " + (&Literal{Value: v[k]}).String()
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	default:
+		return "null"
+	}
+}
+
+func (e *Parameter) String() string { return "{" + e.Name + "}" }
+
+// QuoteName renders s as a single-quoted JSONPath string literal
+func QuoteName(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch {
+		case r == '\'':
+			b.WriteString("\\'")
+		case r == '\\':
+			b.WriteString("\\\\")
+		case r < 0x20:
+			b.WriteString("\\u00")
+			b.WriteString(strconv.FormatInt(int64(r)>>4, 16))
+			b.WriteString(strconv.FormatInt(int64(r)&0xF, 16))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+func callString(name string, args []Expr) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = arg.String()
+	}
+	return name + "(" + strings.Join(parts, ", ") + ")"
+}
+
+func joinExprs(operands []Expr, sep string, parenOr bool) string {
+	parts := make([]string, len(operands))
+	for i, op := range operands {
+		parts[i] = op.String()
+		if _, ok := op.(*OrExpr); ok && parenOr {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, sep)
+}
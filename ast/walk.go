@@ -0,0 +1,76 @@
+package ast
+
+// Visitor is called by Walk for each node. If Visit returns a non-nil
+// visitor w, Walk visits each child of node with w, followed by a call of
+// w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses a tree in depth-first order. It starts by calling
+// v.Visit(node); node must not be nil. Nodes are pointers, so a visitor
+// may rewrite the fields of the nodes it visits.
+func Walk(node Node, v Visitor) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Query:
+		for _, seg := range n.Segments {
+			Walk(seg, v)
+		}
+	case *Union:
+		for _, q := range n.Queries {
+			Walk(q, v)
+		}
+	case *Segment:
+		for _, sel := range n.Selectors {
+			Walk(sel, v)
+		}
+	case *FilterSelector:
+		Walk(n.Expr, v)
+	case *FunctionSelector:
+		walkExprs(n.Args, v)
+	case *OrExpr:
+		walkExprs(n.Operands, v)
+	case *AndExpr:
+		walkExprs(n.Operands, v)
+	case *NotExpr:
+		Walk(n.Expr, v)
+	case *ComparisonExpr:
+		Walk(n.Left, v)
+		Walk(n.Right, v)
+	case *ArithmeticExpr:
+		Walk(n.Left, v)
+		Walk(n.Right, v)
+	case *FunctionCall:
+		walkExprs(n.Args, v)
+	case *NameSelector, *WildcardSelector, *IndexSelector, *SliceSelector, *ParentSelector, *Literal, *Parameter:
+		// leaves
+	}
+
+	v.Visit(nil)
+}
+
+func walkExprs(exprs []Expr, v Visitor) {
+	for _, e := range exprs {
+		Walk(e, v)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a tree in depth-first order, calling f for each node.
+// If f returns true, Inspect visits the children of node, followed by a
+// call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(node, inspector(f))
+}
@@ -0,0 +1,124 @@
+package ast_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/davidhoo/jsonpath"
+	"github.com/davidhoo/jsonpath/ast"
+)
+
+func TestInspectCollectsNames(t *testing.T) {
+	q, err := jsonpath.Parse("$.store.book[?@.price < 10 && length(@.title) > 3].author")
+	if err != nil {
+		t.Fatalf("Parse error = %v", err)
+	}
+
+	var names []string
+	ast.Inspect(q, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.NameSelector); ok {
+			names = append(names, sel.Name)
+		}
+		return true
+	})
+
+	want := []string{"store", "book", "price", "title", "author"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+}
+
+func TestInspectFindsDescendants(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"$.a.b", false},
+		{"$..b", true},
+		{"$.a[?@..b]", true},
+		{"$.a[?count($..y) > 1]", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			q, err := jsonpath.Parse(tt.path)
+			if err != nil {
+				t.Fatalf("Parse error = %v", err)
+			}
+			found := false
+			ast.Inspect(q, func(n ast.Node) bool {
+				if seg, ok := n.(*ast.Segment); ok && seg.Descendant {
+					found = true
+				}
+				return !found
+			})
+			if found != tt.want {
+				t.Errorf("descendant found = %v, want %v", found, tt.want)
+			}
+		})
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	q, err := jsonpath.Parse("$.a[?@.b == 1].c")
+	if err != nil {
+		t.Fatalf("Parse error = %v", err)
+	}
+
+	var names []string
+	ast.Inspect(q, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.NameSelector); ok {
+			names = append(names, sel.Name)
+		}
+		_, isFilter := n.(*ast.FilterSelector)
+		return !isFilter
+	})
+
+	want := []string{"a", "c"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+}
+
+type renamer map[string]string
+
+func (r renamer) Visit(n ast.Node) ast.Visitor {
+	if sel, ok := n.(*ast.NameSelector); ok {
+		if to, exists := r[sel.Name]; exists {
+			sel.Name = to
+		}
+	}
+	return r
+}
+
+func TestWalkRewrites(t *testing.T) {
+	q, err := jsonpath.Parse("$.items[?@.cost > 5].cost")
+	if err != nil {
+		t.Fatalf("Parse error = %v", err)
+	}
+
+	ast.Walk(q, renamer{"cost": "price"})
+
+	if got, want := q.String(), "$['items'][?@['price'] > 5]['price']"; got != want {
+		t.Errorf("rewritten = %q, want %q", got, want)
+	}
+}
+
+func TestInspectUnion(t *testing.T) {
+	a, _ := jsonpath.Parse("$.a")
+	b, _ := jsonpath.Parse("$..b[0]")
+	u := &ast.Union{Queries: []*ast.Query{a, b}}
+
+	if got, want := u.String(), "$['a'] | $..['b'][0]"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	var queries int
+	ast.Inspect(u, func(n ast.Node) bool {
+		if _, ok := n.(*ast.Query); ok {
+			queries++
+		}
+		return true
+	})
+	if queries != 2 {
+		t.Errorf("visited %d queries, want 2", queries)
+	}
+}
@@ -0,0 +1,315 @@
+package jsonpath
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davidhoo/jsonpath/ast"
+)
+
+func intPtr(i int) *int { return &i }
+
+// clearOffsets zeroes the source offsets recorded in tree so that it can
+// be compared with a tree built by hand
+func clearOffsets(tree ast.Node) {
+	ast.Inspect(tree, func(n ast.Node) bool {
+		if n != nil {
+			reflect.ValueOf(n).Elem().FieldByName("Offset").SetInt(0)
+		}
+		return true
+	})
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want *ast.Query
+	}{
+		{
+			name: "root only",
+			path: "$",
+			want: &ast.Query{},
+		},
+		{
+			name: "child names and wildcard",
+			path: "$.store.book[*]",
+			want: &ast.Query{Segments: []*ast.Segment{
+				{Selectors: []ast.Selector{&ast.NameSelector{Name: "store"}}},
+				{Selectors: []ast.Selector{&ast.NameSelector{Name: "book"}}},
+				{Selectors: []ast.Selector{&ast.WildcardSelector{}}},
+			}},
+		},
+		{
+			name: "descendant name",
+			path: "$..author",
+			want: &ast.Query{Segments: []*ast.Segment{
+				{Descendant: true, Selectors: []ast.Selector{&ast.NameSelector{Name: "author"}}},
+			}},
+		},
+		{
+			name: "slice",
+			path: "$[1:]",
+			want: &ast.Query{Segments: []*ast.Segment{
+				{Selectors: []ast.Selector{&ast.SliceSelector{Start: intPtr(1)}}},
+			}},
+		},
+		{
+			name: "slice with step",
+			path: "$[::-1]",
+			want: &ast.Query{Segments: []*ast.Segment{
+				{Selectors: []ast.Selector{&ast.SliceSelector{Step: intPtr(-1)}}},
+			}},
+		},
+		{
+			name: "index union",
+			path: "$[0,-1]",
+			want: &ast.Query{Segments: []*ast.Segment{
+				{Selectors: []ast.Selector{&ast.IndexSelector{Index: 0}, &ast.IndexSelector{Index: -1}}},
+			}},
+		},
+		{
+			name: "comparison filter",
+			path: "$[?@.price < 10]",
+			want: &ast.Query{Segments: []*ast.Segment{
+				{Selectors: []ast.Selector{&ast.FilterSelector{Expr: &ast.ComparisonExpr{
+					Left: &ast.Query{Relative: true, Segments: []*ast.Segment{
+						{Selectors: []ast.Selector{&ast.NameSelector{Name: "price"}}},
+					}},
+					Op:    "<",
+					Right: &ast.Literal{Value: float64(10)},
+				}}}},
+			}},
+		},
+		{
+			name: "logical filter",
+			path: "$[?@.a && !@.b]",
+			want: &ast.Query{Segments: []*ast.Segment{
+				{Selectors: []ast.Selector{&ast.FilterSelector{Expr: &ast.AndExpr{Operands: []ast.Expr{
+					&ast.Query{Relative: true, Segments: []*ast.Segment{
+						{Selectors: []ast.Selector{&ast.NameSelector{Name: "a"}}},
+					}},
+					&ast.NotExpr{Expr: &ast.Query{Relative: true, Segments: []*ast.Segment{
+						{Selectors: []ast.Selector{&ast.NameSelector{Name: "b"}}},
+					}}},
+				}}}}},
+			}},
+		},
+		{
+			name: "function in filter",
+			path: "$[?length(@.name) > 3]",
+			want: &ast.Query{Segments: []*ast.Segment{
+				{Selectors: []ast.Selector{&ast.FilterSelector{Expr: &ast.ComparisonExpr{
+					Left: &ast.FunctionCall{Name: "length", Args: []ast.Expr{
+						&ast.Query{Relative: true, Segments: []*ast.Segment{
+							{Selectors: []ast.Selector{&ast.NameSelector{Name: "name"}}},
+						}},
+					}},
+					Op:    ">",
+					Right: &ast.Literal{Value: float64(3)},
+				}}}},
+			}},
+		},
+		{
+			name: "function segment",
+			path: "$.a.length()",
+			want: &ast.Query{Segments: []*ast.Segment{
+				{Selectors: []ast.Selector{&ast.NameSelector{Name: "a"}}},
+				{Selectors: []ast.Selector{&ast.FunctionSelector{Name: "length"}}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.path)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.path, err)
+			}
+			clearOffsets(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"store.book",
+		"$[",
+		"length($.a)",
+		"$.a | $.b",
+	}
+	for _, path := range tests {
+		t.Run(path, func(t *testing.T) {
+			if _, err := Parse(path); err == nil {
+				t.Errorf("Parse(%q) expected error", path)
+			}
+		})
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	q, err := Parse("$.a[?@.b > 1]")
+	if err != nil {
+		t.Fatalf("Parse error = %v", err)
+	}
+	var got []int
+	ast.Inspect(q, func(n ast.Node) bool {
+		if n != nil {
+			got = append(got, n.Pos())
+		}
+		return true
+	})
+	// $, .a, a, [?...], ?..., @.b > 1, @.b, .b, b, 1
+	want := []int{0, 1, 2, 3, 4, 5, 5, 6, 7, 11}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("positions = %v, want %v", got, want)
+	}
+}
+
+func TestParseErrorOffset(t *testing.T) {
+	tests := []struct {
+		path   string
+		offset int
+	}{
+		{"$.a[?@.b >]", 10},
+		{"$.a.[0]", 4},
+		{"$['a'", 1},
+		{"$.a#", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			_, err := Parse(tt.path)
+			var jpErr *Error
+			if !errors.As(err, &jpErr) {
+				t.Fatalf("Parse(%q) error = %v, want *Error", tt.path, err)
+			}
+			if jpErr.Offset != tt.offset {
+				t.Errorf("Parse(%q) offset = %d, want %d (%v)", tt.path, jpErr.Offset, tt.offset, err)
+			}
+		})
+	}
+}
+
+func TestParseString(t *testing.T) {
+	paths := []string{
+		"$.store.book[*].author",
+		"$..author",
+		"$['a','b'][0,2]",
+		"$.a[1:3]",
+		"$.a[?@.price < 10 && (@.x == 'y' || !@.z)]",
+		"$.a[?match(@.t, 'a.*')]",
+		"$.a[?!search(@.t, 'x')]",
+		"$[?@.a == $.b]",
+		"$.a.length()",
+		"$..price^",
+		"$.a[?@.price > {max}]",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			q, err := Parse(path)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", path, err)
+			}
+			want := MustCompile(path).String()
+			if got := q.String(); got != want {
+				t.Errorf("Parse(%q).String() = %q, want %q", path, got, want)
+			}
+			if _, err := Parse(q.String()); err != nil {
+				t.Errorf("Parse(%q) of rendered tree error = %v", q.String(), err)
+			}
+		})
+	}
+}
+
+func TestCompiledAST(t *testing.T) {
+	c := MustCompile("$.store.book[0]")
+	q, err := c.AST()
+	if err != nil {
+		t.Fatalf("AST() error = %v", err)
+	}
+	if got, want := q.String(), "$['store']['book'][0]"; got != want {
+		t.Errorf("AST().String() = %q, want %q", got, want)
+	}
+}
+
+// filterShape renders a filter expression with its logical structure made
+// explicit, e.g. or(and(a, b), c)
+func filterShape(e ast.Expr) string {
+	var op string
+	var operands []ast.Expr
+	switch x := e.(type) {
+	case *ast.OrExpr:
+		op, operands = "or", x.Operands
+	case *ast.AndExpr:
+		op, operands = "and", x.Operands
+	case *ast.NotExpr:
+		op, operands = "not", []ast.Expr{x.Expr}
+	default:
+		return e.String()
+	}
+	parts := make([]string, len(operands))
+	for i, operand := range operands {
+		parts[i] = filterShape(operand)
+	}
+	return op + "(" + strings.Join(parts, ", ") + ")"
+}
+
+func TestFilterGrouping(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`$[?@.a==1 && @.b==2 || @.c==3 && @.d==4]`, "or(and(@['a'] == 1, @['b'] == 2), and(@['c'] == 3, @['d'] == 4))"},
+		{`$[?(@.a==1 || @.b==2) && @.c==3]`, "and(or(@['a'] == 1, @['b'] == 2), @['c'] == 3)"},
+		{`$[?@.a==1 || @.b==2 && @.c==3]`, "or(@['a'] == 1, and(@['b'] == 2, @['c'] == 3))"},
+		{`$[?((@.a==1 || (@.b==2)) && ((@.c==3) || @.d==4))]`, "and(or(@['a'] == 1, @['b'] == 2), or(@['c'] == 3, @['d'] == 4))"},
+		{`$[?(!@.a && @.b)]`, "and(not(@['a']), @['b'])"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			q, err := Parse(tt.path)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			filter, ok := q.Segments[0].Selectors[0].(*ast.FilterSelector)
+			if !ok {
+				t.Fatalf("selector = %T, want *ast.FilterSelector", q.Segments[0].Selectors[0])
+			}
+			if got := filterShape(filter.Expr); got != tt.want {
+				t.Errorf("filter = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterNegation(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`$[?!(@.a==1 && @.b==2)]`, "not(and(@['a'] == 1, @['b'] == 2))"},
+		{`$[?!(@.a==1 || (@.b==2 && !@.c))]`, "not(or(@['a'] == 1, and(@['b'] == 2, not(@['c']))))"},
+		{`$[?!(@.a<1)]`, "not(@['a'] < 1)"},
+		{`$[?!(!@.a)]`, "not(not(@['a']))"},
+		{`$[?!(@.a) && (@.b)]`, "and(not(@['a']), @['b'])"},
+		{`$[?!match(@.s, 'x.*')]`, "not(match(@['s'], 'x.*'))"},
+		{`$[?!(@.s =~ /^x/)]`, "not(@['s'] =~ '^x')"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			q, err := Parse(tt.path)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			filter := q.Segments[0].Selectors[0].(*ast.FilterSelector)
+			if got := filterShape(filter.Expr); got != tt.want {
+				t.Errorf("filter = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
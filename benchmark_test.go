@@ -94,3 +94,71 @@ func BenchmarkQueryComplexFilter(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkCompiledExecute(b *testing.B) {
+	data := map[string]interface{}{
+		"store": map[string]interface{}{
+			"book": []interface{}{
+				map[string]interface{}{"title": "Book 1", "price": 10.99},
+				map[string]interface{}{"title": "Book 2", "price": 15.99},
+			},
+		},
+	}
+	c, err := Compile("$.store.book[?@.price > 10]")
+	if err != nil {
+		b.Fatalf("Unexpected error: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := c.Execute(data)
+		if err != nil {
+			b.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkCompiledExecuteParallel(b *testing.B) {
+	data := map[string]interface{}{
+		"store": map[string]interface{}{
+			"book": []interface{}{
+				map[string]interface{}{"title": "Book 1", "price": 10.99},
+				map[string]interface{}{"title": "Book 2", "price": 15.99},
+			},
+		},
+	}
+	c, err := Compile("$..book[?@.price > 10].title")
+	if err != nil {
+		b.Fatalf("Unexpected error: %v", err)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, err := c.Execute(data)
+			if err != nil {
+				b.Fatalf("Unexpected error: %v", err)
+			}
+		}
+	})
+}
+
+func BenchmarkCompiledExecuteAll(b *testing.B) {
+	docs := make([]interface{}, 100)
+	for i := range docs {
+		docs[i] = map[string]interface{}{
+			"id":    float64(i),
+			"items": []interface{}{map[string]interface{}{"price": float64(i)}},
+		}
+	}
+	c, err := Compile("$.items[?@.price > 50].price")
+	if err != nil {
+		b.Fatalf("Unexpected error: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, r := range c.ExecuteAll(docs) {
+			if r.Err != nil {
+				b.Fatalf("Unexpected error: %v", r.Err)
+			}
+		}
+	}
+}
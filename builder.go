@@ -0,0 +1,121 @@
+package jsonpath
+
+import (
+	"fmt"
+
+	"github.com/davidhoo/jsonpath/ast"
+)
+
+// Builder constructs a query one segment at a time. Member names are
+// quoted and escaped as needed, so keys taken from user input cannot alter
+// the structure of the query:
+//
+//	c, err := jsonpath.New().Child("store").Child("book").Slice(0, 3).Compile()
+//
+// A Builder is not safe for concurrent use.
+type Builder struct {
+	query      ast.Query
+	descendant bool
+	err        error
+}
+
+// New returns a Builder for a query starting at the root ($)
+func New() *Builder {
+	return &Builder{}
+}
+
+// Child selects the named members. Several names form a union.
+func (b *Builder) Child(names ...string) *Builder {
+	selectors := make([]ast.Selector, len(names))
+	for i, name := range names {
+		selectors[i] = &ast.NameSelector{Name: name}
+	}
+	return b.add(selectors)
+}
+
+// Index selects array elements by index; negative indices count from the
+// end. Several indices form a union.
+func (b *Builder) Index(indices ...int) *Builder {
+	selectors := make([]ast.Selector, len(indices))
+	for i, idx := range indices {
+		selectors[i] = &ast.IndexSelector{Index: idx}
+	}
+	return b.add(selectors)
+}
+
+// Slice selects array elements from start up to but excluding end
+func (b *Builder) Slice(start, end int) *Builder {
+	return b.add([]ast.Selector{&ast.SliceSelector{Start: &start, End: &end}})
+}
+
+// SliceStep selects every step-th array element from start up to but
+// excluding end
+func (b *Builder) SliceStep(start, end, step int) *Builder {
+	if step == 0 {
+		return b.fail(NewError(ErrInvalidPath, "slice step cannot be zero", ""))
+	}
+	return b.add([]ast.Selector{&ast.SliceSelector{Start: &start, End: &end, Step: &step}})
+}
+
+// Wildcard selects all children
+func (b *Builder) Wildcard() *Builder {
+	return b.add([]ast.Selector{&ast.WildcardSelector{}})
+}
+
+// Descendants makes the next segment apply to all descendants, as in
+// $..name
+func (b *Builder) Descendants() *Builder {
+	b.descendant = true
+	return b
+}
+
+// Filter selects the children for which expr is true. expr is a filter
+// expression without the leading "?", e.g. "@.price < 10". Literal values
+// taken from user input should be quoted with ast.QuoteName.
+func (b *Builder) Filter(expr string) *Builder {
+	q, err := Parse("$[?" + expr + "]")
+	if err != nil {
+		return b.fail(fmt.Errorf("invalid filter %q: %w", expr, err))
+	}
+	// expr must not close the brackets and add selectors or segments
+	if len(q.Segments) != 1 || len(q.Segments[0].Selectors) != 1 {
+		return b.fail(NewError(ErrSyntax, "filter is not a single expression", expr))
+	}
+	return b.add(q.Segments[0].Selectors)
+}
+
+// String returns the query built so far as canonical JSONPath text
+func (b *Builder) String() string {
+	return b.query.String()
+}
+
+// Compile compiles the query built so far. It reports the first error
+// encountered while building.
+func (b *Builder) Compile(opts ...Option) (*Compiled, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.descendant {
+		return nil, NewError(ErrSyntax, "descendant segment has no selector", b.String()+"..")
+	}
+	return Compile(b.String(), opts...)
+}
+
+func (b *Builder) add(selectors []ast.Selector) *Builder {
+	if b.err != nil {
+		return b
+	}
+	if len(selectors) == 0 {
+		return b.fail(NewError(ErrInvalidPath, "segment has no selectors", b.String()))
+	}
+	b.query.Segments = append(b.query.Segments, &ast.Segment{Descendant: b.descendant, Selectors: selectors})
+	b.descendant = false
+	return b
+}
+
+func (b *Builder) fail(err error) *Builder {
+	if b.err == nil {
+		b.err = err
+	}
+	return b
+}
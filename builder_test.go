@@ -0,0 +1,132 @@
+package jsonpath
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/davidhoo/jsonpath/ast"
+)
+
+func TestBuilder(t *testing.T) {
+	data := map[string]interface{}{
+		"store": map[string]interface{}{
+			"book": []interface{}{
+				map[string]interface{}{"title": "A", "price": 8.95},
+				map[string]interface{}{"title": "B", "price": 12.99},
+				map[string]interface{}{"title": "C", "price": 8.99},
+				map[string]interface{}{"title": "D", "price": 22.99},
+			},
+		},
+		"a'b": "quote",
+		"x.y": "dot",
+		"[*]": "brackets",
+	}
+
+	tests := []struct {
+		name    string
+		builder *Builder
+		want    string
+		values  []interface{}
+	}{
+		{
+			name:    "children and slice",
+			builder: New().Child("store").Child("book").Slice(0, 2).Child("title"),
+			want:    "$['store']['book'][0:2]['title']",
+			values:  []interface{}{"A", "B"},
+		},
+		{
+			name:    "filter",
+			builder: New().Child("store").Child("book").Filter("@.price < 10").Child("title"),
+			want:    "$['store']['book'][?@['price'] < 10]['title']",
+			values:  []interface{}{"A", "C"},
+		},
+		{
+			name:    "index union",
+			builder: New().Child("store").Child("book").Index(0, -1).Child("title"),
+			want:    "$['store']['book'][0,-1]['title']",
+			values:  []interface{}{"A", "D"},
+		},
+		{
+			name:    "slice with step",
+			builder: New().Child("store").Child("book").SliceStep(0, 4, 2).Child("title"),
+			want:    "$['store']['book'][0:4:2]['title']",
+			values:  []interface{}{"A", "C"},
+		},
+		{
+			name:    "descendants",
+			builder: New().Descendants().Child("title"),
+			want:    "$..['title']",
+			values:  []interface{}{"A", "B", "C", "D"},
+		},
+		{
+			name:    "wildcard",
+			builder: New().Child("store").Child("book").Wildcard().Child("price"),
+			want:    "$['store']['book'][*]['price']",
+			values:  []interface{}{8.95, 12.99, 8.99, 22.99},
+		},
+		{
+			name:    "quote in name",
+			builder: New().Child("a'b"),
+			want:    `$['a\'b']`,
+			values:  []interface{}{"quote"},
+		},
+		{
+			name:    "special characters are not syntax",
+			builder: New().Child("x.y", "[*]"),
+			want:    "$['x.y','[*]']",
+			values:  []interface{}{"dot", "brackets"},
+		},
+		{
+			name:    "user supplied filter literal",
+			builder: New().Child("store").Child("book").Filter("@.title == " + ast.QuoteName("B' || true || '")).Child("title"),
+			want:    `$['store']['book'][?@['title'] == 'B\' || true || \'']['title']`,
+			values:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.builder.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			c, err := tt.builder.Compile()
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			result, err := c.Execute(data)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			var values []interface{}
+			for _, n := range result {
+				values = append(values, n.Value)
+			}
+			if !reflect.DeepEqual(values, tt.values) {
+				t.Errorf("Execute() = %v, want %v", values, tt.values)
+			}
+		})
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *Builder
+	}{
+		{"invalid filter", New().Child("a").Filter("@.price <").Child("b")},
+		{"filter closing its brackets", New().Child("a").Filter("@.a]['x'")},
+		{"filter adding a segment", New().Child("a").Filter("@.a]..x[?@.b")},
+		{"filter adding a selector", New().Child("a").Filter("@.a, 'b'")},
+		{"filter adding a selector and brackets", New().Child("a").Filter("@.a]['x")},
+		{"zero step", New().SliceStep(0, 4, 0)},
+		{"empty union", New().Child()},
+		{"dangling descendants", New().Child("a").Descendants()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.builder.Compile(); err == nil {
+				t.Error("Compile() expected error")
+			}
+		})
+	}
+}
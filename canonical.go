@@ -0,0 +1,75 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strings"
+)
+
+// String returns the compiled expression rendered as canonical RFC 9535
+// text: member names in single-quoted bracket notation, no insignificant
+// whitespace inside selectors, and single spaces around filter operators.
+// The result parses back to an equivalent expression.
+func (c *Compiled) String() string {
+	if len(c.segments) == 1 && !strings.HasPrefix(strings.TrimSpace(c.path), "$") {
+		// Top-level function call, e.g. length($.a)
+		return c.segments[0].String()
+	}
+	if len(c.segments) == 1 {
+		if u, ok := c.segments[0].(*queryUnionSegmentV3); ok {
+			// Union of whole queries, each rendered with its own root
+			return u.String()
+		}
+	}
+	return canonicalSegments("$", c.segments)
+}
+
+// canonicalSegments renders segments after the given root identifier
+func canonicalSegments(root string, segments []segmentV3) string {
+	var b strings.Builder
+	b.WriteString(root)
+	for _, seg := range segments {
+		b.WriteString(seg.String())
+	}
+	return b.String()
+}
+
+// canonicalName renders a member name as a single-quoted string literal
+func canonicalName(name string) string {
+	return "'" + escapeNormalizedPathKey(name) + "'"
+}
+
+// canonicalSelector strips the enclosing brackets from a rendered
+// bracketed segment so that it can appear inside a union
+func canonicalSelector(seg segmentV3) string {
+	s := seg.String()
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// canonicalExpr renders a filter expression tree. Operands of && that are
+// themselves || expressions are parenthesized to preserve precedence.
+func canonicalExpr(node exprNode) string {
+	switch n := node.(type) {
+	case *andNode:
+		parts := make([]string, len(n.children))
+		for i, child := range n.children {
+			parts[i] = canonicalExpr(child)
+			if _, ok := child.(*orNode); ok {
+				parts[i] = "(" + parts[i] + ")"
+			}
+		}
+		return strings.Join(parts, " && ")
+	case *orNode:
+		parts := make([]string, len(n.children))
+		for i, child := range n.children {
+			parts[i] = canonicalExpr(child)
+		}
+		return strings.Join(parts, " || ")
+	case fmt.Stringer:
+		return n.String()
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,99 @@
+package jsonpath
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompiledString(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"$", "$"},
+		{"$.store.book[*].title", "$['store']['book'][*]['title']"},
+		{"$..author", "$..['author']"},
+		{`$["a b"]['it\'s']`, `$['a b']['it\'s']`},
+		{"$[ 0 , 1 ]", "$[0,1]"},
+		{"$[1:3]", "$[1:3]"},
+		{"$[::-1]", "$[::-1]"},
+		{"$..*", "$..[*]"},
+		{"$['a',1,2:3,*]", "$['a',1,2:3,*]"},
+		{`$.store.book[?(@.price<10 && @.category=="fiction")]`, "$['store']['book'][?@['price'] < 10 && @['category'] == 'fiction']"},
+		{"$[?@.a && (@.b || @.c)]", "$[?@['a'] && (@['b'] || @['c'])]"},
+		{"$[?@.price > {maxPrice}]", "$[?@['price'] > {maxPrice}]"},
+		{"$[?!@.isbn]", "$[?!@['isbn']]"},
+		{`$[?match(@.title, "S.*")]`, "$[?match(@['title'], 'S.*')]"},
+		{"$[?count(@..*) > 2]", "$[?count(@..[*]) > 2]"},
+		{"$[?@.a == $.b.c]", "$[?@['a'] == $['b']['c']]"},
+		{"$[?@.a.b[0] == 1.5]", "$[?@['a']['b'][0] == 1.5]"},
+		{"length($.a)", "length($['a'])"},
+		{"$.a | $.b[0]", "$['a'] | $['b'][0]"},
+		{"$..x|$[?@.y > 1].z | $", "$..['x'] | $[?@['y'] > 1]['z'] | $"},
+		{`$[?@.a in ["x", 'y']]`, "$[?@['a'] in ['x', 'y']]"},
+		{`$[?@.a not in [1, null, ["it's"], {"k": "v"}]]`, `$[?@['a'] nin [1, null, ['it\'s'], {'k': 'v'}]]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			c, err := Compile(tt.path)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			got := c.String()
+			if got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			// The canonical form must parse back to itself
+			again, err := Compile(got)
+			if err != nil {
+				t.Fatalf("Compile(String()) error = %v", err)
+			}
+			if again.String() != got {
+				t.Errorf("String() not stable: %q -> %q", got, again.String())
+			}
+		})
+	}
+}
+
+func TestCompiledStringExecutes(t *testing.T) {
+	data := map[string]interface{}{
+		"lim": 10.0,
+		"items": []interface{}{
+			map[string]interface{}{"p": 5.0, "t": "abc", "tags": []interface{}{"x", "y"}, "n": map[string]interface{}{"v": 1.0}},
+			map[string]interface{}{"p": 15.0, "t": "xyz", "tags": []interface{}{"y"}, "n": map[string]interface{}{"v": 2.0}},
+		},
+	}
+	paths := []string{
+		"$.items[?@.p < 10]",
+		"$.items[?@.p < $.lim]",
+		"$.items[?@.tags[0] == 'x']",
+		"$.items[?@.tags[-1] == 'y']",
+		"$.items[?@.n.v == 2]",
+		"$.items[?@.p == $.items[0].p]",
+		"$.items[?match(@.t, 'a.*')]",
+		"$.items[?search(@.t, 'y')]",
+		"$.items[?length(@.tags) > 1]",
+		"$.items[?count(@.tags[*]) == 2]",
+		"$.items[?@.tags]",
+		"$.items[?!@.missing]",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			want, err := Query(data, path)
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", path, err)
+			}
+			if len(want) == 0 {
+				t.Fatalf("Query(%q) returned no results", path)
+			}
+			canonical := MustCompile(path).String()
+			got, err := Query(data, canonical)
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", canonical, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Query(%q) = %v, want %v", canonical, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package jsonpath
+
+import (
+	"github.com/davidhoo/jsonpath/ast"
+)
+
+// compilePath parses path with opts and builds the segments that
+// evaluate it
+func compilePath(path string, opts parseOptions) ([]segmentV3, ast.Node, error) {
+	tree, err := parsePath(path, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	var segments []segmentV3
+	switch n := tree.(type) {
+	case *ast.Query:
+		segments, err = compileQuery(n)
+	case *ast.Union:
+		var seg segmentV3
+		seg, err = compileUnion(n)
+		segments = []segmentV3{seg}
+	case *ast.FunctionCall:
+		var seg segmentV3
+		seg, err = compileTopLevelCall(n)
+		segments = []segmentV3{seg}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return segments, tree, nil
+}
+
+// compileQuery builds the segments of a query. A descendant segment
+// becomes a recursive segment followed by its selectors.
+func compileQuery(q *ast.Query) ([]segmentV3, error) {
+	var segments []segmentV3
+	for _, seg := range q.Segments {
+		if seg.Descendant {
+			segments = append(segments, &recursiveSegmentV3{})
+		}
+		compiled, err := compileSelectors(seg.Selectors)
+		if err != nil {
+			return nil, err
+		}
+		if call, ok := compiled.(*callSegmentV3); ok && aggregateFunctions[call.name] && selectsMany(segments) {
+			compiled = &aggregateSegmentV3{call: call}
+		} else if ok && fallbackFunctions[call.name] {
+			start := memberChain(segments)
+			compiled = &fallbackSegmentV3{chain: append([]segmentV3(nil), segments[start:]...), call: call}
+			segments = segments[:start]
+		}
+		segments = append(segments, compiled)
+	}
+	return segments, nil
+}
+
+// memberChain returns the index where the trailing names and indices of
+// segments start. A name that completes a descendant segment is not part
+// of the chain.
+func memberChain(segments []segmentV3) int {
+	start := len(segments)
+	for start > 0 {
+		switch segments[start-1].(type) {
+		case *nameSegmentV3, *indexSegmentV3:
+			start--
+			continue
+		}
+		break
+	}
+	if start > 0 && start < len(segments) {
+		if _, ok := segments[start-1].(*recursiveSegmentV3); ok {
+			start++
+		}
+	}
+	return start
+}
+
+// compileUnion builds the segment for a union of whole queries
+func compileUnion(u *ast.Union) (segmentV3, error) {
+	seg := &queryUnionSegmentV3{queries: make([][]segmentV3, len(u.Queries))}
+	for i, q := range u.Queries {
+		segments, err := compileQuery(q)
+		if err != nil {
+			return nil, err
+		}
+		seg.queries[i] = segments
+	}
+	return seg, nil
+}
+
+// selectsMany reports whether segments may select more than one node from
+// a single input node. An aggregate yields a single node whatever precedes
+// it.
+func selectsMany(segments []segmentV3) bool {
+	many := false
+	for _, seg := range segments {
+		switch seg.(type) {
+		case *aggregateSegmentV3:
+			many = false
+		case *nameSegmentV3, *indexSegmentV3, *callSegmentV3, *parentSegmentV3, *fallbackSegmentV3:
+		default:
+			many = true
+		}
+	}
+	return many
+}
+
+// compileSelectors builds the segment for the selectors of one segment.
+// Unions of only names or only indices use the dedicated segment types.
+func compileSelectors(selectors []ast.Selector) (segmentV3, error) {
+	if len(selectors) == 1 {
+		return compileSelector(selectors[0])
+	}
+	var names []string
+	var indices []int
+	for _, sel := range selectors {
+		switch s := sel.(type) {
+		case *ast.NameSelector:
+			names = append(names, s.Name)
+		case *ast.IndexSelector:
+			indices = append(indices, s.Index)
+		}
+	}
+	switch len(selectors) {
+	case len(names):
+		return &multiNameSegmentV3{names: names}, nil
+	case len(indices):
+		return &multiIndexSegmentV3{indices: indices}, nil
+	}
+	union := &unionSegmentV3{selectors: make([]segmentV3, len(selectors))}
+	for i, sel := range selectors {
+		compiled, err := compileSelector(sel)
+		if err != nil {
+			return nil, err
+		}
+		union.selectors[i] = compiled
+	}
+	return union, nil
+}
+
+// compileSelector builds the segment for a single selector
+func compileSelector(sel ast.Selector) (segmentV3, error) {
+	switch s := sel.(type) {
+	case *ast.NameSelector:
+		return &nameSegmentV3{name: s.Name}, nil
+	case *ast.WildcardSelector:
+		return &wildcardSegmentV3{}, nil
+	case *ast.IndexSelector:
+		return &indexSegmentV3{index: s.Index}, nil
+	case *ast.SliceSelector:
+		seg := &sliceSegmentV3{step: 1}
+		if s.Start != nil {
+			seg.start, seg.hasStart = *s.Start, true
+		}
+		if s.End != nil {
+			seg.end, seg.hasEnd = *s.End, true
+		}
+		if s.Step != nil {
+			seg.step = *s.Step
+		}
+		return seg, nil
+	case *ast.FilterSelector:
+		expr, err := compileFilter(s.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &filterSegmentV3{expr: expr}, nil
+	case *ast.FunctionSelector:
+		args, err := segmentArgs(s.Name, s.Args)
+		if err != nil {
+			return nil, err
+		}
+		return &callSegmentV3{name: s.Name, args: args}, nil
+	case *ast.ParentSelector:
+		return &parentSegmentV3{}, nil
+	default:
+		return nil, NewError(ErrSyntax, "unsupported selector: "+sel.String(), sel.String())
+	}
+}
+
+// compileTopLevelCall builds the segment for a top-level function call.
+// Query arguments are compiled here, from the tree parsed with the
+// caller's options, and resolved at evaluation time.
+func compileTopLevelCall(call *ast.FunctionCall) (segmentV3, error) {
+	args := make([]interface{}, len(call.Args))
+	for i, arg := range call.Args {
+		switch a := arg.(type) {
+		case *ast.Query:
+			segments, err := compileQuery(a)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = queryArg(segments)
+		case *ast.Literal:
+			args[i] = a.Value
+		default:
+			return nil, NewError(ErrInvalidFunction, "unsupported argument: "+arg.String(), call.String())
+		}
+	}
+	return &functionSegmentV3{name: call.Name, args: args, call: call}, nil
+}
+
+// segmentArgs returns the arguments of a function segment: the values of
+// literals, and relative queries compiled for the function to execute
+// against the values it is given
+func segmentArgs(name string, exprs []ast.Expr) ([]interface{}, error) {
+	args := make([]interface{}, len(exprs))
+	for i, arg := range exprs {
+		switch a := arg.(type) {
+		case *ast.Literal:
+			args[i] = a.Value
+		case *ast.Query:
+			segments, err := compileQuery(a)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = queryArg(segments)
+		default:
+			return nil, NewError(ErrInvalidFunction, "unsupported argument: "+arg.String(), name)
+		}
+	}
+	return args, nil
+}
@@ -0,0 +1,169 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Compiled is a parsed JSONPath expression that can be executed many times.
+//
+// A Compiled value is immutable once created: all per-execution state lives
+// in a context allocated by Execute, so a single Compiled may be shared and
+// executed concurrently from any number of goroutines.
+type Compiled struct {
+	path     string
+	segments []segmentV3
+	opts     []Option
+	params   []string // placeholder names, sorted
+}
+
+// Compile parses a JSONPath expression. The options become the defaults for
+// every execution of the returned Compiled; parse limits such as
+// WithMaxPathLength apply to path itself.
+func Compile(path string, opts ...Option) (*Compiled, error) {
+	c := &Compiled{opts: append([]Option(nil), opts...)}
+	segments, tree, err := compilePath(path, c.resolveOptions(nil).parse)
+	if err != nil {
+		return nil, err
+	}
+	c.path = path
+	c.segments = segments
+	c.params = collectParams(tree)
+	return c, nil
+}
+
+// MustCompile is like Compile but panics if the expression cannot be parsed.
+// It simplifies safe initialization of package-level variables holding
+// compiled expressions.
+func MustCompile(path string, opts ...Option) *Compiled {
+	c, err := Compile(path, opts...)
+	if err != nil {
+		panic(`jsonpath: Compile(` + strconv.Quote(path) + `): ` + err.Error())
+	}
+	return c
+}
+
+// Execute evaluates the compiled expression against data. If data is a
+// string it is parsed as a JSON document. Options given here are applied
+// after those given to Compile.
+func (c *Compiled) Execute(data interface{}, opts ...Option) (NodeList, error) {
+	data, err := decodeDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	return c.execute(data, opts)
+}
+
+// ExecuteValue evaluates the compiled expression like Execute and returns
+// the value of the first node selected. found is false when nothing was
+// selected, as opposed to a selected JSON null.
+func (c *Compiled) ExecuteValue(data interface{}, opts ...Option) (value interface{}, found bool, err error) {
+	result, err := c.Execute(data, opts...)
+	if err != nil {
+		return nil, false, err
+	}
+	value, found = result.First()
+	return value, found, nil
+}
+
+// Result is the outcome of executing a compiled expression against one
+// document in a batch
+type Result struct {
+	Nodes NodeList
+	Err   error
+}
+
+// ExecuteAll evaluates the compiled expression against each document in
+// docs, returning one Result per document in the same order. A failure on
+// one document, such as invalid JSON or an exceeded limit, is reported in
+// its Result and does not stop the others. Options are resolved once for
+// the whole batch.
+func (c *Compiled) ExecuteAll(docs []interface{}, opts ...Option) []Result {
+	o := c.resolveOptions(opts)
+	results := make([]Result, len(docs))
+	for i, doc := range docs {
+		data, err := decodeDocument(doc)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		if err := c.checkParams(o); err != nil {
+			results[i].Err = err
+			continue
+		}
+		ctx := &evalContext{opts: o}
+		results[i].Nodes, results[i].Err = ctx.evaluate(c.segments, data)
+	}
+	return results
+}
+
+// execute evaluates the compiled expression against already decoded data
+func (c *Compiled) execute(data interface{}, opts []Option) (NodeList, error) {
+	o := c.resolveOptions(opts)
+	if err := c.checkParams(o); err != nil {
+		return nil, err
+	}
+	ctx := &evalContext{opts: o}
+	return ctx.evaluate(c.segments, data)
+}
+
+// resolveOptions applies the Compile options followed by opts
+func (c *Compiled) resolveOptions(opts []Option) options {
+	var o options
+	for _, list := range [][]Option{c.opts, opts} {
+		for _, opt := range list {
+			if opt != nil {
+				opt.apply(&o)
+			}
+		}
+	}
+	return o
+}
+
+// Path returns the expression the Compiled was created from
+func (c *Compiled) Path() string {
+	return c.path
+}
+
+// NumSegments returns the number of segments in the compiled expression,
+// counting a descendant segment ("..") as its own segment. A union of whole
+// queries, such as $.a | $.b, and a top-level function call count as a
+// single segment.
+func (c *Compiled) NumSegments() int {
+	return len(c.segments)
+}
+
+// IsSingular reports whether the expression is a singular query, built
+// only from member names and array indices, which selects at most one node
+// from any document. Expressions containing wildcards, slices, unions,
+// filters, descendant segments or functions are not singular.
+func (c *Compiled) IsSingular() bool {
+	return isSingularSegments(c.segments)
+}
+
+// isSingularSegments reports whether segments consist only of name and
+// index selectors, so that they select at most one node
+func isSingularSegments(segments []segmentV3) bool {
+	for _, seg := range segments {
+		switch seg.(type) {
+		case *indexSegmentV3, *nameSegmentV3:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// decodeDocument parses data as JSON if it is a string
+func decodeDocument(data interface{}) (interface{}, error) {
+	jsonStr, ok := data.(string)
+	if !ok {
+		return data, nil
+	}
+	var parsedData interface{}
+	if err := json.Unmarshal([]byte(jsonStr), &parsedData); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	return parsedData, nil
+}
@@ -0,0 +1,208 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	c, err := Compile("$.store.book[?@.price < 10].title")
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if c.Path() != "$.store.book[?@.price < 10].title" {
+		t.Errorf("Path() = %q", c.Path())
+	}
+
+	result, err := c.Execute(`{"store":{"book":[{"title":"A","price":8},{"title":"B","price":12}]}}`)
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if len(result) != 1 || result[0].Value != "A" {
+		t.Errorf("Execute() = %v, want [A]", result)
+	}
+
+	if _, err := Compile("$.store["); err == nil {
+		t.Error("Compile() expected error for invalid path")
+	}
+}
+
+func TestCompiledOptions(t *testing.T) {
+	data := map[string]interface{}{"Name": "x", "items": []interface{}{1.0, 2.0, 3.0}}
+
+	c, err := Compile("$.name", WithCaseInsensitiveNames())
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if result, _ := c.Execute(data); len(result) != 1 {
+		t.Errorf("Execute() with compile options = %v, want 1 node", result)
+	}
+
+	c, err = Compile("$.items[*]")
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if _, err := c.Execute(data, WithMaxResults(2)); err == nil {
+		t.Error("Execute() with per-call options expected limit error")
+	}
+	// Limits are per execution: a failed run must not affect the next one
+	if result, err := c.Execute(data); err != nil || len(result) != 3 {
+		t.Errorf("Execute() = %v, %v, want 3 nodes", result, err)
+	}
+}
+
+func TestCompiledConcurrentExecute(t *testing.T) {
+	paths := []string{
+		"$..price",
+		"$.store.book[?@.price > 10 && match(@.title, 'B.*')].title",
+		"$.store.book[*].title",
+		"$.store.*",
+		"$..book[-1:]",
+		"$.store.book[?count(@.tags[*]) > 1]",
+	}
+	var data interface{}
+	if err := json.Unmarshal([]byte(`{"store":{"book":[
+		{"title":"A","price":8,"tags":["x"]},
+		{"title":"B1","price":12,"tags":["x","y"]},
+		{"title":"B2","price":22,"tags":["y","z"]}
+	],"bicycle":{"price":19.95}}}`), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		c, err := Compile(path, WithMaxSteps(10000))
+		if err != nil {
+			t.Fatalf("Compile(%q) error = %v", path, err)
+		}
+		want, err := c.Execute(data)
+		if err != nil {
+			t.Fatalf("Execute(%q) error = %v", path, err)
+		}
+		wantJSON, _ := json.Marshal(want)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 32)
+		for g := 0; g < 32; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 50; i++ {
+					got, err := c.Execute(data)
+					if err != nil {
+						errs <- err
+						return
+					}
+					gotJSON, _ := json.Marshal(got)
+					if string(gotJSON) != string(wantJSON) {
+						errs <- fmt.Errorf("%s: got %s, want %s", path, gotJSON, wantJSON)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Error(err)
+		}
+	}
+}
+
+func TestMustCompile(t *testing.T) {
+	c := MustCompile("$.store.book[0].title")
+	if c.NumSegments() != 4 {
+		t.Errorf("NumSegments() = %d, want 4", c.NumSegments())
+	}
+	if n := MustCompile("$").NumSegments(); n != 0 {
+		t.Errorf("NumSegments() for $ = %d, want 0", n)
+	}
+	if n := MustCompile("$.a.b | $.c").NumSegments(); n != 1 {
+		t.Errorf("NumSegments() for a union = %d, want 1", n)
+	}
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("MustCompile() did not panic on invalid path")
+		}
+		msg, ok := r.(string)
+		if !ok || !strings.HasPrefix(msg, `jsonpath: Compile("$.store[")`) {
+			t.Errorf("MustCompile() panic = %v", r)
+		}
+	}()
+	MustCompile("$.store[")
+}
+
+func TestCompiledExecuteAll(t *testing.T) {
+	c := MustCompile("$.items[*].id", WithMaxResults(2))
+	docs := []interface{}{
+		`{"items": [{"id": 1}, {"id": 2}]}`,
+		`{"items": [`,
+		map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": "x"}}},
+		`{"items": [{"id": 1}, {"id": 2}, {"id": 3}]}`,
+		`{"other": true}`,
+	}
+
+	results := c.ExecuteAll(docs)
+	if len(results) != len(docs) {
+		t.Fatalf("ExecuteAll() returned %d results, want %d", len(results), len(docs))
+	}
+
+	tests := []struct {
+		count   int
+		wantErr bool
+	}{
+		{count: 2},
+		{wantErr: true},
+		{count: 1},
+		{wantErr: true},
+		{count: 0},
+	}
+	for i, tt := range tests {
+		r := results[i]
+		if (r.Err != nil) != tt.wantErr {
+			t.Errorf("results[%d].Err = %v, wantErr %v", i, r.Err, tt.wantErr)
+		}
+		if len(r.Nodes) != tt.count {
+			t.Errorf("results[%d] has %d nodes, want %d", i, len(r.Nodes), tt.count)
+		}
+	}
+	if results[2].Nodes[0].Value != "x" {
+		t.Errorf("results[2] value = %v, want x", results[2].Nodes[0].Value)
+	}
+
+	// Per-call options override the Compile options for the whole batch
+	results = c.ExecuteAll(docs[3:4], WithMaxResults(0))
+	if results[0].Err != nil || len(results[0].Nodes) != 3 {
+		t.Errorf("ExecuteAll() with override = %+v, want 3 nodes", results[0])
+	}
+}
+
+func TestCompiledIsSingular(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"$", true},
+		{"$.store.book[0].title", true},
+		{"$['store']['book'][-1]", true},
+		{"$.store.book[*]", false},
+		{"$..title", false},
+		{"$.store.book[0:1]", false},
+		{"$.store.book[0,1]", false},
+		{"$['a','b']", false},
+		{"$.store.book[?@.price < 10]", false},
+		{"$.store.book.length()", false},
+		{"length($.store.book)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := MustCompile(tt.path).IsSingular(); got != tt.want {
+				t.Errorf("IsSingular() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
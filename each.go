@@ -0,0 +1,91 @@
+package jsonpath
+
+import "fmt"
+
+// Each executes a JSONPath query and calls fn with the value and Normalized
+// Path of each selected node, in the order Query would return them. It
+// stops as soon as fn returns false. Unlike Query, Each does not collect the
+// results, and work on nodes after the last one visited is skipped.
+func Each(data interface{}, path string, fn func(value interface{}, path string) bool, opts ...Option) error {
+	data, err := decodeDocument(data)
+	if err != nil {
+		return err
+	}
+	c, err := Compile(path, opts...)
+	if err != nil {
+		return fmt.Errorf("invalid path: %v", err)
+	}
+	return c.each(data, fn, nil)
+}
+
+// Each evaluates the compiled expression like Execute and calls fn for each
+// selected node until fn returns false. See the package-level Each.
+func (c *Compiled) Each(data interface{}, fn func(value interface{}, path string) bool, opts ...Option) error {
+	data, err := decodeDocument(data)
+	if err != nil {
+		return err
+	}
+	return c.each(data, fn, opts)
+}
+
+func (c *Compiled) each(data interface{}, fn func(value interface{}, path string) bool, opts []Option) error {
+	o := c.resolveOptions(opts)
+	if err := c.checkParams(o); err != nil {
+		return err
+	}
+	ctx := &evalContext{opts: o}
+	starts := NodeList{{Location: "$", Value: data, Root: data}}
+	segments := c.segments
+	// Segments up to the last one consuming a whole nodelist, such as an
+	// aggregate, cannot be walked depth-first and are evaluated up front
+	for i := len(segments) - 1; i >= 0; i-- {
+		if _, ok := segments[i].(nodeListSegment); ok {
+			var err error
+			if starts, err = ctx.evaluate(segments[:i+1], data); err != nil {
+				return err
+			}
+			segments = segments[i+1:]
+			break
+		}
+	}
+	visited := 0
+	visit := func(n Node) (bool, error) {
+		visited++
+		if err := ctx.checkResults(visited); err != nil {
+			return false, err
+		}
+		return fn(n.Value, n.Location), nil
+	}
+	for _, start := range starts {
+		more, err := ctx.walk(segments, start, visit)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// walk evaluates segments depth-first from node, calling visit for each
+// result. It returns false once visit has asked to stop.
+func (ctx *evalContext) walk(segments []segmentV3, node Node, visit func(Node) (bool, error)) (bool, error) {
+	if len(segments) == 0 {
+		return visit(node)
+	}
+	if err := ctx.step(); err != nil {
+		return false, err
+	}
+	children, err := segments[0].evaluate(ctx, node)
+	if ctx.err != nil {
+		return false, ctx.err
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, child := range children {
+		more, err := ctx.walk(segments[1:], child, visit)
+		if err != nil || !more {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,85 @@
+package jsonpath
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEach(t *testing.T) {
+	data := `{"store": {"book": [
+		{"title": "A", "price": 8.95},
+		{"title": "B", "price": 12.99},
+		{"title": "C", "price": 8.99}
+	], "bicycle": {"price": 19.95}}}`
+
+	paths := []string{
+		"$.store.book[*].title",
+		"$..price",
+		"$.store.*",
+		"$.store.book[?@.price < 10]",
+		"$.store.book[0,2,0].title",
+		"$.missing",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			want, err := Query(data, path)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			var got NodeList
+			err = Each(data, path, func(value interface{}, location string) bool {
+				got = append(got, Node{Location: location, Value: value})
+				return true
+			})
+			if err != nil {
+				t.Fatalf("Each() error = %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("Each() visited %d nodes, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i].Location != want[i].Location || !reflect.DeepEqual(got[i].Value, want[i].Value) {
+					t.Errorf("node %d = %s %v, want %s %v", i, got[i].Location, got[i].Value, want[i].Location, want[i].Value)
+				}
+			}
+		})
+	}
+}
+
+func TestEachStops(t *testing.T) {
+	data := []interface{}{1.0, 2.0, 3.0, 4.0}
+
+	var seen []interface{}
+	err := MustCompile("$[*]").Each(data, func(value interface{}, _ string) bool {
+		seen = append(seen, value)
+		return len(seen) < 2
+	})
+	if err != nil {
+		t.Fatalf("Each() error = %v", err)
+	}
+	if want := []interface{}{1.0, 2.0}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("Each() visited %v, want %v", seen, want)
+	}
+
+	// Stopping early avoids the work a full query would exceed its limit on
+	err = Each(data, "$[*]", func(interface{}, string) bool { return false }, WithMaxSteps(3))
+	if err != nil {
+		t.Errorf("Each() with early stop error = %v", err)
+	}
+	if _, err := Query(data, "$[*]", WithMaxResults(3)); err == nil {
+		t.Error("Query() expected limit error")
+	}
+	if err := Each(data, "$[*]", func(interface{}, string) bool { return true }, WithMaxResults(3)); err == nil {
+		t.Error("Each() expected limit error")
+	}
+}
+
+func TestEachErrors(t *testing.T) {
+	noop := func(interface{}, string) bool { return true }
+	if err := Each(`{`, "$", noop); err == nil {
+		t.Error("Each() with invalid JSON expected error")
+	}
+	if err := Each(`{}`, "$[", noop); err == nil {
+		t.Error("Each() with invalid path expected error")
+	}
+}
@@ -1,5 +1,7 @@
 package jsonpath
 
+import "fmt"
+
 // ErrorType represents the type of error that occurred
 type ErrorType int
 
@@ -10,6 +12,7 @@ const (
 	ErrEvaluation                       // Error during evaluation
 	ErrInvalidFunction                  // Invalid function call
 	ErrInvalidArgument                  // Invalid argument
+	ErrLimitExceeded                    // Evaluation limit exceeded
 )
 
 // Error represents a JSONPath error
@@ -17,6 +20,7 @@ type Error struct {
 	Type    ErrorType // Type of error
 	Message string    // Error message
 	Path    string    // JSONPath expression where error occurred
+	Offset  int       // Byte offset in Path where a syntax error was found, or -1
 }
 
 // Error implements the error interface
@@ -30,5 +34,20 @@ func NewError(typ ErrorType, msg string, path string) error {
 		Type:    typ,
 		Message: msg,
 		Path:    path,
+		Offset:  -1,
+	}
+}
+
+// syntaxError creates an error for a problem found at the given byte
+// offset of path. The offset is also reported in the message.
+func syntaxError(typ ErrorType, msg string, path string, offset int) error {
+	return &Error{
+		Type:    typ,
+		Message: fmt.Sprintf("%s at position %d", msg, offset),
+		Path:    path,
+		Offset:  offset,
 	}
 }
+
+// ErrFunctionNotFound 表示函数未找到
+var ErrFunctionNotFound = fmt.Errorf("function not found")
@@ -0,0 +1,811 @@
+package jsonpath
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/davidhoo/jsonpath/ast"
+)
+
+// compileFilter builds the evaluator for a filter expression
+func compileFilter(expr ast.Expr) (exprNode, error) {
+	switch e := expr.(type) {
+	case *ast.OrExpr:
+		children, err := compileFilters(e.Operands)
+		if err != nil {
+			return nil, err
+		}
+		return &orNode{children: children}, nil
+	case *ast.AndExpr:
+		children, err := compileFilters(e.Operands)
+		if err != nil {
+			return nil, err
+		}
+		return &andNode{children: children}, nil
+	case *ast.NotExpr:
+		child, err := compileFilter(e.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{child: child, expr: e}, nil
+	case *ast.ComparisonExpr:
+		left, err := compileOperand(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := compileOperand(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		node := &comparisonNode{left: left, op: e.Op, right: right, expr: e}
+		node.custom, _ = lookupOperator(e.Op)
+		if lit, ok := e.Right.(*ast.Literal); ok && e.Op == "=~" {
+			pattern, _ := lit.Value.(string)
+			if node.re, err = getCompiledRegex(pattern); err != nil {
+				return nil, NewError(ErrInvalidFilter, "invalid regular expression: "+err.Error(), e.String())
+			}
+		}
+		return node, nil
+	case *ast.Query:
+		query, err := compileOperand(e)
+		if err != nil {
+			return nil, err
+		}
+		return &existenceNode{query: query.(*queryOperand)}, nil
+	case *ast.FunctionCall:
+		call, err := compileOperand(e)
+		if err != nil {
+			return nil, err
+		}
+		return &testNode{call: call.(*callOperand)}, nil
+	default:
+		return nil, NewError(ErrInvalidFilter, "invalid filter test: "+expr.String(), expr.String())
+	}
+}
+
+func compileFilters(exprs []ast.Expr) ([]exprNode, error) {
+	nodes := make([]exprNode, len(exprs))
+	for i, e := range exprs {
+		node, err := compileFilter(e)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+	return nodes, nil
+}
+
+// compileOperand builds the evaluator for a comparison operand or a
+// function argument
+func compileOperand(expr ast.Expr) (operand, error) {
+	switch e := expr.(type) {
+	case *ast.Literal:
+		return &literalOperand{lit: e.Value}, nil
+	case *ast.Parameter:
+		return &paramOperand{ref: paramRef{name: e.Name}}, nil
+	case *ast.Query:
+		segments, err := compileQuery(e)
+		if err != nil {
+			return nil, err
+		}
+		return &queryOperand{query: e, segments: segments}, nil
+	case *ast.ArithmeticExpr:
+		left, err := compileOperand(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := compileOperand(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &arithmeticOperand{left: left, op: e.Op, right: right}, nil
+	case *ast.FunctionCall:
+		if base, ok := indexFunctions[e.Name]; ok {
+			return &indexOperand{base: base}, nil
+		}
+		if e.Name == "key" {
+			return &keyOperand{}, nil
+		}
+		call := &callOperand{call: e, args: make([]operand, len(e.Args))}
+		for i, arg := range e.Args {
+			var err error
+			if call.args[i], err = compileOperand(arg); err != nil {
+				return nil, err
+			}
+		}
+		return call, nil
+	default:
+		return nil, NewError(ErrInvalidFilter, "invalid operand: "+expr.String(), expr.String())
+	}
+}
+
+// exprNode represents a node in the filter expression tree
+type exprNode interface {
+	evaluate(ctx *evalContext, item interface{}, root interface{}) (bool, error)
+}
+
+// andNode represents an AND operation
+type andNode struct {
+	children []exprNode
+}
+
+func (n *andNode) evaluate(ctx *evalContext, item interface{}, root interface{}) (bool, error) {
+	for _, child := range n.children {
+		result, err := child.evaluate(ctx, item, root)
+		if err != nil {
+			return false, err
+		}
+		if !result {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// orNode represents an OR operation
+type orNode struct {
+	children []exprNode
+}
+
+func (n *orNode) evaluate(ctx *evalContext, item interface{}, root interface{}) (bool, error) {
+	for _, child := range n.children {
+		result, err := child.evaluate(ctx, item, root)
+		if err != nil {
+			return false, err
+		}
+		if result {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// notNode negates a filter expression
+type notNode struct {
+	child exprNode
+	expr  *ast.NotExpr
+}
+
+func (n *notNode) evaluate(ctx *evalContext, item interface{}, root interface{}) (bool, error) {
+	result, err := n.child.evaluate(ctx, item, root)
+	return !result && err == nil, err
+}
+
+func (n *notNode) String() string { return n.expr.String() }
+
+// comparisonNode compares the values of two operands. re is the pattern
+// of =~ when it is a literal, and custom the function of an operator added
+// by RegisterOperator.
+type comparisonNode struct {
+	left, right operand
+	op          string
+	re          *regexp.Regexp
+	custom      OperatorFunc
+	expr        *ast.ComparisonExpr
+}
+
+func (n *comparisonNode) evaluate(ctx *evalContext, item interface{}, root interface{}) (bool, error) {
+	left := n.left.value(ctx, item, root)
+	right := n.right.value(ctx, item, root)
+	if ctx != nil && ctx.opts.timeLayouts != nil {
+		a, okA := parseTime(left, ctx.opts.timeLayouts)
+		b, okB := parseTime(right, ctx.opts.timeLayouts)
+		if result, ok := compareTimes(a, n.op, b); ok && (okA || okB) {
+			if !okA || !okB {
+				// A timestamp is only comparable with another timestamp
+				return n.op == "!=", nil
+			}
+			return result, nil
+		}
+	}
+	if ctx != nil && ctx.opts.caseInsensitiveComps && n.op != "=~" {
+		left, right = foldCase(left), foldCase(right)
+	}
+	if n.custom != nil {
+		return n.custom(left, right), nil
+	}
+	switch n.op {
+	case "in":
+		return isMember(left, right), nil
+	case "nin":
+		return !isMember(left, right), nil
+	case "contains":
+		if str, ok := left.(string); ok {
+			sub, ok := right.(string)
+			return ok && strings.Contains(str, sub), nil
+		}
+		return isMember(right, left), nil
+	case "subsetof", "anyof":
+		return setRelation(left, n.op, right), nil
+	case "=~":
+		return n.matches(left, right), nil
+	case "<", "<=", ">", ">=":
+		if ctx != nil && ctx.opts.strictComparisons {
+			if lt, rt := jsonType(left), jsonType(right); lt != rt && lt != "" && rt != "" {
+				return false, NewError(ErrEvaluation, "incompatible types in comparison: "+lt+" "+n.op+" "+rt, n.expr.String())
+			}
+		}
+	}
+	result, err := compareValues(left, n.op, right)
+	if err != nil {
+		return false, nil
+	}
+	return result, nil
+}
+
+// matches reports whether value is a string matching the regex pattern.
+// A pattern that is not a literal is compiled when it is evaluated.
+func (n *comparisonNode) matches(value, pattern interface{}) bool {
+	str, ok := value.(string)
+	if !ok {
+		return false
+	}
+	re := n.re
+	if re == nil {
+		p, ok := pattern.(string)
+		if !ok {
+			return false
+		}
+		var err error
+		if re, err = getCompiledRegex(p); err != nil {
+			return false
+		}
+	}
+	return re.MatchString(str)
+}
+
+func (n *comparisonNode) String() string { return n.expr.String() }
+
+// parseTime parses v as a timestamp with the first layout that accepts it.
+// It is false unless v is such a string.
+func parseTime(v interface{}, layouts []string) (time.Time, bool) {
+	str, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, str); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// compareTimes applies a comparison operator to two timestamps. ok is
+// false for operators other than ==, !=, <, <=, > and >=.
+func compareTimes(a time.Time, op string, b time.Time) (result, ok bool) {
+	switch op {
+	case "==":
+		return a.Equal(b), true
+	case "!=":
+		return !a.Equal(b), true
+	case "<":
+		return a.Before(b), true
+	case "<=":
+		return !a.After(b), true
+	case ">":
+		return a.After(b), true
+	case ">=":
+		return !a.Before(b), true
+	}
+	return false, false
+}
+
+// jsonType returns the name of the JSON type of v, or "" for Nothing
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, int, int64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return ""
+	}
+}
+
+// foldCase returns v with every string, including those inside arrays
+// and object members, in lower case. Member names are left unchanged.
+func foldCase(v interface{}) interface{} {
+	switch x := v.(type) {
+	case string:
+		return strings.ToLower(x)
+	case []interface{}:
+		folded := make([]interface{}, len(x))
+		for i, elem := range x {
+			folded[i] = foldCase(elem)
+		}
+		return folded
+	case map[string]interface{}:
+		folded := make(map[string]interface{}, len(x))
+		for k, elem := range x {
+			folded[k] = foldCase(elem)
+		}
+		return folded
+	default:
+		return v
+	}
+}
+
+// isMember reports whether value equals an element of the array list
+func isMember(value, list interface{}) bool {
+	elems, ok := list.([]interface{})
+	if _, absent := value.(Nothing); !ok || absent {
+		return false
+	}
+	for _, elem := range elems {
+		if equal, err := compareValues(value, "==", elem); err == nil && equal {
+			return true
+		}
+	}
+	return false
+}
+
+// setRelation reports whether all (subsetof) or any (anyof) elements of
+// the array left are elements of the array right. It is false unless both
+// are arrays.
+func setRelation(left interface{}, op string, right interface{}) bool {
+	elems, ok := left.([]interface{})
+	if _, isArray := right.([]interface{}); !ok || !isArray {
+		return false
+	}
+	for _, elem := range elems {
+		member := isMember(elem, right)
+		if op == "anyof" && member {
+			return true
+		}
+		if op == "subsetof" && !member {
+			return false
+		}
+	}
+	return op == "subsetof"
+}
+
+// existenceNode is true when a query selects at least one node, or with
+// WithTruthiness at least one truthy value
+type existenceNode struct {
+	query *queryOperand
+}
+
+func (n *existenceNode) evaluate(ctx *evalContext, item interface{}, root interface{}) (bool, error) {
+	nodes := n.query.nodes(ctx, item, root)
+	if ctx == nil || !ctx.opts.truthiness {
+		return len(nodes) > 0, nil
+	}
+	for _, node := range nodes {
+		if isTruthy(node.Value) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isTruthy reports whether v is truthy as in JavaScript
+func isTruthy(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case float64:
+		return x != 0 && !math.IsNaN(x)
+	case int:
+		return x != 0
+	case int64:
+		return x != 0
+	default:
+		return true
+	}
+}
+
+func (n *existenceNode) String() string { return n.query.query.String() }
+
+// testNode is true when a function call yields true
+type testNode struct {
+	call *callOperand
+}
+
+func (n *testNode) evaluate(ctx *evalContext, item interface{}, root interface{}) (bool, error) {
+	result, ok := n.call.value(ctx, item, root).(bool)
+	return ok && result, nil
+}
+
+func (n *testNode) String() string { return n.call.call.String() }
+
+// operand produces a value inside a filter expression. Absent values are
+// reported as Nothing.
+type operand interface {
+	value(ctx *evalContext, item interface{}, root interface{}) interface{}
+}
+
+// literalOperand is a literal value
+type literalOperand struct {
+	lit interface{}
+}
+
+func (o *literalOperand) value(ctx *evalContext, item interface{}, root interface{}) interface{} {
+	return o.lit
+}
+
+// paramOperand is a placeholder bound at execution time
+type paramOperand struct {
+	ref paramRef
+}
+
+func (o *paramOperand) value(ctx *evalContext, item interface{}, root interface{}) interface{} {
+	return ctx.resolveParam(o.ref)
+}
+
+// queryOperand is a query relative to the current item (@) or the root ($)
+type queryOperand struct {
+	query    *ast.Query
+	segments []segmentV3
+}
+
+// nodes evaluates the query. Evaluation errors select nothing.
+func (o *queryOperand) nodes(ctx *evalContext, item interface{}, root interface{}) NodeList {
+	start := Node{Location: "$", Value: root, Root: root}
+	if o.query.Relative {
+		start = Node{Location: "@", Value: item, Root: root}
+	}
+	results, err := ctx.evaluateFrom(o.segments, start)
+	if err != nil {
+		return nil
+	}
+	return results
+}
+
+// value returns the value of the single node selected, Nothing when no
+// node is selected and the values of all nodes when there are several
+func (o *queryOperand) value(ctx *evalContext, item interface{}, root interface{}) interface{} {
+	results := o.nodes(ctx, item, root)
+	switch len(results) {
+	case 0:
+		return Nothing{}
+	case 1:
+		return results[0].Value
+	default:
+		return nodeValues(results)
+	}
+}
+
+// arithmeticOperand applies an arithmetic operator to two numbers. It
+// yields Nothing when an operand is not a number or the result is not a
+// finite number, e.g. after a division by zero.
+type arithmeticOperand struct {
+	left, right operand
+	op          string
+}
+
+func (o *arithmeticOperand) value(ctx *evalContext, item interface{}, root interface{}) interface{} {
+	a, b, ok := normalizeNumbers(o.left.value(ctx, item, root), o.right.value(ctx, item, root))
+	if !ok {
+		return Nothing{}
+	}
+	var result float64
+	switch o.op {
+	case "+":
+		result = a + b
+	case "-":
+		result = a - b
+	case "*":
+		result = a * b
+	case "/":
+		result = a / b
+	case "%":
+		result = math.Mod(a, b)
+	}
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return Nothing{}
+	}
+	return result
+}
+
+// indexFunctions map index() and position() to the number they give the
+// first array element
+var indexFunctions = map[string]int{
+	"index":    0,
+	"position": 1,
+}
+
+// indexOperand is index() or position(), the index of the array element
+// being tested counted from base. It is Nothing for object members.
+type indexOperand struct {
+	base int
+}
+
+func (o *indexOperand) value(ctx *evalContext, item interface{}, root interface{}) interface{} {
+	if ctx == nil {
+		return Nothing{}
+	}
+	index, ok := ctx.key.(int)
+	if !ok {
+		return Nothing{}
+	}
+	return float64(index + o.base)
+}
+
+// keyOperand is key(), the name of the object member being tested or the
+// index of the array element
+type keyOperand struct{}
+
+func (o *keyOperand) value(ctx *evalContext, item interface{}, root interface{}) interface{} {
+	if ctx == nil {
+		return Nothing{}
+	}
+	switch key := ctx.key.(type) {
+	case string:
+		return key
+	case int:
+		return float64(key)
+	default:
+		return Nothing{}
+	}
+}
+
+// callOperand is a function call, looked up when it is evaluated. Failed
+// calls and calls with an absent argument, except to the fallback
+// functions, yield Nothing.
+type callOperand struct {
+	call *ast.FunctionCall
+	args []operand
+}
+
+func (o *callOperand) value(ctx *evalContext, item interface{}, root interface{}) interface{} {
+	fn, err := ctx.opts.parse.function(o.call.Name)
+	if err != nil {
+		return Nothing{}
+	}
+	sig, typed := standardSignatures[o.call.Name]
+	args := make([]interface{}, len(o.args))
+	for i, arg := range o.args {
+		if q, ok := arg.(*queryOperand); ok && typed && i < len(sig.params) && sig.params[i] == nodesType {
+			args[i] = nodeValues(q.nodes(ctx, item, root))
+			continue
+		}
+		args[i] = arg.value(ctx, item, root)
+		if _, ok := args[i].(Nothing); ok && !fallbackFunctions[o.call.Name] {
+			return Nothing{}
+		}
+	}
+	result, err := fn.Call(args)
+	if err != nil {
+		return Nothing{}
+	}
+	return normalizeParam(result)
+}
+
+// nodeValues returns the values of nodes
+func nodeValues(nodes NodeList) []interface{} {
+	values := make([]interface{}, len(nodes))
+	for i, n := range nodes {
+		values[i] = n.Value
+	}
+	return values
+}
+
+// compareValues compares two values based on the operator
+func compareValues(value1 interface{}, operator string, value2 interface{}) (bool, error) {
+	// 检查操作符是否有效
+	validOperators := map[string]bool{
+		"==":    true,
+		"!=":    true,
+		">":     true,
+		"<":     true,
+		">=":    true,
+		"<=":    true,
+		"match": true,
+	}
+	if !validOperators[operator] {
+		return false, fmt.Errorf("invalid operator: %s", operator)
+	}
+
+	// Handle Nothing values
+	_, isNothing1 := value1.(Nothing)
+	_, isNothing2 := value2.(Nothing)
+	if isNothing1 || isNothing2 {
+		switch operator {
+		case "==":
+			return isNothing1 && isNothing2, nil // Nothing == Nothing → true
+		case "!=":
+			return !(isNothing1 && isNothing2), nil // Nothing != Nothing → false
+		case "<=", ">=":
+			return isNothing1 && isNothing2, nil // Nothing <= Nothing → true
+		default:
+			return false, nil
+		}
+	}
+
+	// 处理 nil 值
+	if value1 == nil || value2 == nil {
+		switch operator {
+		case "==":
+			return value1 == value2, nil
+		case "!=":
+			return value1 != value2, nil
+		case "<=", ">=":
+			// null <= null and null >= null are true (same type comparison)
+			return value1 == value2, nil
+		default:
+			return false, nil
+		}
+	}
+
+	// 处理数字类型
+	num1, num2, isNum := normalizeNumbers(value1, value2)
+	if isNum {
+		switch operator {
+		case "==":
+			return num1 == num2, nil
+		case "!=":
+			return num1 != num2, nil
+		case ">":
+			return num1 > num2, nil
+		case "<":
+			return num1 < num2, nil
+		case ">=":
+			return num1 >= num2, nil
+		case "<=":
+			return num1 <= num2, nil
+		default:
+			return false, fmt.Errorf("invalid operator for numbers: %s", operator)
+		}
+	}
+
+	// 处理字符串类型
+	if str1, ok := value1.(string); ok {
+		if str2, ok := value2.(string); ok {
+			switch operator {
+			case "==":
+				return str1 == str2, nil
+			case "!=":
+				return str1 != str2, nil
+			case ">":
+				return str1 > str2, nil
+			case "<":
+				return str1 < str2, nil
+			case ">=":
+				return str1 >= str2, nil
+			case "<=":
+				return str1 <= str2, nil
+			case "match":
+				re, err := regexp.Compile(str2)
+				if err != nil {
+					return false, fmt.Errorf("invalid regex pattern: %s", str2)
+				}
+				return re.MatchString(str1), nil
+			default:
+				return false, fmt.Errorf("invalid operator for strings: %s", operator)
+			}
+		}
+		if operator == "match" {
+			return false, fmt.Errorf("pattern must be a string")
+		}
+	}
+	if operator == "match" {
+		return false, fmt.Errorf("value must be a string")
+	}
+
+	// 处理布尔类型
+	if bool1, ok := value1.(bool); ok {
+		if bool2, ok := value2.(bool); ok {
+			switch operator {
+			case "==":
+				return bool1 == bool2, nil
+			case "!=":
+				return bool1 != bool2, nil
+			case "<=", ">=":
+				// true <= true, false <= false are true (same type comparison)
+				return bool1 == bool2, nil
+			default:
+				return false, nil
+			}
+		}
+	}
+
+	// 处理数组类型 - RFC 9535 支持深度比较
+	if arr1, ok := value1.([]interface{}); ok {
+		if arr2, ok := value2.([]interface{}); ok {
+			switch operator {
+			case "==":
+				return deepCompareValues(arr1, arr2), nil
+			case "!=":
+				return !deepCompareValues(arr1, arr2), nil
+			default:
+				return false, nil
+			}
+		}
+	}
+
+	// 处理对象类型 - RFC 9535 支持深度比较
+	if obj1, ok := value1.(map[string]interface{}); ok {
+		if obj2, ok := value2.(map[string]interface{}); ok {
+			switch operator {
+			case "==":
+				return deepCompareValues(obj1, obj2), nil
+			case "!=":
+				return !deepCompareValues(obj1, obj2), nil
+			default:
+				return false, nil
+			}
+		}
+	}
+
+	// RFC 9535: comparison between incompatible types
+	// == returns false, != returns true
+	if operator == "!=" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// deepCompareValues performs deep comparison of two values
+func deepCompareValues(a, b interface{}) bool {
+	switch v1 := a.(type) {
+	case []interface{}:
+		v2, ok := b.([]interface{})
+		if !ok || len(v1) != len(v2) {
+			return false
+		}
+		for i := range v1 {
+			if !deepCompareValues(v1[i], v2[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		v2, ok := b.(map[string]interface{})
+		if !ok || len(v1) != len(v2) {
+			return false
+		}
+		for k, val1 := range v1 {
+			val2, exists := v2[k]
+			if !exists || !deepCompareValues(val1, val2) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// normalizeNumbers 将两个值转换为 float64 类型
+func normalizeNumbers(value1, value2 interface{}) (float64, float64, bool) {
+	var num1, num2 float64
+	var ok1, ok2 bool
+
+	// 尝试将 value1 转换为 float64
+	switch v := value1.(type) {
+	case float64:
+		num1, ok1 = v, true
+	case int64:
+		num1, ok1 = float64(v), true
+	case int:
+		num1, ok1 = float64(v), true
+	}
+
+	// 尝试将 value2 转换为 float64
+	switch v := value2.(type) {
+	case float64:
+		num2, ok2 = v, true
+	case int64:
+		num2, ok2 = float64(v), true
+	case int:
+		num2, ok2 = float64(v), true
+	}
+
+	return num1, num2, ok1 && ok2
+}
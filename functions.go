@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unicode/utf8"
 )
 
@@ -19,13 +20,189 @@ type Function interface {
 	Name() string
 }
 
-// builtinFunction is a helper type for implementing Function interface
+// ArgType is the set of JSON types a function argument may have. Types
+// combine with |, e.g. ArgString | ArgArray.
+type ArgType int
+
+const (
+	ArgString ArgType = 1 << iota
+	ArgNumber
+	ArgBool
+	ArgNull
+	ArgArray
+	ArgObject
+	ArgAny = ArgString | ArgNumber | ArgBool | ArgNull | ArgArray | ArgObject
+
+	argScalar = ArgString | ArgNumber | ArgBool | ArgNull
+)
+
+// argTypeNames names the types of an ArgType in error messages
+var argTypeNames = []struct {
+	typ  ArgType
+	name string
+}{
+	{ArgString, "string"},
+	{ArgNumber, "number"},
+	{ArgBool, "boolean"},
+	{ArgNull, "null"},
+	{ArgArray, "array"},
+	{ArgObject, "object"},
+}
+
+// String describes t for error messages, e.g. "a string or array"
+func (t ArgType) String() string {
+	var names []string
+	for _, n := range argTypeNames {
+		if t&n.typ != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "nothing"
+	}
+	desc := names[len(names)-1]
+	if len(names) > 1 {
+		desc = strings.Join(names[:len(names)-1], ", ") + " or " + desc
+	}
+	if strings.IndexByte("aeiou", desc[0]) >= 0 {
+		return "an " + desc
+	}
+	return "a " + desc
+}
+
+// accepts reports whether v has one of the types in t
+func (t ArgType) accepts(v interface{}) bool {
+	if t == ArgAny {
+		return true
+	}
+	switch v.(type) {
+	case string:
+		return t&ArgString != 0
+	case float64, float32, int, int32, int64, json.Number:
+		return t&ArgNumber != 0
+	case bool:
+		return t&ArgBool != 0
+	case nil:
+		return t&ArgNull != 0
+	case []interface{}:
+		return t&ArgArray != 0
+	case map[string]interface{}:
+		return t&ArgObject != 0
+	}
+	return false
+}
+
+// Signature declares the arguments of a function: the type of each one,
+// how many of the last ones may be omitted, and whether the last one may
+// repeat. Numbers passed for an ArgNumber parameter are converted to
+// float64 before the function is called.
+type Signature struct {
+	Params   []ArgType
+	Optional int
+	Variadic bool
+}
+
+// check validates args against the signature of the function name and
+// returns them with numbers converted to float64. Violations are
+// ErrInvalidArgument errors.
+func (sig *Signature) check(name string, args []interface{}) ([]interface{}, error) {
+	maxArgs := len(sig.Params)
+	minArgs := maxArgs - sig.Optional
+	variadic := sig.Variadic && maxArgs > 0
+	if len(args) < minArgs || (len(args) > maxArgs && !variadic) {
+		return nil, NewError(ErrInvalidArgument, arityMessage(name, minArgs, maxArgs, variadic), name)
+	}
+	var coerced []interface{}
+	for i, arg := range args {
+		typ := sig.Params[len(sig.Params)-1]
+		if i < len(sig.Params) {
+			typ = sig.Params[i]
+		}
+		if !typ.accepts(arg) {
+			which := "argument"
+			if maxArgs > 1 || variadic {
+				which = ordinal(i+1) + " argument"
+			}
+			return nil, NewError(ErrInvalidArgument, fmt.Sprintf("%s() %s must be %s", name, which, typ), name)
+		}
+		n, isNumber := numberArg(arg)
+		if _, exact := arg.(float64); !isNumber || exact || typ&ArgNumber == 0 {
+			continue
+		}
+		if coerced == nil {
+			coerced = append([]interface{}(nil), args...)
+		}
+		coerced[i] = n
+	}
+	if coerced != nil {
+		return coerced, nil
+	}
+	return args, nil
+}
+
+// numberArg returns the float64 value of a number argument
+func numberArg(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// arityMessage describes the number of arguments the function name takes
+func arityMessage(name string, minArgs, maxArgs int, variadic bool) string {
+	plural := func(n int) string {
+		if n == 1 {
+			return ""
+		}
+		return "s"
+	}
+	switch {
+	case variadic:
+		return fmt.Sprintf("%s() requires at least %d argument%s", name, minArgs, plural(minArgs))
+	case minArgs == maxArgs:
+		return fmt.Sprintf("%s() requires exactly %d argument%s", name, maxArgs, plural(maxArgs))
+	default:
+		return fmt.Sprintf("%s() requires %d to %d arguments", name, minArgs, maxArgs)
+	}
+}
+
+// ordinal spells out small positions for error messages
+func ordinal(n int) string {
+	if words := []string{"first", "second", "third", "fourth"}; n <= len(words) {
+		return words[n-1]
+	}
+	return strconv.Itoa(n) + "th"
+}
+
+// builtinFunction is a helper type for implementing Function interface.
+// A function with a signature has its arguments checked before callback
+// is called.
 type builtinFunction struct {
-	name     string
-	callback func([]interface{}) (interface{}, error)
+	name      string
+	signature *Signature
+	callback  func([]interface{}) (interface{}, error)
 }
 
 func (f *builtinFunction) Call(args []interface{}) (interface{}, error) {
+	if f.signature != nil {
+		checked, err := f.signature.check(f.name, args)
+		if err != nil {
+			return nil, err
+		}
+		args = checked
+	}
 	return f.callback(args)
 }
 
@@ -61,25 +238,6 @@ func getCompiledRegex(pattern string) (*regexp.Regexp, error) {
 	return re, nil
 }
 
-// removeAnchors 移除模式中的 ^ 和 $ 锚点
-func removeAnchors(pattern string) string {
-	if len(pattern) == 0 {
-		return pattern
-	}
-	
-	// 移除开头的 ^
-	if pattern[0] == '^' {
-		pattern = pattern[1:]
-	}
-	
-	// 移除结尾的 $
-	if len(pattern) > 0 && pattern[len(pattern)-1] == '$' {
-		pattern = pattern[:len(pattern)-1]
-	}
-	
-	return pattern
-}
-
 // numberType 表示数值类型
 type numberType int
 
@@ -198,42 +356,27 @@ func isInf32(f float32, sign int) bool {
 // globalFunctions is the registry of built-in functions
 var globalFunctions = map[string]Function{
 	"length": &builtinFunction{
-		name: "length",
+		name:      "length",
+		signature: &Signature{Params: []ArgType{ArgString | ArgArray | ArgObject}},
 		callback: func(args []interface{}) (interface{}, error) {
-			if len(args) != 1 {
-				return nil, fmt.Errorf("length() requires exactly 1 argument")
-			}
-
-			// 如果参数是数组，返回数组长度
-			if arr, ok := args[0].([]interface{}); ok {
-				return float64(len(arr)), nil
-			}
-
-			// 如果参数是字符串，返回字符串长度
-			if str, ok := args[0].(string); ok {
-				return float64(utf8.RuneCountInString(str)), nil
-			}
-
-			// 如果参数是对象，返回对象的键数量
-			if obj, ok := args[0].(map[string]interface{}); ok {
-				return float64(len(obj)), nil
+			switch v := args[0].(type) {
+			case []interface{}:
+				// 如果参数是数组，返回数组长度
+				return float64(len(v)), nil
+			case string:
+				// 如果参数是字符串，返回字符串长度
+				return float64(utf8.RuneCountInString(v)), nil
+			default:
+				// 参数是对象，返回对象的键数量
+				return float64(len(v.(map[string]interface{}))), nil
 			}
-
-			return nil, fmt.Errorf("length() argument must be string, array, or object")
 		},
 	},
 	"keys": &builtinFunction{
-		name: "keys",
+		name:      "keys",
+		signature: &Signature{Params: []ArgType{ArgObject}},
 		callback: func(args []interface{}) (interface{}, error) {
-			if len(args) != 1 {
-				return nil, fmt.Errorf("keys() requires exactly 1 argument")
-			}
-
-			// 确保参数是对象
-			obj, ok := args[0].(map[string]interface{})
-			if !ok {
-				return nil, fmt.Errorf("keys() argument must be an object")
-			}
+			obj := args[0].(map[string]interface{})
 
 			// 获取所有键并排序
 			keys := make([]string, 0, len(obj))
@@ -252,17 +395,10 @@ var globalFunctions = map[string]Function{
 		},
 	},
 	"values": &builtinFunction{
-		name: "values",
+		name:      "values",
+		signature: &Signature{Params: []ArgType{ArgObject}},
 		callback: func(args []interface{}) (interface{}, error) {
-			if len(args) != 1 {
-				return nil, fmt.Errorf("values() requires exactly 1 argument")
-			}
-
-			// 确保参数是对象
-			obj, ok := args[0].(map[string]interface{})
-			if !ok {
-				return nil, fmt.Errorf("values() argument must be an object")
-			}
+			obj := args[0].(map[string]interface{})
 
 			// 获取所有键并排序，以确保值的顺序一致
 			keys := make([]string, 0, len(obj))
@@ -280,37 +416,84 @@ var globalFunctions = map[string]Function{
 			return values, nil
 		},
 	},
-	// RFC 9535 count() - counts nodes in a nodelist
-	"count": &builtinFunction{
-		name: "count",
+	// Non-standard extension: keys_deep() - the sorted set of member names
+	// of an object or array and of all the objects nested in it
+	"keys_deep": &builtinFunction{
+		name:      "keys_deep",
+		signature: &Signature{Params: []ArgType{ArgObject | ArgArray}},
 		callback: func(args []interface{}) (interface{}, error) {
-			if len(args) != 1 {
-				return nil, fmt.Errorf("count() requires exactly 1 argument")
+			seen := make(map[string]bool)
+			collectKeys(args[0], seen)
+			keys := make([]string, 0, len(seen))
+			for k := range seen {
+				keys = append(keys, k)
 			}
-
-			// 如果参数是数组，返回数组长度
-			if arr, ok := args[0].([]interface{}); ok {
-				return float64(len(arr)), nil
+			sort.Strings(keys)
+			result := make([]interface{}, len(keys))
+			for i, k := range keys {
+				result[i] = k
 			}
-
-			// 如果参数是 NodeList (通过反射检查)
+			return result, nil
+		},
+	},
+	// Non-standard extension: entries() - the members of an object as an
+	// array of {"key": name, "value": value} objects, ordered by name
+	"entries": &builtinFunction{
+		name:      "entries",
+		signature: &Signature{Params: []ArgType{ArgObject}},
+		callback:  objectEntries,
+	},
+	// Non-standard extension: to_pairs() - alias of entries()
+	"to_pairs": &builtinFunction{
+		name:      "to_pairs",
+		signature: &Signature{Params: []ArgType{ArgObject}},
+		callback:  objectEntries,
+	},
+	// Non-standard extension: from_entries() - the object whose members are
+	// the {"key": name, "value": value} objects of an array, the inverse of
+	// entries(). A later entry replaces an earlier one with the same key.
+	"from_entries": &builtinFunction{
+		name:      "from_entries",
+		signature: &Signature{Params: []ArgType{ArgArray}},
+		callback: func(args []interface{}) (interface{}, error) {
+			result := make(map[string]interface{})
+			for _, item := range args[0].([]interface{}) {
+				entry, ok := item.(map[string]interface{})
+				if !ok {
+					return nil, NewError(ErrInvalidArgument, "from_entries() requires an array of objects", "from_entries")
+				}
+				key, ok := entry["key"].(string)
+				if !ok {
+					return nil, NewError(ErrInvalidArgument, "from_entries() entry key must be a string", "from_entries")
+				}
+				result[key] = entry["value"]
+			}
+			return result, nil
+		},
+	},
+	// RFC 9535 count() - counts nodes in a nodelist
+	"count": &builtinFunction{
+		name:      "count",
+		signature: &Signature{Params: []ArgType{ArgArray}},
+		callback: func(args []interface{}) (interface{}, error) {
 			// NodeList 在运行时是 []interface{} 类型
-			return nil, fmt.Errorf("count() argument must be a nodelist")
+			return float64(len(args[0].([]interface{}))), nil
+		},
+	},
+	// Non-standard extension: exists() - tests whether a nodelist is non-empty
+	"exists": &builtinFunction{
+		name:      "exists",
+		signature: &Signature{Params: []ArgType{ArgArray}},
+		callback: func(args []interface{}) (interface{}, error) {
+			return len(args[0].([]interface{})) > 0, nil
 		},
 	},
 	// Non-standard extension: occurrences() - counts value occurrences in an array
 	"occurrences": &builtinFunction{
-		name: "occurrences",
+		name:      "occurrences",
+		signature: &Signature{Params: []ArgType{ArgArray, ArgAny}},
 		callback: func(args []interface{}) (interface{}, error) {
-			if len(args) != 2 {
-				return nil, fmt.Errorf("occurrences() requires exactly 2 arguments: array and value")
-			}
-
-			// 确保第一个参数是数组
-			arr, ok := args[0].([]interface{})
-			if !ok {
-				return nil, fmt.Errorf("occurrences() first argument must be an array")
-			}
+			arr := args[0].([]interface{})
 
 			// 计算匹配值的数量
 			count := 0
@@ -324,17 +507,10 @@ var globalFunctions = map[string]Function{
 		},
 	},
 	"min": &builtinFunction{
-		name: "min",
+		name:      "min",
+		signature: &Signature{Params: []ArgType{ArgArray}},
 		callback: func(args []interface{}) (interface{}, error) {
-			if len(args) != 1 {
-				return nil, fmt.Errorf("min() requires exactly 1 argument")
-			}
-
-			// 确保参数是数组
-			arr, ok := args[0].([]interface{})
-			if !ok {
-				return nil, fmt.Errorf("min() argument must be an array")
-			}
+			arr := args[0].([]interface{})
 
 			if len(arr) == 0 {
 				return nil, fmt.Errorf("min() cannot be applied to an empty array")
@@ -374,17 +550,10 @@ var globalFunctions = map[string]Function{
 		},
 	},
 	"max": &builtinFunction{
-		name: "max",
+		name:      "max",
+		signature: &Signature{Params: []ArgType{ArgArray}},
 		callback: func(args []interface{}) (interface{}, error) {
-			if len(args) != 1 {
-				return nil, fmt.Errorf("max() requires exactly 1 argument")
-			}
-
-			// 确保参数是数组
-			arr, ok := args[0].([]interface{})
-			if !ok {
-				return nil, fmt.Errorf("max() argument must be an array")
-			}
+			arr := args[0].([]interface{})
 
 			if len(arr) == 0 {
 				return nil, fmt.Errorf("max() cannot be applied to an empty array")
@@ -424,17 +593,10 @@ var globalFunctions = map[string]Function{
 		},
 	},
 	"avg": &builtinFunction{
-		name: "avg",
+		name:      "avg",
+		signature: &Signature{Params: []ArgType{ArgArray}},
 		callback: func(args []interface{}) (interface{}, error) {
-			if len(args) != 1 {
-				return nil, fmt.Errorf("avg() requires exactly 1 argument")
-			}
-
-			// 确保参数是数组
-			arr, ok := args[0].([]interface{})
-			if !ok {
-				return nil, fmt.Errorf("avg() argument must be an array")
-			}
+			arr := args[0].([]interface{})
 
 			if len(arr) == 0 {
 				return nil, fmt.Errorf("avg() cannot be applied to an empty array")
@@ -472,17 +634,10 @@ var globalFunctions = map[string]Function{
 		},
 	},
 	"sum": &builtinFunction{
-		name: "sum",
+		name:      "sum",
+		signature: &Signature{Params: []ArgType{ArgArray}},
 		callback: func(args []interface{}) (interface{}, error) {
-			if len(args) != 1 {
-				return nil, fmt.Errorf("sum() requires exactly 1 argument")
-			}
-
-			// 确保参数是数组
-			arr, ok := args[0].([]interface{})
-			if !ok {
-				return nil, fmt.Errorf("sum() argument must be an array")
-			}
+			arr := args[0].([]interface{})
 
 			if len(arr) == 0 {
 				return nil, fmt.Errorf("sum() cannot be applied to an empty array")
@@ -527,124 +682,54 @@ var globalFunctions = map[string]Function{
 	// RFC 9535 match() - function-style: match(string, pattern)
 	// Uses I-Regexp for full-string matching
 	"match": &builtinFunction{
-		name: "match",
+		name:      "match",
+		signature: &Signature{Params: []ArgType{ArgAny, ArgString}},
 		callback: func(args []interface{}) (interface{}, error) {
-			// 1. 验证参数数量
-			if len(args) != 2 {
-				return nil, fmt.Errorf("match() requires exactly 2 arguments: string and pattern")
-			}
+			pattern := args[1].(string)
 
-			// 2. 获取并验证第二个参数（正则表达式模式）
-			pattern, ok := args[1].(string)
+			// 1. 获取第一个参数（要匹配的字符串），对于非字符串值，返回 false
+			str, ok := args[0].(string)
 			if !ok {
-				return nil, fmt.Errorf("match() second argument must be a string pattern")
-			}
-
-			// 3. 处理空模式
-			if pattern == "" {
-				return false, nil
-			}
-
-			// 4. 获取第一个参数（要匹配的字符串）
-			var str string
-			switch v := args[0].(type) {
-			case string:
-				str = v
-			default:
-				// 对于非字符串值，返回 false
 				return false, nil
 			}
 
-			// 5. 将 I-Regexp 转换为 Go regexp
-			goPattern, err := IRegexpToGoRegexp(pattern)
+			// 2. 编译 I-Regexp，match() 匹配整个字符串
+			re, err := compileIRegexp(pattern, true)
 			if err != nil {
 				return false, nil // 无效模式返回 false
 			}
 
-			// 6. 对于 match() 函数，我们需要全字符串匹配
-			// 移除现有的锚点，然后添加全字符串匹配
-			goPattern = removeAnchors(goPattern)
-			goPattern = "\\A(?:" + goPattern + ")\\z"
-
-			// 7. 获取或编译正则表达式
-			re, err := getCompiledRegex(goPattern)
-			if err != nil {
-				return false, nil // 正则表达式语法错误时返回 false
-			}
-
-			// 8. 执行匹配
+			// 3. 执行匹配
 			return re.MatchString(str), nil
 		},
 	},
 	// RFC 9535 search() - function-style: search(string, pattern)
 	// Returns true if string contains a match for the I-Regexp pattern
 	"search": &builtinFunction{
-		name: "search",
+		name:      "search",
+		signature: &Signature{Params: []ArgType{ArgString, ArgString}},
 		callback: func(args []interface{}) (interface{}, error) {
-			// 1. 验证参数数量
-			if len(args) != 2 {
-				return nil, fmt.Errorf("search() requires exactly 2 arguments: string and pattern")
-			}
-
-			// 2. 获取并验证第二个参数（正则表达式模式）
-			pattern, ok := args[1].(string)
-			if !ok {
-				return nil, fmt.Errorf("search() second argument must be a string pattern")
-			}
-
-			// 3. 处理空模式
-			if pattern == "" {
-				return true, nil // 空模式匹配任何字符串
-			}
-
-			// 4. 获取第一个参数（要搜索的字符串）
-			var str string
-			switch v := args[0].(type) {
-			case string:
-				str = v
-			default:
-				return nil, fmt.Errorf("search() first argument must be a string")
-			}
+			str, pattern := args[0].(string), args[1].(string)
 
-			// 5. 将 I-Regexp 转换为 Go regexp
-			goPattern, err := IRegexpToGoRegexp(pattern)
+			// 1. 编译 I-Regexp，空模式匹配任何字符串
+			re, err := compileIRegexp(pattern, false)
 			if err != nil {
 				return nil, fmt.Errorf("invalid I-Regexp pattern: %v", err)
 			}
 
-			// 6. 获取或编译正则表达式
-			re, err := getCompiledRegex(goPattern)
-			if err != nil {
-				return nil, fmt.Errorf("invalid regular expression: %v", err)
-			}
-
-			// 7. 执行搜索
+			// 2. 执行搜索
 			return re.MatchString(str), nil
 		},
 	},
 	// Non-standard extension: filterMatch() - filters array by regex
 	// Renamed from the old search() function
 	"filterMatch": &builtinFunction{
-		name: "filterMatch",
+		name:      "filterMatch",
+		signature: &Signature{Params: []ArgType{ArgArray, ArgString}},
 		callback: func(args []interface{}) (interface{}, error) {
-			// 1. 验证参数数量
-			if len(args) != 2 {
-				return nil, fmt.Errorf("filterMatch() requires exactly 2 arguments")
-			}
-
-			// 2. 获取数组参数
-			arr, ok := args[0].([]interface{})
-			if !ok {
-				return nil, fmt.Errorf("first argument must be an array")
-			}
+			arr, pattern := args[0].([]interface{}), args[1].(string)
 
-			// 3. 获取正则表达式参数
-			pattern, ok := args[1].(string)
-			if !ok {
-				return nil, fmt.Errorf("second argument must be a string pattern")
-			}
-
-			// 4. 处理转义字符
+			// 1. 处理转义字符
 			var result strings.Builder
 			var escaped bool
 			var inCharClass bool
@@ -710,13 +795,13 @@ var globalFunctions = map[string]Function{
 
 			pattern = result.String()
 
-			// 5. 获取或编译正则表达式
+			// 2. 获取或编译正则表达式
 			re, err := getCompiledRegex(pattern)
 			if err != nil {
 				return nil, fmt.Errorf("invalid regular expression: %v", err)
 			}
 
-			// 6. 搜索匹配的元素
+			// 3. 搜索匹配的元素
 			matches := make([]interface{}, 0)
 			for _, item := range arr {
 				var str string
@@ -748,19 +833,175 @@ var globalFunctions = map[string]Function{
 			return matches, nil
 		},
 	},
-	// RFC 9535 value() - extracts a single value from a nodelist
-	"value": &builtinFunction{
-		name: "value",
+	// Non-standard extension: startsWith(string, prefix)
+	"startsWith": &builtinFunction{
+		name:      "startsWith",
+		signature: &Signature{Params: []ArgType{ArgAny, ArgAny}},
+		callback: func(args []interface{}) (interface{}, error) {
+			return stringPredicate(args, strings.HasPrefix)
+		},
+	},
+	// Non-standard extension: endsWith(string, suffix)
+	"endsWith": &builtinFunction{
+		name:      "endsWith",
+		signature: &Signature{Params: []ArgType{ArgAny, ArgAny}},
 		callback: func(args []interface{}) (interface{}, error) {
-			if len(args) != 1 {
-				return nil, fmt.Errorf("value() requires exactly 1 argument")
+			return stringPredicate(args, strings.HasSuffix)
+		},
+	},
+	// Non-standard extension: contains(string, substring) or
+	// contains(array, element), like the contains operator
+	"contains": &builtinFunction{
+		name:      "contains",
+		signature: &Signature{Params: []ArgType{ArgAny, ArgAny}},
+		callback: func(args []interface{}) (interface{}, error) {
+			if _, ok := args[0].([]interface{}); ok {
+				return isMember(args[1], args[0]), nil
 			}
-
-			// 参数必须是数组（NodeList）
-			arr, ok := args[0].([]interface{})
-			if !ok {
-				return nil, fmt.Errorf("value() argument must be a nodelist")
+			return stringPredicate(args, strings.Contains)
+		},
+	},
+	// Non-standard extension: trim(), trimPrefix(prefix) and
+	// trimSuffix(suffix) - applied to a string or to each string in an array
+	"trim": &builtinFunction{
+		name:      "trim",
+		signature: &Signature{Params: []ArgType{ArgString | ArgArray}},
+		callback: func(args []interface{}) (interface{}, error) {
+			return mapStrings(args[0], strings.TrimSpace), nil
+		},
+	},
+	"trimPrefix": &builtinFunction{
+		name:      "trimPrefix",
+		signature: &Signature{Params: []ArgType{ArgString | ArgArray, ArgString}},
+		callback: func(args []interface{}) (interface{}, error) {
+			prefix := args[1].(string)
+			return mapStrings(args[0], func(s string) string { return strings.TrimPrefix(s, prefix) }), nil
+		},
+	},
+	"trimSuffix": &builtinFunction{
+		name:      "trimSuffix",
+		signature: &Signature{Params: []ArgType{ArgString | ArgArray, ArgString}},
+		callback: func(args []interface{}) (interface{}, error) {
+			suffix := args[1].(string)
+			return mapStrings(args[0], func(s string) string { return strings.TrimSuffix(s, suffix) }), nil
+		},
+	},
+	// Non-standard extension: split(string, separator) - splits a string
+	// into an array, or into characters when separator is empty
+	"split": &builtinFunction{
+		name:      "split",
+		signature: &Signature{Params: []ArgType{ArgString, ArgString}},
+		callback: func(args []interface{}) (interface{}, error) {
+			parts := strings.Split(args[0].(string), args[1].(string))
+			result := make([]interface{}, len(parts))
+			for i, part := range parts {
+				result[i] = part
+			}
+			return result, nil
+		},
+	},
+	// Non-standard extension: join(array, separator) - concatenates the
+	// scalars in an array, written as by to_string(), with null as ""
+	"join": &builtinFunction{
+		name:      "join",
+		signature: &Signature{Params: []ArgType{ArgArray, ArgString}},
+		callback: func(args []interface{}) (interface{}, error) {
+			arr := args[0].([]interface{})
+			parts := make([]string, len(arr))
+			for i, item := range arr {
+				if !argScalar.accepts(item) {
+					return nil, NewError(ErrInvalidArgument, "join() array elements must be scalars", "join")
+				}
+				if item != nil {
+					parts[i] = toJSONString(item).(string)
+				}
+			}
+			return strings.Join(parts, args[1].(string)), nil
+		},
+	},
+	// Non-standard extension: concat(value, ...) - concatenates its
+	// arguments into a string. As a segment with relative query arguments,
+	// such as $.users[*].concat(@.last, ', ', @.first), the node's value is
+	// only what the queries select from.
+	"concat": &builtinFunction{
+		name:      "concat",
+		signature: &Signature{Params: []ArgType{ArgAny}, Variadic: true},
+		callback: func(args []interface{}) (interface{}, error) {
+			parts := args
+			for _, arg := range args[1:] {
+				if _, ok := arg.(*boundQuery); ok {
+					parts = args[1:]
+					break
+				}
+			}
+			var b strings.Builder
+			for _, part := range parts {
+				values := []interface{}{part}
+				if q, ok := part.(*boundQuery); ok {
+					var err error
+					if values, err = q.values(args[0]); err != nil {
+						return nil, err
+					}
+				}
+				for _, v := range values {
+					if v != nil {
+						b.WriteString(toJSONString(v).(string))
+					}
+				}
+			}
+			return b.String(), nil
+		},
+	},
+	// Non-standard extension: default(value, fallback) - fallback when value
+	// is null or absent, and value otherwise
+	"default": &builtinFunction{
+		name:      "default",
+		signature: &Signature{Params: []ArgType{ArgAny, ArgAny}},
+		callback: func(args []interface{}) (interface{}, error) {
+			if isMissing(args[0]) {
+				return args[1], nil
+			}
+			return args[0], nil
+		},
+	},
+	// Non-standard extension: coalesce(...) - the first argument that is
+	// neither null nor absent, or nothing. Applied as a segment with
+	// relative queries among its arguments, the queries are evaluated
+	// against the node and take the place of its value, as for concat().
+	"coalesce": &builtinFunction{
+		name:      "coalesce",
+		signature: &Signature{Params: []ArgType{ArgAny}, Variadic: true},
+		callback: func(args []interface{}) (interface{}, error) {
+			candidates := args
+			for _, arg := range args[1:] {
+				if _, ok := arg.(*boundQuery); ok {
+					candidates = args[1:]
+					break
+				}
+			}
+			for _, arg := range candidates {
+				values := []interface{}{arg}
+				if q, ok := arg.(*boundQuery); ok {
+					var err error
+					if values, err = q.values(args[0]); err != nil {
+						return nil, err
+					}
+				}
+				for _, v := range values {
+					if !isMissing(v) {
+						return v, nil
+					}
+				}
 			}
+			return Nothing{}, nil
+		},
+	},
+	// RFC 9535 value() - extracts a single value from a nodelist
+	"value": &builtinFunction{
+		name:      "value",
+		signature: &Signature{Params: []ArgType{ArgArray}},
+		callback: func(args []interface{}) (interface{}, error) {
+			arr := args[0].([]interface{})
 
 			// 如果恰好有一个节点，返回其值
 			if len(arr) == 1 {
@@ -771,10 +1012,935 @@ var globalFunctions = map[string]Function{
 			return Nothing{}, nil
 		},
 	},
-}
+	// Non-standard extension: product() - multiplies the numbers in an
+	// array, returning an integer when sum() would
+	"product": &builtinFunction{
+		name:      "product",
+		signature: &Signature{Params: []ArgType{ArgArray}},
+		callback: func(args []interface{}) (interface{}, error) {
+			arr := args[0].([]interface{})
+			if len(arr) == 0 {
+				return nil, fmt.Errorf("product() cannot be applied to an empty array")
+			}
 
-// GetFunction returns a registered function by name
-func GetFunction(name string) (Function, error) {
+			product := 1.0
+			count := 0
+			allIntegers := true
+			for _, item := range arr {
+				num, err := convertToNumber(item)
+				if err != nil {
+					continue // 跳过无效的数值
+				}
+
+				// 跳过特殊值
+				if num.typ == numberTypeNaN ||
+					num.typ == numberTypeInfinity ||
+					num.typ == numberTypeNegativeInfinity {
+					continue
+				}
+
+				if num.typ == numberTypeFloat {
+					allIntegers = false
+				}
+
+				product *= num.value
+				count++
+			}
+
+			if count == 0 {
+				return nil, fmt.Errorf("product() no valid numbers in array")
+			}
+
+			if allIntegers && product == float64(int64(product)) {
+				return int64(product), nil
+			}
+			return product, nil
+		},
+	},
+	// Non-standard extension: abs(), ceil(), floor() and round(digits) - the
+	// math functions, applied to a number or to each number in an array
+	"abs":   mathFunction("abs", math.Abs),
+	"ceil":  mathFunction("ceil", math.Ceil),
+	"floor": mathFunction("floor", math.Floor),
+	"round": &builtinFunction{
+		name:      "round",
+		signature: &Signature{Params: []ArgType{ArgNumber | ArgArray, ArgNumber}, Optional: 1},
+		callback: func(args []interface{}) (interface{}, error) {
+			digits := 0.0
+			if len(args) == 2 {
+				digits = args[1].(float64)
+				if digits != math.Trunc(digits) {
+					return nil, NewError(ErrInvalidArgument, "round() digits must be an integer", "round")
+				}
+			}
+			scale := math.Pow(10, digits)
+			return mapNumbers("round", args[0], func(x float64) float64 {
+				return math.Round(x*scale) / scale
+			})
+		},
+	},
+	// Non-standard extension: to_number() - converts a value, or each value
+	// in an array, to a number
+	"to_number": &builtinFunction{
+		name:      "to_number",
+		signature: &Signature{Params: []ArgType{ArgAny}},
+		callback: func(args []interface{}) (interface{}, error) {
+			return mapValues(args[0], toNumber), nil
+		},
+	},
+	// Non-standard extension: to_string() - converts a value, or each value
+	// in an array, to a string
+	"to_string": &builtinFunction{
+		name:      "to_string",
+		signature: &Signature{Params: []ArgType{ArgAny}},
+		callback: func(args []interface{}) (interface{}, error) {
+			return mapValues(args[0], toJSONString), nil
+		},
+	},
+	// Non-standard extension: parse_date(value[, layout]) - the time of an
+	// RFC 3339 timestamp, or of a string in the format of time.Parse, as
+	// seconds since the Unix epoch. Numbers are taken to be epoch seconds.
+	"parse_date": &builtinFunction{
+		name:      "parse_date",
+		signature: &Signature{Params: []ArgType{ArgString | ArgNumber, ArgString}, Optional: 1},
+		callback: func(args []interface{}) (interface{}, error) {
+			layout := time.RFC3339
+			if len(args) == 2 {
+				layout = args[1].(string)
+			}
+			t, err := dateArg("parse_date", args[0], layout)
+			if err != nil {
+				return nil, err
+			}
+			return epochSeconds(t), nil
+		},
+	},
+	// Non-standard extension: format_date(date, layout) - a date, as accepted
+	// by parse_date(), written in UTC in the format of time.Time.Format
+	"format_date": &builtinFunction{
+		name:      "format_date",
+		signature: &Signature{Params: []ArgType{ArgString | ArgNumber, ArgString}},
+		callback: func(args []interface{}) (interface{}, error) {
+			t, err := dateArg("format_date", args[0], time.RFC3339)
+			if err != nil {
+				return nil, err
+			}
+			return t.UTC().Format(args[1].(string)), nil
+		},
+	},
+	// Non-standard extension: now() - the current time as seconds since the
+	// Unix epoch
+	"now": &builtinFunction{
+		name:      "now",
+		signature: &Signature{},
+		callback: func(args []interface{}) (interface{}, error) {
+			return epochSeconds(timeNow()), nil
+		},
+	},
+	// Non-standard extension: date_add(date, duration) - a date, as accepted
+	// by parse_date(), moved by a duration such as '-24h', '90m' or '7d', or
+	// by a number of seconds
+	"date_add": &builtinFunction{
+		name:      "date_add",
+		signature: &Signature{Params: []ArgType{ArgString | ArgNumber, ArgString | ArgNumber}},
+		callback: func(args []interface{}) (interface{}, error) {
+			t, err := dateArg("date_add", args[0], time.RFC3339)
+			if err != nil {
+				return nil, err
+			}
+			d, err := durationArg("date_add", args[1])
+			if err != nil {
+				return nil, err
+			}
+			return epochSeconds(t.Add(d)), nil
+		},
+	},
+	// Non-standard extension: median() - the middle value of the numbers in
+	// an array, or the mean of the two middle values
+	"median": &builtinFunction{
+		name:      "median",
+		signature: &Signature{Params: []ArgType{ArgArray}},
+		callback: func(args []interface{}) (interface{}, error) {
+			nums, err := finiteNumbers("median", args[0].([]interface{}))
+			if err != nil {
+				return nil, err
+			}
+			sort.Float64s(nums)
+			mid := len(nums) / 2
+			if len(nums)%2 == 1 {
+				return numberResult(nums[mid]), nil
+			}
+			return numberResult((nums[mid-1] + nums[mid]) / 2), nil
+		},
+	},
+	// Non-standard extension: variance(array[, kind]) - the population or
+	// sample variance of the numbers in an array
+	"variance": &builtinFunction{
+		name:      "variance",
+		signature: &Signature{Params: []ArgType{ArgArray, ArgString}, Optional: 1},
+		callback: func(args []interface{}) (interface{}, error) {
+			v, err := variance("variance", args)
+			if err != nil {
+				return nil, err
+			}
+			return numberResult(v), nil
+		},
+	},
+	// Non-standard extension: stddev(array[, kind]) - the population or
+	// sample standard deviation of the numbers in an array
+	"stddev": &builtinFunction{
+		name:      "stddev",
+		signature: &Signature{Params: []ArgType{ArgArray, ArgString}, Optional: 1},
+		callback: func(args []interface{}) (interface{}, error) {
+			v, err := variance("stddev", args)
+			if err != nil {
+				return nil, err
+			}
+			return numberResult(math.Sqrt(v)), nil
+		},
+	},
+	// Non-standard extension: percentile(array, p[, interpolation]) - the
+	// p-th percentile, 0 to 100, of the numbers in an array
+	"percentile": &builtinFunction{
+		name:      "percentile",
+		signature: &Signature{Params: []ArgType{ArgArray, ArgNumber, ArgString}, Optional: 1},
+		callback: func(args []interface{}) (interface{}, error) {
+			return quantile("percentile", args, 100)
+		},
+	},
+	// Non-standard extension: quantile(array, q[, interpolation]) - the
+	// q-quantile, 0 to 1, of the numbers in an array
+	"quantile": &builtinFunction{
+		name:      "quantile",
+		signature: &Signature{Params: []ArgType{ArgArray, ArgNumber, ArgString}, Optional: 1},
+		callback: func(args []interface{}) (interface{}, error) {
+			return quantile("quantile", args, 1)
+		},
+	},
+	// Non-standard extension: mode() - the most frequent values in an array,
+	// in the order they first appear
+	"mode": &builtinFunction{
+		name:      "mode",
+		signature: &Signature{Params: []ArgType{ArgArray}},
+		callback: func(args []interface{}) (interface{}, error) {
+			var values []interface{}
+			var counts []int
+			best := 0
+			for _, item := range args[0].([]interface{}) {
+				i := indexOfValue(values, item)
+				if i < 0 {
+					i = len(values)
+					values = append(values, item)
+					counts = append(counts, 0)
+				}
+				if counts[i]++; counts[i] > best {
+					best = counts[i]
+				}
+			}
+			modes := make([]interface{}, 0, 1)
+			for i, v := range values {
+				if counts[i] == best {
+					modes = append(modes, v)
+				}
+			}
+			return modes, nil
+		},
+	},
+	// Non-standard extension: sort() - returns a sorted copy of an array
+	// of scalars
+	"sort": &builtinFunction{
+		name:      "sort",
+		signature: &Signature{Params: []ArgType{ArgArray}},
+		callback: func(args []interface{}) (interface{}, error) {
+			arr := args[0].([]interface{})
+			for _, item := range arr {
+				if !argScalar.accepts(item) {
+					return nil, NewError(ErrInvalidArgument, "sort() array elements must be scalars", "sort")
+				}
+			}
+			sorted := append([]interface{}(nil), arr...)
+			sort.SliceStable(sorted, func(i, j int) bool {
+				return compareScalars(sorted[i], sorted[j]) < 0
+			})
+			return sorted, nil
+		},
+	},
+	// Non-standard extension: sort_by(array, key[, order]) - sorts an array
+	// of objects by a member name or a relative query such as @.price
+	"sort_by": &builtinFunction{
+		name:      "sort_by",
+		signature: &Signature{Params: []ArgType{ArgArray, ArgAny, ArgString}, Optional: 1},
+		callback: func(args []interface{}) (interface{}, error) {
+			arr := args[0].([]interface{})
+			descending := false
+			if len(args) == 3 {
+				switch args[2] {
+				case "asc":
+				case "desc":
+					descending = true
+				default:
+					return nil, NewError(ErrInvalidArgument, "sort_by() order must be 'asc' or 'desc'", "sort_by")
+				}
+			}
+			type keyed struct {
+				item interface{}
+				key  interface{}
+				ok   bool
+			}
+			items := make([]keyed, len(arr))
+			for i, item := range arr {
+				values, err := selectByKey("sort_by", args[1], item)
+				if err != nil {
+					return nil, err
+				}
+				ok := len(values) == 1 && argScalar.accepts(values[0])
+				items[i] = keyed{item: item, ok: ok}
+				if ok {
+					items[i].key = values[0]
+				}
+			}
+			// Items without a scalar key keep their order after the others
+			sort.SliceStable(items, func(i, j int) bool {
+				if !items[i].ok || !items[j].ok {
+					return items[i].ok && !items[j].ok
+				}
+				if descending {
+					return compareScalars(items[i].key, items[j].key) > 0
+				}
+				return compareScalars(items[i].key, items[j].key) < 0
+			})
+			sorted := make([]interface{}, len(items))
+			for i, it := range items {
+				sorted[i] = it.item
+			}
+			return sorted, nil
+		},
+	},
+	// Non-standard extension: unique() - removes duplicate values from an
+	// array, keeping the first of each
+	"unique": &builtinFunction{
+		name:      "unique",
+		signature: &Signature{Params: []ArgType{ArgArray}},
+		callback:  uniqueValues,
+	},
+	// Non-standard extension: distinct() - alias of unique()
+	"distinct": &builtinFunction{
+		name:      "distinct",
+		signature: &Signature{Params: []ArgType{ArgArray}},
+		callback:  uniqueValues,
+	},
+	// Non-standard extension: first() - the first element of an array
+	"first": &builtinFunction{
+		name:      "first",
+		signature: &Signature{Params: []ArgType{ArgArray}},
+		callback: func(args []interface{}) (interface{}, error) {
+			arr := args[0].([]interface{})
+			if len(arr) == 0 {
+				return Nothing{}, nil
+			}
+			return arr[0], nil
+		},
+	},
+	// Non-standard extension: last() - the last element of an array
+	"last": &builtinFunction{
+		name:      "last",
+		signature: &Signature{Params: []ArgType{ArgArray}},
+		callback: func(args []interface{}) (interface{}, error) {
+			arr := args[0].([]interface{})
+			if len(arr) == 0 {
+				return Nothing{}, nil
+			}
+			return arr[len(arr)-1], nil
+		},
+	},
+	// Non-standard extension: map(array, key[, misses]) - projects each
+	// element of an array through a member name or a relative query
+	"map": &builtinFunction{
+		name:      "map",
+		signature: &Signature{Params: []ArgType{ArgArray, ArgAny, ArgString}, Optional: 1},
+		callback: func(args []interface{}) (interface{}, error) {
+			arr := args[0].([]interface{})
+			fillNull := false
+			if len(args) == 3 {
+				switch args[2] {
+				case "drop":
+				case "null":
+					fillNull = true
+				default:
+					return nil, NewError(ErrInvalidArgument, "map() misses must be 'drop' or 'null'", "map")
+				}
+			}
+			result := make([]interface{}, 0, len(arr))
+			for _, item := range arr {
+				values, err := selectByKey("map", args[1], item)
+				if err != nil {
+					return nil, err
+				}
+				switch {
+				case len(values) == 1:
+					result = append(result, values[0])
+				case len(values) > 1:
+					result = append(result, values)
+				case fillNull:
+					result = append(result, nil)
+				}
+			}
+			return result, nil
+		},
+	},
+}
+
+// uniqueValues returns the array argument without the values equal, as by
+// ==, to an earlier one, so objects and arrays are compared deeply
+func uniqueValues(args []interface{}) (interface{}, error) {
+	result := make([]interface{}, 0, len(args[0].([]interface{})))
+	for _, item := range args[0].([]interface{}) {
+		if !isMember(item, result) {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// collectKeys adds the member names of every object in v to seen
+func collectKeys(v interface{}, seen map[string]bool) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, item := range x {
+			seen[k] = true
+			collectKeys(item, seen)
+		}
+	case []interface{}:
+		for _, item := range x {
+			collectKeys(item, seen)
+		}
+	}
+}
+
+// objectEntries returns the members of the object argument as
+// {"key": name, "value": value} objects ordered by name, as keys() orders
+// them
+func objectEntries(args []interface{}) (interface{}, error) {
+	obj := args[0].(map[string]interface{})
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := make([]interface{}, len(keys))
+	for i, k := range keys {
+		result[i] = map[string]interface{}{"key": k, "value": obj[k]}
+	}
+	return result, nil
+}
+
+// indexOfValue returns the index of the first of values equal to v, as by
+// ==, or -1
+func indexOfValue(values []interface{}, v interface{}) int {
+	for i, value := range values {
+		if equal, err := compareValues(v, "==", value); err == nil && equal {
+			return i
+		}
+	}
+	return -1
+}
+
+// selectByKey returns the values that key, the member name or relative
+// query passed to the function name, selects from item
+func selectByKey(name string, key interface{}, item interface{}) ([]interface{}, error) {
+	switch k := key.(type) {
+	case string:
+		if obj, ok := item.(map[string]interface{}); ok {
+			if value, exists := obj[k]; exists {
+				return []interface{}{value}, nil
+			}
+		}
+		return nil, nil
+	case *boundQuery:
+		return k.values(item)
+	default:
+		return nil, NewError(ErrInvalidArgument, name+"() second argument must be a member name or a relative query", name)
+	}
+}
+
+// mathFunction returns the function name applying fn to a number or to
+// each number in an array
+func mathFunction(name string, fn func(float64) float64) Function {
+	return &builtinFunction{
+		name:      name,
+		signature: &Signature{Params: []ArgType{ArgNumber | ArgArray}},
+		callback: func(args []interface{}) (interface{}, error) {
+			return mapNumbers(name, args[0], fn)
+		},
+	}
+}
+
+// mapNumbers applies fn to the number v, or to each number in the array v
+func mapNumbers(name string, v interface{}, fn func(float64) float64) (interface{}, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return fn(v.(float64)), nil
+	}
+	result := make([]interface{}, len(arr))
+	for i, item := range arr {
+		x, isNumber := numberArg(item)
+		if !isNumber {
+			return nil, NewError(ErrInvalidArgument, name+"() array elements must be numbers", name)
+		}
+		result[i] = fn(x)
+	}
+	return result, nil
+}
+
+// mapStrings applies fn to the string v, or to each string in the array
+// v, leaving other values unchanged
+func mapStrings(v interface{}, fn func(string) string) interface{} {
+	return mapValues(v, func(item interface{}) interface{} {
+		if s, ok := item.(string); ok {
+			return fn(s)
+		}
+		return item
+	})
+}
+
+// mapValues applies fn to v, or to each value in the array v
+func mapValues(v interface{}, fn func(interface{}) interface{}) interface{} {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return fn(v)
+	}
+	result := make([]interface{}, len(arr))
+	for i, item := range arr {
+		result[i] = fn(item)
+	}
+	return result
+}
+
+// toNumber converts a number, a string holding a decimal number or a
+// boolean to a number, and any other value to null
+func toNumber(v interface{}) interface{} {
+	switch x := v.(type) {
+	case string:
+		x = strings.TrimSpace(x)
+		f, err := strconv.ParseFloat(x, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) || strings.ContainsAny(x, "xX") {
+			return nil
+		}
+		return f
+	case bool:
+		if x {
+			return 1.0
+		}
+		return 0.0
+	}
+	if f, ok := numberArg(v); ok {
+		return f
+	}
+	return nil
+}
+
+// toJSONString returns a string unchanged and any other value as JSON text
+func toJSONString(v interface{}) interface{} {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	text, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return string(text)
+}
+
+// timeNow returns the current time for now(); tests replace it
+var timeNow = time.Now
+
+// dateArg returns the time of the date argument v of the function name:
+// a number of seconds since the Unix epoch or a string in layout
+func dateArg(name string, v interface{}, layout string) (time.Time, error) {
+	if s, ok := v.(string); ok {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return time.Time{}, NewError(ErrInvalidArgument, fmt.Sprintf("%s() cannot parse date %q", name, s), name)
+		}
+		return t, nil
+	}
+	secs := v.(float64)
+	if math.IsNaN(secs) || math.IsInf(secs, 0) {
+		return time.Time{}, NewError(ErrInvalidArgument, name+"() date must be finite", name)
+	}
+	whole, frac := math.Modf(secs)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+}
+
+// durationArg returns the duration argument v of the function name: a
+// number of seconds, or a string as accepted by time.ParseDuration or a
+// number of days such as "7d" or "-1.5d"
+func durationArg(name string, v interface{}) (time.Duration, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Duration(v.(float64) * float64(time.Second)), nil
+	}
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		if n, err := strconv.ParseFloat(days, 64); err == nil && !math.IsNaN(n) && !math.IsInf(n, 0) {
+			return time.Duration(n * 24 * float64(time.Hour)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, NewError(ErrInvalidArgument, fmt.Sprintf("%s() cannot parse duration %q", name, s), name)
+	}
+	return d, nil
+}
+
+// epochSeconds returns t as seconds since the Unix epoch, an int64 when
+// it falls on a whole second
+func epochSeconds(t time.Time) interface{} {
+	if t.Nanosecond() == 0 {
+		return t.Unix()
+	}
+	return float64(t.Unix()) + float64(t.Nanosecond())/1e9
+}
+
+// finiteNumbers returns the finite numbers in arr, skipping other values
+// as avg() and sum() do. It is an error for arr to hold no finite number.
+func finiteNumbers(name string, arr []interface{}) ([]float64, error) {
+	if len(arr) == 0 {
+		return nil, fmt.Errorf("%s() cannot be applied to an empty array", name)
+	}
+	nums := make([]float64, 0, len(arr))
+	for _, item := range arr {
+		num, err := convertToNumber(item)
+		if err != nil || num.typ == numberTypeNaN ||
+			num.typ == numberTypeInfinity || num.typ == numberTypeNegativeInfinity {
+			continue
+		}
+		nums = append(nums, num.value)
+	}
+	if len(nums) == 0 {
+		return nil, fmt.Errorf("%s() no valid numbers in array", name)
+	}
+	return nums, nil
+}
+
+// variance computes the variance of the numbers in the array args[0] for
+// the function name. args[1], if present, is "population" or "sample".
+func variance(name string, args []interface{}) (float64, error) {
+	sample := false
+	if len(args) == 2 {
+		switch args[1] {
+		case "population":
+		case "sample":
+			sample = true
+		default:
+			return 0, NewError(ErrInvalidArgument, name+"() kind must be 'population' or 'sample'", name)
+		}
+	}
+	nums, err := finiteNumbers(name, args[0].([]interface{}))
+	if err != nil {
+		return 0, err
+	}
+	n := float64(len(nums))
+	if sample {
+		if len(nums) < 2 {
+			return 0, fmt.Errorf("%s() of a sample requires at least 2 numbers", name)
+		}
+		n--
+	}
+	var mean float64
+	for _, x := range nums {
+		mean += x
+	}
+	mean /= float64(len(nums))
+	var sum float64
+	for _, x := range nums {
+		sum += (x - mean) * (x - mean)
+	}
+	return sum / n, nil
+}
+
+// quantile computes the quantile args[1] / scale of the numbers in the
+// array args[0] for the function name. Between two numbers it
+// interpolates by args[2], as numpy does: "linear" (the default), "lower",
+// "higher", "nearest" or "midpoint".
+func quantile(name string, args []interface{}, scale float64) (interface{}, error) {
+	q := args[1].(float64) / scale
+	if math.IsNaN(q) || q < 0 || q > 1 {
+		return nil, NewError(ErrInvalidArgument, fmt.Sprintf("%s() requires a value between 0 and %v", name, scale), name)
+	}
+	interpolation := "linear"
+	if len(args) == 3 {
+		interpolation = args[2].(string)
+	}
+	nums, err := finiteNumbers(name, args[0].([]interface{}))
+	if err != nil {
+		return nil, err
+	}
+	sort.Float64s(nums)
+	pos := q * float64(len(nums)-1)
+	lo, hi := nums[int(math.Floor(pos))], nums[int(math.Ceil(pos))]
+	var result float64
+	switch interpolation {
+	case "linear":
+		result = lo + (hi-lo)*(pos-math.Floor(pos))
+	case "lower":
+		result = lo
+	case "higher":
+		result = hi
+	case "nearest":
+		result = nums[int(math.RoundToEven(pos))]
+	case "midpoint":
+		result = (lo + hi) / 2
+	default:
+		return nil, NewError(ErrInvalidArgument, fmt.Sprintf("%s() interpolation must be 'linear', 'lower', 'higher', 'nearest' or 'midpoint'", name), name)
+	}
+	return numberResult(result), nil
+}
+
+// numberResult returns f as an int64 when it is a whole number, as avg()
+// does
+func numberResult(f float64) interface{} {
+	if f == float64(int64(f)) {
+		return int64(f)
+	}
+	return f
+}
+
+// compareScalars orders JSON scalars: null first, then false and true,
+// then numbers by value and strings by code point
+func compareScalars(a, b interface{}) int {
+	if ra, rb := scalarRank(a), scalarRank(b); ra != rb {
+		return ra - rb
+	}
+	switch x := a.(type) {
+	case string:
+		return strings.Compare(x, b.(string))
+	case bool:
+		if x == b.(bool) {
+			return 0
+		}
+		if x {
+			return 1
+		}
+		return -1
+	case nil:
+		return 0
+	}
+	na, _ := numberArg(a)
+	nb, _ := numberArg(b)
+	switch {
+	case na < nb:
+		return -1
+	case na > nb:
+		return 1
+	}
+	return 0
+}
+
+// scalarRank is the position of the type of v in the order of
+// compareScalars
+func scalarRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case string:
+		return 3
+	}
+	return 2
+}
+
+// stringPredicate applies test to two string arguments. It is
+// false unless both arguments are strings.
+func stringPredicate(args []interface{}, test func(s, sub string) bool) (interface{}, error) {
+	str, ok1 := args[0].(string)
+	sub, ok2 := args[1].(string)
+	return ok1 && ok2 && test(str, sub), nil
+}
+
+// fallbackFunctions replace missing values. Applied as a segment, such as
+// $.config.timeout.default(30), a fallback function is also called for
+// the members and elements missing along the names and indices before it,
+// and in a filter it is called with the arguments that select nothing.
+var fallbackFunctions = map[string]bool{
+	"default":  true,
+	"coalesce": true,
+}
+
+// isMissing reports whether v is null or absent
+func isMissing(v interface{}) bool {
+	if _, ok := v.(Nothing); ok {
+		return true
+	}
+	return v == nil
+}
+
+// aggregateFunctions reduce an array to a summary. Applied as a segment
+// after a query that may select several nodes, such as
+// $.book[*].price.sum(), an aggregate receives the values of all those
+// nodes as one array. Other functions are applied to each node.
+var aggregateFunctions = map[string]bool{
+	"min":         true,
+	"max":         true,
+	"avg":         true,
+	"sum":         true,
+	"occurrences": true,
+	"product":     true,
+	"median":      true,
+	"variance":    true,
+	"stddev":      true,
+	"mode":        true,
+	"percentile":  true,
+	"quantile":    true,
+}
+
+// Registry is a set of functions kept apart from the global ones, so that
+// queries can be given different functions without changing global state.
+// A Registry passed to a query with WithFunctions takes precedence over
+// the functions added by RegisterFunction and the built-in ones. It is
+// safe for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	funcs map[string]Function
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{funcs: map[string]Function{}}
+}
+
+// Register adds fn to r under name. Like RegisterFunction, it is an error
+// to register a name that is already registered in r or built in.
+func (r *Registry) Register(name string, fn Function) error {
+	if err := checkFunctionName(name, fn); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, builtin := globalFunctions[name]
+	if _, exists := r.funcs[name]; exists || builtin {
+		return NewError(ErrInvalidFunction, fmt.Sprintf("function %s is already defined", name), name)
+	}
+	r.funcs[name] = fn
+	return nil
+}
+
+// Override adds fn to r under name, replacing a function of that name
+func (r *Registry) Override(name string, fn Function) error {
+	if err := checkFunctionName(name, fn); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.funcs[name] = fn
+	return nil
+}
+
+// Unregister removes a function added to r
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.funcs, name)
+}
+
+// Lookup returns the function added to r under name
+func (r *Registry) Lookup(name string) (Function, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fn, ok := r.funcs[name]
+	return fn, ok
+}
+
+// userFunctions holds the functions added by RegisterFunction and
+// OverrideFunction, which take precedence over the built-in ones
+var userFunctions = NewRegistry()
+
+// NewFunction returns a Function with the given name that calls fn
+func NewFunction(name string, fn func(args []interface{}) (interface{}, error)) Function {
+	return &builtinFunction{name: name, callback: fn}
+}
+
+// NewTypedFunction returns a Function like NewFunction whose arguments are
+// checked against sig before fn is called, so fn may rely on their number
+// and types
+func NewTypedFunction(name string, sig Signature, fn func(args []interface{}) (interface{}, error)) Function {
+	return &builtinFunction{name: name, signature: &sig, callback: fn}
+}
+
+// RegisterFunction adds a function that expressions can call by name,
+// e.g. geoDistance(@.from, @.to) in a filter or $.points.geoDistance() as a
+// segment. name consists of ASCII letters, digits and underscores and
+// starts with a letter or underscore. It may be prefixed with a namespace
+// of the same form, as in str:upper, to keep a library of functions apart
+// from the built-in ones. It is an error to register a name that is
+// already registered or built in; see OverrideFunction.
+func RegisterFunction(name string, fn Function) error {
+	return userFunctions.Register(name, fn)
+}
+
+// OverrideFunction registers fn under name, replacing a built-in or
+// registered function of that name. The argument checks of the RFC 9535
+// functions, such as count() requiring a query, still apply.
+func OverrideFunction(name string, fn Function) error {
+	return userFunctions.Override(name, fn)
+}
+
+// UnregisterFunction removes a function added by RegisterFunction or
+// OverrideFunction, restoring the built-in function it replaced, if any
+func UnregisterFunction(name string) {
+	userFunctions.Unregister(name)
+}
+
+// checkFunctionName checks the name and function given to a Registry. The
+// name must be a function name, optionally namespaced, other than true,
+// false and null. index(), position() and key() are evaluated by filters
+// themselves and cannot be replaced.
+func checkFunctionName(name string, fn Function) error {
+	if fn == nil {
+		return NewError(ErrInvalidFunction, "function is nil", name)
+	}
+	if !isValidNamespacedName(name) || name == "true" || name == "false" || name == "null" {
+		return NewError(ErrInvalidFunction, fmt.Sprintf("invalid function name: %q", name), name)
+	}
+	if _, ok := indexFunctions[name]; ok || name == "key" {
+		return NewError(ErrInvalidFunction, fmt.Sprintf("function %s cannot be replaced", name), name)
+	}
+	return nil
+}
+
+// isValidNamespacedName reports whether name is a valid function name,
+// optionally prefixed with a namespace such as str:
+func isValidNamespacedName(name string) bool {
+	if ns, local, found := strings.Cut(name, ":"); found {
+		return isValidFunctionName(ns) && isValidFunctionName(local)
+	}
+	return isValidFunctionName(name)
+}
+
+// isValidFunctionName 检查是否是有效的函数名
+func isValidFunctionName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if i == 0 {
+			if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_') {
+				return false
+			}
+		} else {
+			if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// GetFunction returns a registered function by name
+func GetFunction(name string) (Function, error) {
+	if f, exists := userFunctions.Lookup(name); exists {
+		return f, nil
+	}
 	if f, exists := globalFunctions[name]; exists {
 		return f, nil
 	}
@@ -1,8 +1,10 @@
 package jsonpath
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
+	"strings"
 	"testing"
 )
 
@@ -248,3 +250,259 @@ func TestLengthUnicode(t *testing.T) {
 		})
 	}
 }
+
+func TestRegisterFunction(t *testing.T) {
+	distance := NewFunction("distance", func(args []interface{}) (interface{}, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("distance() requires exactly 2 arguments")
+		}
+		a, ok1 := args[0].(float64)
+		b, ok2 := args[1].(float64)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("distance() arguments must be numbers")
+		}
+		return math.Abs(a - b), nil
+	})
+	if err := RegisterFunction("distance", distance); err != nil {
+		t.Fatalf("RegisterFunction() error = %v", err)
+	}
+	defer UnregisterFunction("distance")
+
+	data := `{"points": [1, 5, 12], "origin": 4}`
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.points[?distance(@, $.origin) <= 1]`, []interface{}{5.0}},
+		{`$.points[?distance(@, 10) > 5]`, []interface{}{1.0}},
+		{`$.origin.distance(10)`, []interface{}{6.0}},
+		{`distance($.origin, 1)`, []interface{}{3.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	UnregisterFunction("distance")
+	if _, err := Query(data, `$.points[?distance(@, 10) > 5]`); err == nil {
+		t.Error("Query() after UnregisterFunction succeeded")
+	}
+}
+
+func TestOverrideFunction(t *testing.T) {
+	length := NewFunction("length", func(args []interface{}) (interface{}, error) {
+		return 42.0, nil
+	})
+	if err := RegisterFunction("length", length); err == nil {
+		t.Fatal("RegisterFunction() replaced a built-in function")
+	}
+	if err := OverrideFunction("length", length); err != nil {
+		t.Fatalf("OverrideFunction() error = %v", err)
+	}
+	result, err := Query(`{"a": "abc"}`, `$.a.length()`)
+	UnregisterFunction("length")
+	if err != nil || len(result) != 1 || result[0].Value != 42.0 {
+		t.Errorf("overridden length() = %v, %v, want 42", result, err)
+	}
+	result, err = Query(`{"a": "abc"}`, `$.a.length()`)
+	if err != nil || len(result) != 1 || result[0].Value != 3.0 {
+		t.Errorf("restored length() = %v, %v, want 3", result, err)
+	}
+}
+
+func TestRegisterFunctionErrors(t *testing.T) {
+	fn := NewFunction("f", func(args []interface{}) (interface{}, error) { return nil, nil })
+	if err := RegisterFunction("twice", fn); err != nil {
+		t.Fatalf("RegisterFunction() error = %v", err)
+	}
+	defer UnregisterFunction("twice")
+
+	tests := []struct {
+		name string
+		fn   Function
+	}{
+		{"twice", fn},    // already registered
+		{"count", fn},    // built in
+		{"key", fn},      // evaluated by filters
+		{"position", fn}, // evaluated by filters
+		{"true", fn},     // literal
+		{"2x", fn},       // starts with a digit
+		{"geo-dist", fn}, // invalid character
+		{"", fn},         // empty
+		{"missing", nil}, // nil function
+	}
+	for _, tt := range tests {
+		err := RegisterFunction(tt.name, tt.fn)
+		if jpErr, ok := err.(*Error); !ok || jpErr.Type != ErrInvalidFunction {
+			t.Errorf("RegisterFunction(%q) error = %v, want ErrInvalidFunction", tt.name, err)
+		}
+	}
+	if err := OverrideFunction("key", fn); err == nil {
+		t.Error("OverrideFunction(\"key\") succeeded")
+	}
+}
+
+func TestFunctionSignatures(t *testing.T) {
+	data := `{"n": 5, "s": "abc", "a": [1, 2], "o": {"k": 1}}`
+	tests := []struct {
+		path    string
+		wantErr string
+	}{
+		{`$.n.length()`, "length() argument must be a string, array or object"},
+		{`$.s.keys()`, "keys() argument must be an object"},
+		{`$.o.sum()`, "sum() argument must be an array"},
+		{`$.a.min(1)`, "min() requires exactly 1 argument"},
+		{`$.a.occurrences()`, "occurrences() requires exactly 2 arguments"},
+		{`$.n.filterMatch('x')`, "filterMatch() first argument must be an array"},
+		{`$.a.filterMatch(1)`, "filterMatch() second argument must be a string"},
+		{`$.s.search(1)`, "search() second argument must be a string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			_, err := Query(data, tt.path)
+			jpErr, ok := err.(*Error)
+			if !ok || jpErr.Type != ErrInvalidArgument {
+				t.Fatalf("Query(%q) error = %v, want ErrInvalidArgument", tt.path, err)
+			}
+			if jpErr.Message != tt.wantErr {
+				t.Errorf("Query(%q) error = %q, want %q", tt.path, jpErr.Message, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewTypedFunction(t *testing.T) {
+	var got []interface{}
+	fn := NewTypedFunction("clamp", Signature{
+		Params:   []ArgType{ArgNumber, ArgNumber, ArgNumber},
+		Optional: 1,
+	}, func(args []interface{}) (interface{}, error) {
+		got = args
+		return nil, nil
+	})
+
+	if _, err := fn.Call([]interface{}{int64(3), json.Number("1.5")}); err != nil {
+		t.Fatalf("Call() error = %v", err)
+	}
+	if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", []interface{}{3.0, 1.5}) {
+		t.Errorf("Call() passed %#v, want numbers converted to float64", got)
+	}
+
+	tests := []struct {
+		args    []interface{}
+		wantErr string
+	}{
+		{[]interface{}{1.0}, "clamp() requires 2 to 3 arguments"},
+		{[]interface{}{1.0, 2.0, 3.0, 4.0}, "clamp() requires 2 to 3 arguments"},
+		{[]interface{}{1.0, "2"}, "clamp() second argument must be a number"},
+		{[]interface{}{1.0, 2.0, nil}, "clamp() third argument must be a number"},
+	}
+	for _, tt := range tests {
+		_, err := fn.Call(tt.args)
+		if jpErr, ok := err.(*Error); !ok || jpErr.Type != ErrInvalidArgument || jpErr.Message != tt.wantErr {
+			t.Errorf("Call(%v) error = %v, want %q", tt.args, err, tt.wantErr)
+		}
+	}
+
+	variadic := NewTypedFunction("all", Signature{Params: []ArgType{ArgBool}, Variadic: true},
+		func(args []interface{}) (interface{}, error) { return len(args), nil })
+	if n, err := variadic.Call([]interface{}{true, false, true}); err != nil || n != 3 {
+		t.Errorf("variadic Call() = %v, %v, want 3", n, err)
+	}
+	if _, err := variadic.Call(nil); err == nil || err.Error() != "all() requires at least 1 argument" {
+		t.Errorf("variadic Call() with no arguments error = %v", err)
+	}
+}
+
+func TestNamespacedFunctions(t *testing.T) {
+	upper := NewTypedFunction("str:upper", Signature{Params: []ArgType{ArgString}},
+		func(args []interface{}) (interface{}, error) {
+			return strings.ToUpper(args[0].(string)), nil
+		})
+	length := NewFunction("str:length", func(args []interface{}) (interface{}, error) {
+		return -1.0, nil
+	})
+	for name, fn := range map[string]Function{"str:upper": upper, "str:length": length} {
+		if err := RegisterFunction(name, fn); err != nil {
+			t.Fatalf("RegisterFunction(%q) error = %v", name, err)
+		}
+		defer UnregisterFunction(name)
+	}
+
+	data := `{"users": [{"name": "ann"}, {"name": "Bob"}]}`
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.users[0].name.str:upper()`, []interface{}{"ANN"}},
+		{`$.users[?str:upper(@.name) == 'BOB'].name`, []interface{}{"Bob"}},
+		{`str:upper($.users[1].name)`, []interface{}{"BOB"}},
+		// The namespace keeps str:length() apart from the built-in length()
+		{`$.users[0].name.str:length()`, []interface{}{-1.0}},
+		{`$.users[0].name.length()`, []interface{}{3.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{
+		`$.users[0].name.math:upper()`, // unknown namespace
+		`$.users[0].name.str :upper()`, // whitespace inside the name
+		`$.users[0].str:upper`,         // not a call
+	} {
+		if _, err := Query(data, path); err == nil {
+			t.Errorf("Query(%q) succeeded, want an error", path)
+		}
+	}
+	for _, name := range []string{"str:", ":upper", "str:up:per", "1str:upper"} {
+		if err := RegisterFunction(name, upper); err == nil {
+			UnregisterFunction(name)
+			t.Errorf("RegisterFunction(%q) succeeded, want an error", name)
+		}
+	}
+}
+
+func TestProductKeepsIntegers(t *testing.T) {
+	product := globalFunctions["product"]
+	tests := []struct {
+		args []interface{}
+		want interface{}
+	}{
+		{[]interface{}{2, int64(3), 4.0}, int64(24)},
+		{[]interface{}{1.5, 2}, 3.0},
+		{[]interface{}{0.5, 0.5}, 0.25},
+		{[]interface{}{-2, "x", 5}, int64(-10)},
+	}
+	for _, tt := range tests {
+		got, err := product.Call([]interface{}{tt.args})
+		if err != nil {
+			t.Fatalf("product(%v) error = %v", tt.args, err)
+		}
+		if got != tt.want {
+			t.Errorf("product(%v) = %#v, want %#v", tt.args, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package jsonpath
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -30,6 +31,21 @@ func IRegexpToGoRegexp(pattern string) (string, error) {
 	return parser.Parse()
 }
 
+// compileIRegexp compiles an I-Regexp pattern. With full set the pattern
+// must match the whole string, as for match(); otherwise any substring, as
+// for search(). ^ and $ outside character classes are kept as anchors, as
+// the RFC 9535 compliance test suite expects.
+func compileIRegexp(pattern string, full bool) (*regexp.Regexp, error) {
+	goPattern, err := IRegexpToGoRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if full {
+		goPattern = `\A(?:` + goPattern + `)\z`
+	}
+	return getCompiledRegex(goPattern)
+}
+
 // Parse parses the I-Regexp pattern and returns the equivalent Go regexp.
 func (p *IRegexpParser) Parse() (string, error) {
 	if len(p.pattern) == 0 {
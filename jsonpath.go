@@ -1,47 +1,40 @@
 package jsonpath
 
 import (
-	"encoding/json"
 	"fmt"
 )
 
 // Query executes a JSONPath query on JSON data and returns a NodeList.
 // Each Node contains a Location (Normalized Path) and the corresponding Value.
-func Query(data interface{}, path string) (NodeList, error) {
+// Options may be supplied to adjust evaluation, e.g. WithCaseInsensitiveNames.
+//
+// Query parses path on every call; use Compile to parse once and execute
+// many times.
+func Query(data interface{}, path string, opts ...Option) (NodeList, error) {
 	// If data is a string, parse it as JSON
-	if jsonStr, ok := data.(string); ok {
-		var parsedData interface{}
-		if err := json.Unmarshal([]byte(jsonStr), &parsedData); err != nil {
-			return nil, fmt.Errorf("invalid JSON: %v", err)
-		}
-		data = parsedData
+	data, err := decodeDocument(data)
+	if err != nil {
+		return nil, err
 	}
 
 	// Parse path into segments
-	segments, err := parse(path)
+	c, err := Compile(path, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("invalid path: %v", err)
 	}
 
-	// Convert to v3 segments
-	v3Segments := wrapSegments(segments)
-
-	// Build root node
-	root := Node{Location: "$", Value: data, Root: data}
-
 	// Evaluate segments using v3 pipeline
-	nodeList := NodeList{root}
-	for _, seg := range v3Segments {
-		var newNodeList NodeList
-		for _, n := range nodeList {
-			evaluated, err := seg.evaluate(n)
-			if err != nil {
-				return nil, err
-			}
-			newNodeList = append(newNodeList, evaluated...)
-		}
-		nodeList = newNodeList
-	}
+	return c.execute(data, nil)
+}
 
-	return nodeList, nil
+// QueryValue executes a JSONPath query and returns the value of the first
+// node selected. found reports whether any node was selected, which tells
+// a match of a JSON null (nil, true) apart from no match at all (nil, false).
+func QueryValue(data interface{}, path string, opts ...Option) (value interface{}, found bool, err error) {
+	result, err := Query(data, path, opts...)
+	if err != nil {
+		return nil, false, err
+	}
+	value, found = result.First()
+	return value, found, nil
 }
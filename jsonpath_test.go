@@ -0,0 +1,2474 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryValue(t *testing.T) {
+	data := `{"a": null, "b": 1, "c": [null, 2]}`
+
+	tests := []struct {
+		path      string
+		wantValue interface{}
+		wantFound bool
+		wantErr   bool
+	}{
+		{path: "$.a", wantValue: nil, wantFound: true},
+		{path: "$.missing", wantValue: nil, wantFound: false},
+		{path: "$.b", wantValue: float64(1), wantFound: true},
+		{path: "$.c[*]", wantValue: nil, wantFound: true},
+		{path: "$.c[5]", wantValue: nil, wantFound: false},
+		{path: "$[", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			value, found, err := QueryValue(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("QueryValue(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if found != tt.wantFound {
+				t.Errorf("QueryValue(%q) found = %v, want %v", tt.path, found, tt.wantFound)
+			}
+			if !reflect.DeepEqual(value, tt.wantValue) {
+				t.Errorf("QueryValue(%q) value = %v, want %v", tt.path, value, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestCompiledExecuteValue(t *testing.T) {
+	c := MustCompile("$.a")
+
+	value, found, err := c.ExecuteValue(`{"a": null}`)
+	if err != nil || !found || value != nil {
+		t.Errorf("ExecuteValue() = %v, %v, %v, want nil, true, nil", value, found, err)
+	}
+
+	value, found, err = c.ExecuteValue(`{"b": 1}`)
+	if err != nil || found || value != nil {
+		t.Errorf("ExecuteValue() = %v, %v, %v, want nil, false, nil", value, found, err)
+	}
+
+	if _, _, err := c.ExecuteValue(`{`); err == nil {
+		t.Error("ExecuteValue() with invalid JSON expected error")
+	}
+}
+
+// queryValues returns the values selected by path, failing the test on error
+func queryValues(t *testing.T, data interface{}, path string) []interface{} {
+	t.Helper()
+	result, err := Query(data, path)
+	if err != nil {
+		t.Fatalf("Query(%q) error = %v", path, err)
+	}
+	var values []interface{}
+	for _, n := range result {
+		values = append(values, n.Value)
+	}
+	return values
+}
+
+func TestDoubleQuotedNames(t *testing.T) {
+	data := `{
+		"store": {"book name": 1, "it's": 2, "nested": {"book name": 3}},
+		"items": [{"book name": "x"}, {"book name": "y"}]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$["store"]["book name"]`, []interface{}{float64(1)}},
+		{`$["store"]['book name']`, []interface{}{float64(1)}},
+		{`$.store["it's"]`, []interface{}{float64(2)}},
+		{`$.store[ "book name" ]`, []interface{}{float64(1)}},
+		{`$.store["book name","it's"]`, []interface{}{float64(1), float64(2)}},
+		{`$.store..["book name"]`, []interface{}{float64(1), float64(3)}},
+		{`$.items[?@["book name"] == "y"]["book name"]`, []interface{}{"y"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapedNames(t *testing.T) {
+	data := map[string]interface{}{
+		"it's": 1, `say "hi"`: 2, `back\slash`: 3, "line\nbreak": 4,
+		"tab\tx": 5, "😀": 6, "é": 7, "a/b": 8, "\b\f\r": 9,
+	}
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{path: `$['it\'s']`, want: []interface{}{1}},
+		{path: `$["say \"hi\""]`, want: []interface{}{2}},
+		{path: `$['back\\slash']`, want: []interface{}{3}},
+		{path: `$['line\nbreak']`, want: []interface{}{4}},
+		{path: `$["tab\tx"]`, want: []interface{}{5}},
+		{path: `$['😀']`, want: []interface{}{6}},
+		{path: `$["\ud83d\ude00"]`, want: []interface{}{6}},
+		{path: `$['\u00e9']`, want: []interface{}{7}},
+		{path: `$['\u00E9']`, want: []interface{}{7}},
+		{path: `$['a\/b']`, want: []interface{}{8}},
+		{path: `$['\b\f\r']`, want: []interface{}{9}},
+		{path: `$['it\'s','😀']`, want: []interface{}{1, 6}},
+		{path: `$['\x']`, wantErr: true},
+		{path: `$['\ud83d']`, wantErr: true},
+		{path: `$['\u00g9']`, wantErr: true},
+		{path: `$["it\'s"]`, wantErr: true},
+		{path: `$['trailing\']`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+			// The Normalized Path of the match selects the same node
+			again := queryValues(t, data, result[0].Location)
+			if !reflect.DeepEqual(again, tt.want[:1]) {
+				t.Errorf("Query(%q) = %v, want %v", result[0].Location, again, tt.want[:1])
+			}
+		})
+	}
+}
+
+func TestQuotedNamesWithDelimiters(t *testing.T) {
+	data := `{
+		"a.b c[0]": {"x]": [1, 2]},
+		"a": {"b c[0]": 3},
+		"k,v": 4,
+		"$": 5,
+		"items": [{"first.name": "Ann"}, {"first.name": "Bob"}]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$['a.b c[0]']['x]'][1]`, []interface{}{float64(2)}},
+		{`$["a.b c[0]"]["x]"][0]`, []interface{}{float64(1)}},
+		{`$.a['b c[0]']`, []interface{}{float64(3)}},
+		{`$['k,v','$']`, []interface{}{float64(4), float64(5)}},
+		{`$..['x]'][0]`, []interface{}{float64(1)}},
+		{`$.items[?@['first.name'] == 'Bob']['first.name']`, []interface{}{"Bob"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+			// The Normalized Path of the match selects the same node
+			again := queryValues(t, data, result[0].Location)
+			if !reflect.DeepEqual(again, tt.want[:1]) {
+				t.Errorf("Query(%q) = %v, want %v", result[0].Location, again, tt.want[:1])
+			}
+		})
+	}
+}
+
+func TestUnicodeShorthandNames(t *testing.T) {
+	data := `{"用户": {"名字": "张三", "年龄": 30}, "café": 1, "😀x": 2, "a_é1": 3}`
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{path: "$.用户.名字", want: []interface{}{"张三"}},
+		{path: "$..名字", want: []interface{}{"张三"}},
+		{path: "$.café", want: []interface{}{float64(1)}},
+		{path: "$.😀x", want: []interface{}{float64(2)}},
+		{path: "$.a_é1", want: []interface{}{float64(3)}},
+		{path: "$[?@.名字 == '张三'].年龄", want: []interface{}{float64(30)}},
+		{path: "$.\xff", wantErr: true},
+		{path: "$.a\xffb", wantErr: true},
+		{path: "$['\xff']", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhitespaceTolerance(t *testing.T) {
+	data := `{"a": [{"b": 1, "s": "xy"}, {"b": 2, "s": "z"}], "n": [0, 1, 2, 3, 4]}`
+
+	tests := []struct {
+		path    string
+		want    int
+		wantErr bool
+	}{
+		{path: "$[ 'a' ][ 0 , 1 ].b", want: 2},
+		{path: "$.a[? @.b == 1 ]", want: 1},
+		{path: "$.n[ 1 : 4 : 2 ]", want: 2},
+		{path: "$.a[?( @.b > 1 )]", want: 1},
+		{path: "$.a[? length( @.s ) == 2 ]", want: 1},
+		{path: "$.a[?\n@.b\t==\r1]", want: 1},
+		{path: "$.a[?@.b==1&&@.s=='xy']", want: 1},
+		{path: "$.a[? !( @.b == 1 ) ]", want: 1},
+		{path: "$.a[?match( @.s , 'x.*' )]", want: 1},
+		{path: "$ .a [0]", want: 1},
+		{path: "$.a[?@ .b == 2]", want: 1},
+		{path: "$. a", wantErr: true},
+		{path: "$.. a", wantErr: true},
+		{path: " $.a", wantErr: true},
+		{path: "$.a ", wantErr: true},
+		{path: "$.a[?@.b = = 1]", wantErr: true},
+		{path: "$.a[?length (@.s) == 2]", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if len(result) != tt.want {
+				t.Errorf("Query(%q) returned %d nodes, want %d", tt.path, len(result), tt.want)
+			}
+		})
+	}
+}
+
+func TestMembersNamedLikeFunctions(t *testing.T) {
+	data := `{
+		"length": [{"a": 1}, {"a": 2}],
+		"keys": {"x": 1},
+		"o": {"length": 5, "length()": 6}
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{"$.length[1].a", []interface{}{float64(2)}},
+		{"$['length'][?@.a > 1].a", []interface{}{float64(2)}},
+		{"$.keys.x", []interface{}{float64(1)}},
+		{"$.o.length", []interface{}{float64(5)}},
+		{"$.o['length()']", []interface{}{float64(6)}},
+		{"$.o.length()", []interface{}{float64(2)}},
+		{"$.length.length()", []interface{}{float64(2)}},
+		{"$[?@.length == 5].length", []interface{}{float64(5)}},
+		{"$..length", []interface{}{[]interface{}{map[string]interface{}{"a": float64(1)}, map[string]interface{}{"a": float64(2)}}, float64(5)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	// A quoted name is never a function call, so it round-trips and is singular
+	c := MustCompile("$.o['length()']")
+	if got, want := c.String(), "$['o']['length()']"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if !c.IsSingular() {
+		t.Error("IsSingular() = false, want true")
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	data := `{"a": [{"v": 0.001}, {"v": 1000}, {"v": -0.5}, {"v": 150}], "b": [1, 1000, 1]}`
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr string
+	}{
+		{path: "$.a[?@.v == 1e-3].v", want: []interface{}{0.001}},
+		{path: "$.a[?@.v == 1E3].v", want: []interface{}{float64(1000)}},
+		{path: "$.a[?@.v == 1.5e+2].v", want: []interface{}{float64(150)}},
+		{path: "$.a[?@.v == -5E-1].v", want: []interface{}{-0.5}},
+		{path: "$.a[?@.v < -0].v", want: []interface{}{-0.5}},
+		{path: "$.a[?@.v > 0.1e1].v", want: []interface{}{float64(1000), float64(150)}},
+		{path: "$.b.occurrences(1e3)", want: []interface{}{float64(1)}},
+		{path: "occurrences($.b, 1E0)", want: []interface{}{float64(2)}},
+		{path: "$.a[?@.v == 01]", wantErr: "invalid number: 01"},
+		{path: "$.a[?@.v == 1.]", wantErr: "invalid number: 1."},
+		{path: "$.a[?@.v == 1e]", wantErr: "invalid number: 1e"},
+		{path: "$.a[?@.v == 1.2.3]", wantErr: "invalid number: 1.2.3"},
+		{path: "$.a[?@.v == -]", wantErr: "invalid number: -"},
+		{path: "$.a[?@.v == 1e400]", wantErr: "number out of range: 1e400"},
+		{path: "$.a[?@.v == +1]", wantErr: "unexpected \"+\""},
+		{path: "$.b.occurrences(1e)", wantErr: "invalid number: 1e"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Query(%q) error = %v, want %q", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterStringEscapes(t *testing.T) {
+	data := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "René", "note": "line\nbreak", "path": `a\b`, "emoji": "😀"},
+			map[string]interface{}{"name": "Rene", "note": "line break", "path": "a/b", "emoji": "x"},
+		},
+	}
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{path: `$.items[?@.name == "Ren\u00e9"].name`, want: []interface{}{"René"}},
+		{path: `$.items[?@.name == 'Ren\u00C9'].name`},
+		{path: `$.items[?@.note == 'line\nbreak'].name`, want: []interface{}{"René"}},
+		{path: `$.items[?@.note == "line\u000abreak"].name`, want: []interface{}{"René"}},
+		{path: `$.items[?@.path == 'a\\b'].name`, want: []interface{}{"René"}},
+		{path: `$.items[?@.path == 'a\/b'].name`, want: []interface{}{"Rene"}},
+		{path: `$.items[?@.emoji == '\ud83d\ude00'].name`, want: []interface{}{"René"}},
+		{path: `$.items[?match(@.note, 'line\nb.*')].name`, want: []interface{}{"René"}},
+		{path: `$.items[?@.note == 'line\qbreak']`, wantErr: true},
+		{path: `$.items[?@.emoji == '\ud83d']`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+			// The canonical form keeps the unescaped value
+			canonical := MustCompile(tt.path).String()
+			if again := queryValues(t, data, canonical); !reflect.DeepEqual(again, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", canonical, again, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterEscapedQuotes(t *testing.T) {
+	data := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"title": "it's here", "quote": `she said "hi"`, "op": "a && b || c", "br": "x]) == y"},
+			map[string]interface{}{"title": "other", "quote": "none", "op": "a", "br": "y"},
+		},
+		"tags": []interface{}{"it's", `say "hi"`, "it's"},
+	}
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.items[?@.title == 'it\'s here'].title`, []interface{}{"it's here"}},
+		{`$.items[?@.quote == "she said \"hi\""].title`, []interface{}{"it's here"}},
+		{`$.items[?@.op == 'a && b || c' && @.br == 'x]) == y'].title`, []interface{}{"it's here"}},
+		{`$.items[?(@.title == "it's here" || @.quote == 'she said "hi"')].title`, []interface{}{"it's here"}},
+		{`$.items[?@.title != 'it\'s here'].title`, []interface{}{"other"}},
+		{`$.items[?search(@.quote, "\"hi\"")].title`, []interface{}{"it's here"}},
+		{`$.items[?match(@.title, 'it\'s.*')].title`, []interface{}{"it's here"}},
+		{`$.tags.occurrences('it\'s')`, []interface{}{float64(2)}},
+		{`occurrences($.tags, "say \"hi\"")`, []interface{}{float64(1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+			canonical := MustCompile(tt.path).String()
+			if again := queryValues(t, data, canonical); !reflect.DeepEqual(again, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", canonical, again, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{`$.items[?@.title == 'it\\'s']`, `$.items[?@.quote == "she said "hi""]`} {
+		if _, err := Query(data, path); err == nil {
+			t.Errorf("Query(%q) expected error", path)
+		}
+	}
+}
+
+func TestRootQueriesInFilters(t *testing.T) {
+	data := `{
+		"settings": {"maxPrice": 10, "on": true, "tags": ["a"]},
+		"items": [
+			{"price": 5, "tag": "a", "sub": [{"p": 10}]},
+			{"price": 15, "tag": "b", "sub": [{"p": 1}]}
+		]
+	}`
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{path: "$.items[?@.price < $.settings.maxPrice].price", want: []interface{}{float64(5)}},
+		{path: "$.items[?@.price >= $['settings']['maxPrice']].price", want: []interface{}{float64(15)}},
+		{path: "$.items[?@.tag == $.settings.tags[0]].price", want: []interface{}{float64(5)}},
+		{path: "$.items[?$.settings.on].price", want: []interface{}{float64(5), float64(15)}},
+		{path: "$.items[?$.settings.off].price"},
+		{path: "$.items[?count($.items[*]) == 2].price", want: []interface{}{float64(5), float64(15)}},
+		{path: "$..sub[?@.p == $.settings.maxPrice].p", want: []interface{}{float64(10)}},
+		{path: "$.items[?@.sub[?@.p == $.settings.maxPrice]].price", want: []interface{}{float64(5)}},
+		{path: "$.items[?@.price < $..maxPrice]", wantErr: true},
+		{path: "$.items[?@.price < $.settings[*]]", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBracketQueriesInFilters(t *testing.T) {
+	data := `[
+		{"id": 1, "weird key": 1, "a.b": 3, "n": {"b c": "x"}, "list": [7]},
+		{"id": 2, "weird key": 2, "a.b": 1, "n": {"b c": "y"}, "list": []}
+	]`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$[?@['weird key'] == 1].id`, []interface{}{float64(1)}},
+		{`$[?@["a.b"] > 2].id`, []interface{}{float64(1)}},
+		{`$[?@['n']['b c'] == 'y'].id`, []interface{}{float64(2)}},
+		{`$[?@.n["b c"] == 'x'].id`, []interface{}{float64(1)}},
+		{`$[?@['list'][0] == 7].id`, []interface{}{float64(1)}},
+		{`$[?@['list'][0]].id`, []interface{}{float64(1)}},
+		{`$[?!@['list'][0]].id`, []interface{}{float64(2)}},
+		{`$[?length(@['n']['b c']) == 1].id`, []interface{}{float64(1), float64(2)}},
+		{`$[?@['weird key'] == $[1]['weird key']].id`, []interface{}{float64(2)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChainedFunctionSegments(t *testing.T) {
+	data := `{"store": {"b": [1, 2], "a": {"x": 3}}, "prices": {"k1": 10, "k2": 20}}`
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{path: "$.store.keys().length()", want: []interface{}{float64(2)}},
+		{path: "$.store.keys()[0]", want: []interface{}{"a"}},
+		{path: "$.prices.values()[*]", want: []interface{}{float64(10), float64(20)}},
+		{path: "$.prices.values()[-1:]", want: []interface{}{float64(20)}},
+		{path: "$.prices.keys()[?@ == 'k2']", want: []interface{}{"k2"}},
+		{path: "$.prices.values().sum()", want: []interface{}{float64(30)}},
+		{path: "$.store.values()[0].x", want: []interface{}{float64(3)}},
+		{path: "$.store.values()[1].length()", want: []interface{}{float64(2)}},
+		{path: "$.store.keys().length().length()", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWildcardAfterSegments(t *testing.T) {
+	data := `{
+		"store": {"book": [
+			{"title": "A", "price": 5},
+			{"title": "B", "price": 20, "tags": ["x", "y"]}
+		]},
+		"m": [[1, 2], [3]]
+	}`
+
+	tests := []struct {
+		path      string
+		locations []string
+	}{
+		{"$.store.book[?@.price>10].*", []string{
+			"$['store']['book'][1]['price']",
+			"$['store']['book'][1]['tags']",
+			"$['store']['book'][1]['title']",
+		}},
+		{"$.store.book[1:].tags.*", []string{
+			"$['store']['book'][1]['tags'][0]",
+			"$['store']['book'][1]['tags'][1]",
+		}},
+		{"$.m[0].*", []string{"$['m'][0][0]", "$['m'][0][1]"}},
+		{"$.m[*].*", []string{"$['m'][0][0]", "$['m'][0][1]", "$['m'][1][0]"}},
+		{"$['m'][-1].*", []string{"$['m'][1][0]"}},
+		{"$.m[0,1].*", []string{"$['m'][0][0]", "$['m'][0][1]", "$['m'][1][0]"}},
+		{"$..book[?@.tags].*.*", []string{
+			"$['store']['book'][1]['tags'][0]",
+			"$['store']['book'][1]['tags'][1]",
+		}},
+		{"$.store.book[?@.price>10] .*", []string{
+			"$['store']['book'][1]['price']",
+			"$['store']['book'][1]['tags']",
+			"$['store']['book'][1]['title']",
+		}},
+		{"$.store.book[0].title.*", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []string
+			for _, n := range result {
+				got = append(got, n.Location)
+			}
+			if !reflect.DeepEqual(got, tt.locations) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.locations)
+			}
+		})
+	}
+}
+
+func TestLastIndex(t *testing.T) {
+	data := `{"a": [0, 1, 2, 3, 4, 5], "b": [{"x": [7, 8]}, {"x": [9]}], "last": "member"}`
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{path: "$.a[last()]", want: []interface{}{float64(5)}},
+		{path: "$.a[last()-1]", want: []interface{}{float64(4)}},
+		{path: "$.a[last() - 2]", want: []interface{}{float64(3)}},
+		{path: "$.a[last()-0]", want: []interface{}{float64(5)}},
+		{path: "$.a[last()-6]"},
+		{path: "$.a[0, last()]", want: []interface{}{float64(0), float64(5)}},
+		{path: "$.a[last()-2:]", want: []interface{}{float64(3), float64(4), float64(5)}},
+		{path: "$.a[1:last()]", want: []interface{}{float64(1), float64(2), float64(3), float64(4)}},
+		{path: "$.b[*].x[last()]", want: []interface{}{float64(8), float64(9)}},
+		{path: "$.b[?@.x[last()] == 8].x[0]", want: []interface{}{float64(7)}},
+		{path: "$.last", want: []interface{}{"member"}},
+		{path: "$['last']", want: []interface{}{"member"}},
+		{path: "$.a[last]", wantErr: true},
+		{path: "$.a[last()+1]", wantErr: true},
+		{path: "$.a[last()-01]", wantErr: true},
+		{path: "$.a[last()-1.5]", wantErr: true},
+		{path: "$.a[last(]", wantErr: true},
+		{path: "$.a[::last()]", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryUnion(t *testing.T) {
+	data := `{"a": {"b": 1}, "c": [{"d": 2}, {"d": 3}], "e": "x"}`
+
+	tests := []struct {
+		path      string
+		locations []string
+		wantErr   bool
+	}{
+		{path: "$.a.b | $.c[*].d", locations: []string{"$['a']['b']", "$['c'][0]['d']", "$['c'][1]['d']"}},
+		{path: "$.e|$.a.b", locations: []string{"$['e']", "$['a']['b']"}},
+		{path: "$.a.b | $.a.b", locations: []string{"$['a']['b']", "$['a']['b']"}},
+		{path: "$.missing | $.c[?@.d > 2].d | $.e", locations: []string{"$['c'][1]['d']", "$['e']"}},
+		{path: "$.missing | $.other", locations: nil},
+		{path: "$.a.b |", wantErr: true},
+		{path: "| $.a.b", wantErr: true},
+		{path: "$.a | @.b", wantErr: true},
+		{path: "$.a | length($.c)", wantErr: true},
+		{path: "$[?@.d | 1]", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			var got []string
+			for _, n := range result {
+				got = append(got, n.Location)
+			}
+			if !reflect.DeepEqual(got, tt.locations) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.locations)
+			}
+		})
+	}
+
+	c := MustCompile("$.c[?@.d > {min}].d | $.e")
+	if got := c.Params(); !reflect.DeepEqual(got, []string{"min"}) {
+		t.Errorf("Params() = %v, want [min]", got)
+	}
+	if c.IsSingular() {
+		t.Error("IsSingular() = true for a union of queries")
+	}
+	result, err := c.ExecuteDecoder(json.NewDecoder(strings.NewReader(data)), Params{"min": 2})
+	if err != nil || len(result) != 2 {
+		t.Errorf("ExecuteDecoder() = %v, %v, want 2 nodes", result, err)
+	}
+}
+
+func TestExistenceFilters(t *testing.T) {
+	data := `{"book": [
+		{"title": "A", "isbn": "0-553-21311-3"},
+		{"title": "B", "isbn": null},
+		{"title": "C"},
+		{"title": "D", "isbn": false, "meta": {"tags": []}},
+		{"title": "E", "meta": {}}
+	]}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{"$.book[?@.isbn].title", []interface{}{"A", "B", "D"}},
+		{"$.book[?@['isbn']].title", []interface{}{"A", "B", "D"}},
+		{"$.book[?!@.isbn].title", []interface{}{"C", "E"}},
+		{"$.book[?@.meta.tags].title", []interface{}{"D"}},
+		{"$.book[?@.meta && !@.meta.tags].title", []interface{}{"E"}},
+		{"$.book[?@.isbn || @.meta].title", []interface{}{"A", "B", "D", "E"}},
+		{"$.book[?@.*].title", []interface{}{"A", "B", "C", "D", "E"}},
+		{"$.book[?@..tags].title", []interface{}{"D"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldComparisons(t *testing.T) {
+	data := `{"projects": [
+		{"id": 1, "spent": 5, "budget": 3},
+		{"id": 2, "spent": 1, "budget": 3},
+		{"id": 3, "spent": 2},
+		{"id": 4, "spent": "b", "budget": "a"},
+		{"id": 5, "spent": 3, "budget": 3, "limits": {"max": 4}}
+	]}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{"$.projects[?@.spent > @.budget].id", []interface{}{float64(1), float64(4)}},
+		{"$.projects[?@.spent <= @.budget].id", []interface{}{float64(2), float64(5)}},
+		{"$.projects[?@.spent == @.budget].id", []interface{}{float64(5)}},
+		{"$.projects[?@.spent != @.budget].id", []interface{}{float64(1), float64(2), float64(3), float64(4)}},
+		{"$.projects[?@.budget == @.missing].id", []interface{}{float64(3)}},
+		{"$.projects[?@.spent < @.limits.max].id", []interface{}{float64(5)}},
+		{"$.projects[?@['spent'] > @['budget']].id", []interface{}{float64(1), float64(4)}},
+		{"$.projects[?@.spent > $.projects[1].budget].id", []interface{}{float64(1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMembershipFilters(t *testing.T) {
+	data := `{
+		"book": [
+			{"id": 1, "category": "fiction", "tags": ["a"]},
+			{"id": 2, "category": "reference"},
+			{"id": 3, "category": "poetry"},
+			{"id": 4, "in": 0}
+		],
+		"allowed": ["poetry", "drama"]
+	}`
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{path: "$.book[?@.category in ['fiction', 'reference']].id", want: []interface{}{float64(1), float64(2)}},
+		{path: `$.book[?@.category in ["fiction"]].id`, want: []interface{}{float64(1)}},
+		{path: "$.book[?@.category nin ['fiction', 'reference']].id", want: []interface{}{float64(3), float64(4)}},
+		{path: "$.book[?@.category not in ['fiction', 'reference']].id", want: []interface{}{float64(3), float64(4)}},
+		{path: "$.book[?@.category in $.allowed].id", want: []interface{}{float64(3)}},
+		{path: "$.book[?@.id in [1, 3, 'x', null]].id", want: []interface{}{float64(1), float64(3)}},
+		{path: "$.book[?@.tags in [['a'], ['b']]].id", want: []interface{}{float64(1)}},
+		{path: "$.book[?@.id in []].id"},
+		{path: "$.book[?@.id in 1].id"},
+		{path: "$.book[?@.missing in [null]].id"},
+		{path: "$.book[?@.id in [1] || @.id == 2].id", want: []interface{}{float64(1), float64(2)}},
+		{path: "$.book[?@.in].id", want: []interface{}{float64(4)}},
+		{path: "$.book[?@.id in [1,]]", wantErr: true},
+		{path: "$.book[?@.id not [1]]", wantErr: true},
+		{path: "$.book[?@.* in [1]]", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainsFilters(t *testing.T) {
+	data := `{
+		"repos": [
+			{"id": 1, "tags": ["go", "cli"], "name": "golang-tools"},
+			{"id": 2, "tags": ["python"], "name": "pytools"},
+			{"id": 3, "tags": [[1, 2], 3], "name": "misc"},
+			{"id": 4, "contains": "go"}
+		],
+		"wanted": "cli"
+	}`
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{path: `$.repos[?@.tags contains "go"].id`, want: []interface{}{float64(1)}},
+		{path: "$.repos[?@.tags contains 'gopher'].id"},
+		{path: "$.repos[?@.name contains 'tools'].id", want: []interface{}{float64(1), float64(2)}},
+		{path: "$.repos[?@.name contains 'Tools'].id"},
+		{path: "$.repos[?@.tags contains 3].id", want: []interface{}{float64(3)}},
+		{path: "$.repos[?@.tags contains [1, 2]].id", want: []interface{}{float64(3)}},
+		{path: "$.repos[?@.tags contains $.wanted].id", want: []interface{}{float64(1)}},
+		{path: "$.repos[?@.name contains 3].id"},
+		{path: "$.repos[?@.missing contains 'go'].id"},
+		{path: "$.repos[?!(@.tags contains 'go')].id", want: []interface{}{float64(2), float64(3), float64(4)}},
+		{path: "$.repos[?@.contains].id", want: []interface{}{float64(4)}},
+		{path: "$.repos[?@.tags contains]", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetFilters(t *testing.T) {
+	data := `{
+		"users": [
+			{"id": 1, "roles": ["read"]},
+			{"id": 2, "roles": ["read", "write"]},
+			{"id": 3, "roles": ["admin", "read"]},
+			{"id": 4, "roles": []},
+			{"id": 5, "roles": "read"}
+		],
+		"granted": ["read", "write"]
+	}`
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{path: `$.users[?@.roles subsetof ["read", "write"]].id`, want: []interface{}{float64(1), float64(2), float64(4)}},
+		{path: "$.users[?@.roles subsetof $.granted].id", want: []interface{}{float64(1), float64(2), float64(4)}},
+		{path: "$.users[?@.roles anyof ['write', 'admin']].id", want: []interface{}{float64(2), float64(3)}},
+		{path: "$.users[?@.roles anyof []].id"},
+		{path: "$.users[?@.roles subsetof []].id", want: []interface{}{float64(4)}},
+		{path: "$.users[?@.roles anyof 'read'].id"},
+		{path: "$.users[?@.missing subsetof ['read']].id"},
+		{path: "$.users[?!(@.roles anyof ['admin'])].id", want: []interface{}{float64(1), float64(2), float64(4), float64(5)}},
+		{path: "$.users[?@.roles subsetof]", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexFilters(t *testing.T) {
+	data := `{
+		"items": [
+			{"id": 1, "sku": "AB-1234"},
+			{"id": 2, "sku": "ab-9999"},
+			{"id": 3, "sku": "AB-12345"},
+			{"id": 4, "sku": 1234},
+			{"id": 5, "sku": "x/y"}
+		],
+		"pattern": "^ab"
+	}`
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{path: `$.items[?@.sku =~ /^AB-\d{4}$/].id`, want: []interface{}{float64(1)}},
+		{path: `$.items[?@.sku =~ /AB-\d{4}/].id`, want: []interface{}{float64(1), float64(3)}},
+		{path: `$.items[?@.sku =~ /^ab-\d{4}$/i].id`, want: []interface{}{float64(1), float64(2)}},
+		{path: `$.items[?@.sku =~ "^AB-\\d{4}$"].id`, want: []interface{}{float64(1)}},
+		{path: `$.items[?@.sku=~/x\/y/].id`, want: []interface{}{float64(5)}},
+		{path: `$.items[?@.sku =~ $.pattern].id`, want: []interface{}{float64(2)}},
+		{path: `$.items[?@.sku =~ /^AB/ && @.id > 1].id`, want: []interface{}{float64(3)}},
+		{path: `$.items[?@.sku =~ /123/].id`, want: []interface{}{float64(1), float64(3)}},
+		{path: `$.items[?@.sku =~ /(/]`, wantErr: true},
+		{path: `$.items[?@.sku =~ "("]`, wantErr: true},
+		{path: `$.items[?@.sku =~ /abc]`, wantErr: true},
+		{path: `$.items[?@.sku =~ /a/x]`, wantErr: true},
+		{path: `$.items[?@.sku =~ 5]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	q, err := Parse(`$[?@.sku =~ /^ab\/\d$/i]`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got, want := q.String(), `$[?@['sku'] =~ '(?i)^ab/\\d$']`; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+}
+
+func TestAggregateSegments(t *testing.T) {
+	data := `{
+		"store": {
+			"book": [
+				{"category": "fiction", "price": 8},
+				{"category": "fiction", "price": 12},
+				{"category": "reference", "price": 5}
+			],
+			"bicycle": {"price": 20}
+		},
+		"nums": [1, 2, 3]
+	}`
+
+	tests := []struct {
+		path     string
+		want     []interface{}
+		location string
+	}{
+		{"$.store.book[*].price.sum()", []interface{}{float64(25)}, "$['store']['book']"},
+		{"$.store.book[?@.category == 'fiction'].price.sum()", []interface{}{float64(20)}, "$['store']['book']"},
+		{"$.store.book[*].price.min()", []interface{}{float64(5)}, "$['store']['book']"},
+		{"$.store.book[0,1].price.max()", []interface{}{float64(12)}, "$['store']['book']"},
+		{"$..price.max()", []interface{}{float64(20)}, "$['store']"},
+		{"$.store.book[1:].price.avg()", []interface{}{8.5}, "$['store']['book']"},
+		{"$.store.book[*].category.occurrences('fiction')", []interface{}{float64(2)}, "$['store']['book']"},
+		{"$.store.book[2:].price.sum()", []interface{}{float64(5)}, "$['store']['book'][2]['price']"},
+		// A singular query passes its single value, as before
+		{"$.nums.sum()", []interface{}{float64(6)}, "$['nums']"},
+		// Functions other than aggregates still apply to each node
+		{"$.store.book[*].category.length()", []interface{}{float64(7), float64(7), float64(9)}, "$['store']['book'][0]['category']"},
+		// Nothing selected, nothing to aggregate
+		{"$.store.book[?@.missing].price.sum()", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+			if len(result) > 0 && result[0].Location != tt.location {
+				t.Errorf("Query(%q) location = %q, want %q", tt.path, result[0].Location, tt.location)
+			}
+
+			var each []interface{}
+			err = Each(data, tt.path, func(value interface{}, path string) bool {
+				each = append(each, value)
+				return true
+			})
+			if err != nil || !reflect.DeepEqual(each, tt.want) {
+				t.Errorf("Each(%q) = %v, %v, want %v", tt.path, each, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParentSegment(t *testing.T) {
+	data := `{
+		"store": {
+			"book": [{"title": "A", "price": 8}, {"title": "B"}, {"title": "C", "price": 5}],
+			"bicycle": {"price": 20}
+		}
+	}`
+
+	tests := []struct {
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{path: "$..price^", want: []string{"$['store']['bicycle']", "$['store']['book'][0]", "$['store']['book'][2]"}},
+		{path: "$..price^.title", want: []string{"$['store']['book'][0]['title']", "$['store']['book'][2]['title']"}},
+		{path: "$.store.book[?@.price > 6]^^", want: []string{"$['store']"}},
+		// A parent shared by several nodes is selected once
+		{path: "$.store.book[*]^", want: []string{"$['store']['book']"}},
+		{path: "$..price^^", want: []string{"$['store']", "$['store']['book']"}},
+		{path: "$.store.bicycle.price ^", want: []string{"$['store']['bicycle']"}},
+		// The root has no parent
+		{path: "$^"},
+		{path: "$.store^^"},
+		{path: "$[?@^]", wantErr: true},
+		{path: "$.store.^", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			var got []string
+			for _, n := range result {
+				got = append(got, n.Location)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMixedUnions(t *testing.T) {
+	arr := `[10, 20, 30, 40, 50]`
+	obj := `{"a": 1, "b": [1, 2], "title": "t"}`
+
+	tests := []struct {
+		data string
+		path string
+		want []interface{}
+	}{
+		{arr, `$[0,2:4]`, []interface{}{10.0, 30.0, 40.0}},
+		{arr, `$[4,0,'x',1:2]`, []interface{}{50.0, 10.0, 20.0}},
+		{arr, `$[?@ > 35,0]`, []interface{}{40.0, 50.0, 10.0}},
+		{arr, `$[*,0]`, []interface{}{10.0, 20.0, 30.0, 40.0, 50.0, 10.0}},
+		{arr, `$[-1,::2]`, []interface{}{50.0, 10.0, 30.0, 50.0}},
+		{arr, `$[0, 1 , 1:3 ]`, []interface{}{10.0, 20.0, 20.0, 30.0}},
+		{obj, `$['a',0,'title']`, []interface{}{1.0, "t"}},
+		{obj, `$['b',*]`, []interface{}{[]interface{}{1.0, 2.0}, 1.0, []interface{}{1.0, 2.0}, "t"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, tt.data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrimitiveFilters(t *testing.T) {
+	data := `{
+		"numbers": [1, 7, 3, 9, "10", null, true],
+		"tags": ["go", "rust", "go"],
+		"matrix": [[1, 2], [3]]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.numbers[?@ > 5]`, []interface{}{7.0, 9.0}},
+		{`$.numbers[?@ <= 3]`, []interface{}{1.0, 3.0}},
+		{`$.numbers[?@ == '10']`, []interface{}{"10"}},
+		{`$.numbers[?@ == null]`, []interface{}{nil}},
+		{`$.numbers[?@ == true]`, []interface{}{true}},
+		{`$.numbers[?@ > 5 && @ < 9]`, []interface{}{7.0}},
+		{`$.tags[?@ == "go"]`, []interface{}{"go", "go"}},
+		{`$.tags[?@ != 'go']`, []interface{}{"rust"}},
+		{`$.tags[?match(@, 'r.*')]`, []interface{}{"rust"}},
+		{`$.matrix[?@[0] > 2]`, []interface{}{[]interface{}{3.0}}},
+		{`$.matrix[*][?@ > 1]`, []interface{}{2.0, 3.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNestedFilters(t *testing.T) {
+	data := `{"orders": [
+		{"id": 1, "items": [{"qty": 0}, {"qty": 2, "parts": [{"ok": true}]}]},
+		{"id": 2, "items": [{"qty": 0}]},
+		{"id": 3, "items": []},
+		{"id": 4}
+	]}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.orders[?@.items[?@.qty > 0]].id`, []interface{}{1.0}},
+		{`$.orders[?!@.items[?@.qty > 0]].id`, []interface{}{2.0, 3.0, 4.0}},
+		{`$.orders[?@.items[?@.qty == 0]].id`, []interface{}{1.0, 2.0}},
+		{`$.orders[?@.items[?@.parts[?@.ok == true]]].id`, []interface{}{1.0}},
+		{`$.orders[?@.items[?@.qty > 0] && @.id > 1].id`, nil},
+		{`$.orders[?@.items[?@.qty > 0] || @.id == 4].id`, []interface{}{1.0, 4.0}},
+		{`$.orders[?count(@.items[?@.qty >= 0]) == 1].id`, []interface{}{2.0}},
+		{`$.orders[?@.items[?@.qty > 0]].items[?@.qty > 0].qty`, []interface{}{2.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectContainersByNestedMatch(t *testing.T) {
+	data := `{"orders": [
+		{"id": 1, "lines": [{"sku": "X", "qty": 1}, {"sku": "Y", "qty": 2}]},
+		{"id": 2, "lines": [{"sku": "Y", "qty": 5}]},
+		{"id": 3, "lines": [{"sku": "Z", "bundle": [{"sku": "X"}]}]},
+		{"id": 4, "lines": []}
+	]}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		// The order is selected once, however many of its lines match
+		{`$.orders[?@.lines[?@.sku == 'X' || @.sku == 'Y']].id`, []interface{}{1.0, 2.0}},
+		{`$.orders[?@.lines[?@.sku == 'X']].lines[*].qty`, []interface{}{1.0, 2.0}},
+		// Descendant filters match at any depth
+		{`$.orders[?@..[?@.sku == 'X']].id`, []interface{}{1.0, 3.0}},
+		{`$.orders[?!@.lines[?@.sku == 'X']].id`, []interface{}{2.0, 3.0, 4.0}},
+		{`$.orders[?@.lines[?@.sku == 'Y' && @.qty > 1]].id`, []interface{}{1.0, 2.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExistsInFilters(t *testing.T) {
+	data := `{"events": [
+		{"id": 1, "error": {"code": 500}},
+		{"id": 2, "payload": {"steps": [{"ok": true}, {"error": null}]}},
+		{"id": 3, "payload": {"steps": [{"ok": true}]}},
+		{"id": 4, "error": false}
+	]}`
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr string
+	}{
+		{path: `$.events[?exists(@..error)].id`, want: []interface{}{1.0, 2.0, 4.0}},
+		{path: `$.events[?!exists(@..error)].id`, want: []interface{}{3.0}},
+		{path: `$.events[?exists(@.payload.steps[?@.ok])].id`, want: []interface{}{2.0, 3.0}},
+		{path: `$.events[?exists(@..error) && @.id > 1].id`, want: []interface{}{2.0, 4.0}},
+		// exists() is the function form of an existence test
+		{path: `$.events[?@..error].id`, want: []interface{}{1.0, 2.0, 4.0}},
+		{path: `$.events[?exists(@..error) == true]`, wantErr: "exists() result cannot be compared"},
+		{path: `$.events[?exists('error')]`, wantErr: "exists() argument must be a nodelist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if tt.wantErr != "" {
+				if _, err := Query(data, tt.path); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Query(%q) error = %v, want %q", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexedPathsInFilters(t *testing.T) {
+	data := `{"students": [
+		{"id": 1, "scores": [95, 80], "tags": ["draft", "final"], "grid": [[1, 2], [3, 4]]},
+		{"id": 2, "scores": [85, 99], "tags": ["final", "draft"], "grid": [[5]]},
+		{"id": 3, "scores": [], "tags": "final"}
+	]}`
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr string
+	}{
+		{path: `$.students[?@.scores[0] > 90].id`, want: []interface{}{1.0}},
+		{path: `$.students[?@.scores[-1] > 90].id`, want: []interface{}{2.0}},
+		{path: `$.students[?@.tags[-1] == 'final'].id`, want: []interface{}{1.0}},
+		{path: `$.students[?@['tags'][0] == 'final'].id`, want: []interface{}{2.0}},
+		{path: `$.students[?@.grid[1][0] == 3].id`, want: []interface{}{1.0}},
+		{path: `$.students[?@.grid[0][-1] > @.grid[-1][0]].id`, want: nil},
+		// An index out of range, or into a non-array, selects nothing
+		{path: `$.students[?@.scores[2] || @.tags[0]].id`, want: []interface{}{1.0, 2.0}},
+		{path: `$.students[?@.scores[0] != 95].id`, want: []interface{}{2.0, 3.0}},
+		// Only single indices keep a query singular
+		{path: `$.students[?@.scores[0:1] > 90]`, wantErr: "non-singular query"},
+		{path: `$.students[?@.scores[0,1] > 90]`, wantErr: "non-singular query"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if tt.wantErr != "" {
+				if _, err := Query(data, tt.path); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Query(%q) error = %v, want %q", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterFunctionTyping(t *testing.T) {
+	data := `{"books": [
+		{"title": "Sayings", "authors": ["a", "b"], "tags": [{"tag": "x"}, {"tag": "y"}]},
+		{"title": "Go", "authors": ["c"], "tags": [{"tag": "x"}]}
+	]}`
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr string
+	}{
+		// ValueType arguments and results
+		{path: `$.books[?length(@.authors) > 1].title`, want: []interface{}{"Sayings"}},
+		{path: `$.books[?length(@.title) == 2].title`, want: []interface{}{"Go"}},
+		{path: `$.books[?length(value(@.authors[*])) == 1].title`, want: []interface{}{"Go"}},
+		{path: `$.books[?length(@.authors[*]) > 1]`, wantErr: "length() argument must be a singular query"},
+		{path: `$.books[?length(@.authors)]`, wantErr: "length() result must be compared"},
+		// NodesType arguments
+		{path: `$.books[?count(@..tag) == 2].title`, want: []interface{}{"Sayings"}},
+		{path: `$.books[?count(@.tags[*]) == 1].title`, want: []interface{}{"Go"}},
+		{path: `$.books[?count(1) == 1]`, wantErr: "count() argument must be a nodelist"},
+		// LogicalType results
+		{path: `$.books[?match(@.title, 'G.*')].title`, want: []interface{}{"Go"}},
+		{path: `$.books[?!search(@.title, 'ing')].title`, want: []interface{}{"Go"}},
+		{path: `$.books[?match(@.title, 'G.*') == true]`, wantErr: "match() result cannot be compared"},
+		{path: `$.books[?length(match(@.title, 'G')) == 1]`, wantErr: "length() argument must be a value"},
+		// Arity
+		{path: `$.books[?match(@.title) == 1]`, wantErr: "match() requires exactly 2 arguments"},
+		{path: `$.books[?length() == 1]`, wantErr: "length() requires exactly 1 argument"},
+		{path: `$.books[?nosuch(@.title)]`, wantErr: "unknown function: nosuch"},
+		{path: `$.books[0].title.nosuch()`, wantErr: "unknown function: nosuch"},
+		{path: `nosuch($.books)`, wantErr: "unknown function: nosuch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Query(%q) error = %v, want %q", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountInFilters(t *testing.T) {
+	data := `{"nodes": [
+		{"id": 1, "children": [{"id": 2}, {"id": 3}, {"id": 4, "children": [{"id": 5}]}]},
+		{"id": 6, "children": [{"id": 7}]},
+		{"id": 8, "children": []},
+		{"id": 9}
+	]}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.nodes[?count(@.children[*]) > 2].id`, []interface{}{1.0}},
+		{`$.nodes[?count(@.children[*]) == 0].id`, []interface{}{8.0, 9.0}},
+		{`$.nodes[?count(@.children) == 1].id`, []interface{}{1.0, 6.0, 8.0}},
+		{`$.nodes[?count(@..id) == 5].id`, []interface{}{1.0}},
+		{`$.nodes[?count(@.children[?@.children]) == 1].id`, []interface{}{1.0}},
+		{`$.nodes[?count(@.children[0, -1]) == 2].id`, []interface{}{1.0, 6.0}},
+		{`$.nodes[?count($.nodes[*]) == 4].id`, []interface{}{1.0, 6.0, 8.0, 9.0}},
+		{`$.nodes[?count(@.children[*]) < count($.nodes[*])].id`, []interface{}{1.0, 6.0, 8.0, 9.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValueInFilters(t *testing.T) {
+	data := `{"items": [
+		{"id": 1, "a": {"color": "red"}},
+		{"id": 2, "a": {"color": "blue"}},
+		{"id": 3, "a": {"color": "red", "b": {"color": "red"}}},
+		{"id": 4, "colors": ["red"]},
+		{"id": 5}
+	]}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.items[?value(@..color) == 'red'].id`, []interface{}{1.0}},
+		{`$.items[?value(@.a.*) == 'blue'].id`, []interface{}{2.0}},
+		{`$.items[?value(@.colors[*]) == 'red'].id`, []interface{}{4.0}},
+		{`$.items[?value(@..color) != 'red'].id`, []interface{}{2.0, 3.0, 4.0, 5.0}},
+		{`$.items[?length(value(@.a.*)) == 4].id`, []interface{}{2.0}},
+		{`$.items[?value(@.b) == value(@.c)].id`, []interface{}{1.0, 2.0, 3.0, 4.0, 5.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchAndSearchIRegexp(t *testing.T) {
+	data := `{"w": ["abc", "xabcx", "^abc$", "", "a\nc", 5, "a$b"]}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		// match() is a full-string match, search() a substring search
+		{`$.w[?match(@, 'abc')]`, []interface{}{"abc"}},
+		{`$.w[?search(@, 'abc')]`, []interface{}{"abc", "xabcx", "^abc$"}},
+		// ^ and $ anchor the pattern, as the compliance test suite expects
+		{`$.w[?match(@, '^abc$')]`, []interface{}{"abc"}},
+		{`$.w[?match(@, '^ab.*')]`, []interface{}{"abc"}},
+		{`$.w[?match(@, '.*bc$')]`, []interface{}{"abc"}},
+		{`$.w[?search(@, '^abc')]`, []interface{}{"abc"}},
+		{`$.w[?search(@, 'bc$')]`, []interface{}{"abc"}},
+		{`$.w[?search(@, 'a\\$b')]`, []interface{}{"a$b"}},
+		// An empty pattern matches only the empty string, and is found in any string
+		{`$.w[?match(@, '')]`, []interface{}{""}},
+		{`$.w[?search(@, '')]`, []interface{}{"abc", "xabcx", "^abc$", "", "a\nc", "a$b"}},
+		// . does not match line terminators
+		{`$.w[?match(@, 'a.c')]`, []interface{}{"abc"}},
+		// Non-strings and invalid patterns are false rather than errors
+		{`$.w[?!search(@, 'a')]`, []interface{}{"", 5.0}},
+		{`$.w[?search(@, '(')]`, nil},
+		{`$.w[?match(@, '(?:a)')]`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingVersusNull(t *testing.T) {
+	data := `[{"id": 1, "a": null}, {"id": 2}, {"id": 3, "a": "x"}, {"id": 4, "a": "y"}]`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		// An absent member is not null
+		{`$[?@.a == null].id`, []interface{}{1.0}},
+		{`$[?@.a != null].id`, []interface{}{2.0, 3.0, 4.0}},
+		// An absent member is unequal to every value
+		{`$[?@.a != 'x'].id`, []interface{}{1.0, 2.0, 4.0}},
+		{`$[?@.a == 'x'].id`, []interface{}{3.0}},
+		{`$[?!(@.a == 'x')].id`, []interface{}{1.0, 2.0, 4.0}},
+		// Two absent values are equal, and neither is less than the other
+		{`$[?@.a == @.b].id`, []interface{}{2.0}},
+		{`$[?@.a != @.b].id`, []interface{}{1.0, 3.0, 4.0}},
+		{`$[?@.a <= @.b].id`, []interface{}{2.0}},
+		{`$[?@.a >= @.b].id`, []interface{}{2.0}},
+		{`$[?@.a < @.b].id`, nil},
+		// Ordering against an absent value is false
+		{`$[?@.a < 'z'].id`, []interface{}{3.0, 4.0}},
+		{`$[?@.b >= 'a'].id`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegatedFilters(t *testing.T) {
+	data := `[
+		{"id": 1, "a": 0, "b": 2, "s": "xyz"},
+		{"id": 2, "a": 1, "b": 2, "c": true},
+		{"id": 3, "a": 1, "b": 3, "s": "abc"},
+		{"id": 4, "b": 2}
+	]`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		// A negated comparison is true for absent values, unlike its inverse
+		{`$[?!(@.a < 1)].id`, []interface{}{2.0, 3.0, 4.0}},
+		{`$[?@.a >= 1].id`, []interface{}{2.0, 3.0}},
+		{`$[?!(@.a == 1 && @.b == 2)].id`, []interface{}{1.0, 3.0, 4.0}},
+		{`$[?!(@.a == 1 || (@.b == 2 && !@.c))].id`, nil},
+		{`$[?!(@.a) && (@.b == 2)].id`, []interface{}{4.0}},
+		{`$[?!(!@.s)].id`, []interface{}{1.0, 3.0}},
+		{`$[?!match(@.s, 'x.*')].id`, []interface{}{2.0, 3.0, 4.0}},
+		{`$[?!(search(@.s, 'b') || @.c)].id`, []interface{}{1.0, 4.0}},
+		{`$[?!(length(@.s) == 3)].id`, []interface{}{2.0, 4.0}},
+		{`$[?!(@.s =~ /^x/)].id`, []interface{}{2.0, 3.0, 4.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringPredicates(t *testing.T) {
+	data := `[
+		{"id": 1, "name": "dev-api", "tags": ["a", "b"]},
+		{"id": 2, "name": "prod-api"},
+		{"id": 3, "name": 5},
+		{"id": 4, "name": "dev-web", "tags": ["c"]}
+	]`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$[?startsWith(@.name, 'dev-')].id`, []interface{}{1.0, 4.0}},
+		{`$[?endsWith(@.name, '-api')].id`, []interface{}{1.0, 2.0}},
+		{`$[?contains(@.name, '-a')].id`, []interface{}{1.0, 2.0}},
+		{`$[?contains(@.tags, 'c')].id`, []interface{}{4.0}},
+		// Regex metacharacters need no escaping
+		{`$[?startsWith(@.name, 'dev.')].id`, nil},
+		// Values that are not strings, and absent values, never match
+		{`$[?!startsWith(@.name, 'dev-')].id`, []interface{}{2.0, 3.0}},
+		{`$[?endsWith(@.missing, '')].id`, nil},
+		{`$[?startsWith(@.name, 'dev-') && !endsWith(@.name, 'web')].id`, []interface{}{1.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexInFilters(t *testing.T) {
+	data := `{"a": [10, 11, 12, 13, 14, 15, 16], "o": {"x": 1, "y": 2}, "n": [[1, 2, 3], [4, 5]]}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.a[?index() % 2 == 0]`, []interface{}{10.0, 12.0, 14.0, 16.0}},
+		{`$.a[?position() < 3]`, []interface{}{10.0, 11.0}},
+		{`$.a[?index() > 2 && @ != 15]`, []interface{}{13.0, 14.0, 16.0}},
+		{`$.a[?@ == index() + 10]`, []interface{}{10.0, 11.0, 12.0, 13.0, 14.0, 15.0, 16.0}},
+		// Object members have no index
+		{`$.o[?index() == 0]`, nil},
+		// Each filter sees the index of its own element
+		{`$.n[?@[?index() == 2] && index() == 0]`, []interface{}{[]interface{}{1.0, 2.0, 3.0}}},
+		{`$.n[*][?position() == 2]`, []interface{}{2.0, 5.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path, WithArithmetic())
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	for path, want := range map[string]string{
+		`$.a[?index()]`:         "index() result must be compared",
+		`$.a[?index(@) == 1]`:   "index() takes no arguments",
+		`$.a.index()`:           "unknown function",
+		`$.a[?@ == position()]`: "",
+	} {
+		_, err := Query(data, path)
+		if want == "" && err != nil || want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("Query(%q) error = %v, want %q", path, err, want)
+		}
+	}
+}
+
+func TestKeyInFilters(t *testing.T) {
+	data := `{
+		"metrics": {"cpu_user": 12, "cpu_sys": 3, "mem_used": 512, "cpu": {"cores": 4}},
+		"list": ["a", "b", "c"]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.metrics[?key() =~ '^cpu_']`, []interface{}{3.0, 12.0}},
+		{`$.metrics[?startsWith(key(), 'cpu') && @ > 5]`, []interface{}{12.0}},
+		{`$.metrics[?key() == 'cpu'].cores`, []interface{}{4.0}},
+		{`$.metrics[?key() != 'mem_used' && key() in ['cpu_sys', 'mem_used']]`, []interface{}{3.0}},
+		// Array elements are keyed by their index
+		{`$.list[?key() >= 1]`, []interface{}{"b", "c"}},
+		{`$.list[?key() == 'a']`, nil},
+		// Each filter sees the key of its own member
+		{`$[?@[?key() == 'cores']]`, nil},
+		{`$.metrics[?@[?key() == 'cores'] && key() == 'cpu'].cores`, []interface{}{4.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	// Locations tell the matching members apart
+	result, err := Query(data, `$.metrics[?key() =~ '^cpu_']`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locations []string
+	for _, n := range result {
+		locations = append(locations, n.Location)
+	}
+	if want := []string{"$['metrics']['cpu_sys']", "$['metrics']['cpu_user']"}; !reflect.DeepEqual(locations, want) {
+		t.Errorf("locations = %v, want %v", locations, want)
+	}
+}
+
+func TestNestedBrackets(t *testing.T) {
+	data := `{"items": [
+		{"id": 1, "tags": ["x", "y"], "m": {"k": [1, 5]}},
+		{"id": 2, "tags": ["y"], "m": {"k": [0]}}
+	]}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.items[?@.tags[0] == "x"].id`, []interface{}{1.0}},
+		{`$.items[?@['tags'][0] == 'y'].id`, []interface{}{2.0}},
+		{`$.items[?@.m.k[-1] > 2].id`, []interface{}{1.0}},
+		{`$.items[?@.m['k'][0] == 0].id`, []interface{}{2.0}},
+		{`$.items[?@.tags[1]].id`, []interface{}{1.0}},
+		{`$.items[?@.tags[?@ == 'x']].id`, []interface{}{1.0}},
+		{`$.items[?@.m.k[?@ > 3]].id`, []interface{}{1.0}},
+		{`$.items[?length(@.tags[0]) == 1].id`, []interface{}{1.0, 2.0}},
+		{`$.items[?@.tags[0] == $.items[1].tags[0]].id`, []interface{}{2.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// queryLocations returns the locations selected by path, failing the test on error
+func queryLocations(t *testing.T, data interface{}, path string) []string {
+	t.Helper()
+	result, err := Query(data, path)
+	if err != nil {
+		t.Fatalf("Query(%q) error = %v", path, err)
+	}
+	locations := []string{}
+	for _, n := range result {
+		locations = append(locations, n.Location)
+	}
+	return locations
+}
+
+const descendantTestDoc = `{"store": {
+	"book": [{"t": "a", "price": 5}, {"t": "b", "price": 15}],
+	"o": {"book": [{"t": "c", "price": 1}]},
+	"x": [[1, 2], [3]]
+}}`
+
+func TestDescendantIndex(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{`$..book[0]`, []string{"$['store']['book'][0]", "$['store']['o']['book'][0]"}},
+		{`$..book[0].t`, []string{"$['store']['book'][0]['t']", "$['store']['o']['book'][0]['t']"}},
+		{`$..book[-1]`, []string{"$['store']['book'][1]", "$['store']['o']['book'][0]"}},
+		{`$..[0]`, []string{
+			"$['store']['book'][0]", "$['store']['o']['book'][0]",
+			"$['store']['x'][0]", "$['store']['x'][0][0]", "$['store']['x'][1][0]",
+		}},
+		{`$.store.x..[1]`, []string{"$['store']['x'][1]", "$['store']['x'][0][1]"}},
+		{`$..[5]`, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryLocations(t, descendantTestDoc, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescendantWildcard(t *testing.T) {
+	data := `{"a": [1, {"b": 2}], "c": 3, "d": {}}`
+	want := []string{
+		"$['a']", "$['c']", "$['d']",
+		"$['a'][0]", "$['a'][1]",
+		"$['a'][1]['b']",
+	}
+	for _, path := range []string{`$..*`, `$..[*]`} {
+		t.Run(path, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				if got := queryLocations(t, data, path); !reflect.DeepEqual(got, want) {
+					t.Fatalf("Query(%q) = %v, want %v", path, got, want)
+				}
+			}
+		})
+	}
+
+	// Every descendant is selected exactly once
+	got := queryLocations(t, descendantTestDoc, `$..*`)
+	seen := make(map[string]bool)
+	for _, loc := range got {
+		if seen[loc] {
+			t.Errorf("Query($..*) selected %s twice", loc)
+		}
+		seen[loc] = true
+	}
+	if len(got) != 19 {
+		t.Errorf("Query($..*) selected %d nodes, want 19", len(got))
+	}
+
+	// Scalars have no descendants
+	if got := queryLocations(t, `{"a": 1}`, `$.a..*`); len(got) != 0 {
+		t.Errorf("Query($.a..*) = %v, want none", got)
+	}
+}
+
+func TestDescendantFilter(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{`$..[?@.price < 10]`, []string{"$['store']['book'][0]", "$['store']['o']['book'][0]"}},
+		{`$..[?(@.price < 10)].t`, []string{"$['store']['book'][0]['t']", "$['store']['o']['book'][0]['t']"}},
+		{`$..book[?@.price > 10].t`, []string{"$['store']['book'][1]['t']"}},
+		{`$..[?@ == 3]`, []string{"$['store']['x'][1][0]"}},
+		{`$..[?@.t == 'c' || @.price == 15].t`, []string{"$['store']['book'][1]['t']", "$['store']['o']['book'][0]['t']"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryLocations(t, descendantTestDoc, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortFunction(t *testing.T) {
+	data := `{
+		"prices": [30, 4.5, 100, -2, 4.5],
+		"names": ["bob", "Alice", "carol", "alice"],
+		"mixed": ["b", 2, null, true, "a", 1, false],
+		"nested": [[1], 2],
+		"lists": [[3, 1], [2, 0]],
+		"book": [{"price": 12}, {"price": 8}, {"price": 10}]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.prices.sort()`, []interface{}{[]interface{}{-2.0, 4.5, 4.5, 30.0, 100.0}}},
+		{`$.names.sort()`, []interface{}{[]interface{}{"Alice", "alice", "bob", "carol"}}},
+		{`$.mixed.sort()`, []interface{}{[]interface{}{nil, false, true, 1.0, 2.0, "a", "b"}}},
+		// Each selected array is sorted separately
+		{`$.lists[*].sort()`, []interface{}{[]interface{}{1.0, 3.0}, []interface{}{0.0, 2.0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	// sort() returns a copy and leaves the document unchanged
+	doc := map[string]interface{}{"a": []interface{}{3.0, 1.0, 2.0}}
+	queryValues(t, doc, `$.a.sort()`)
+	if want := []interface{}{3.0, 1.0, 2.0}; !reflect.DeepEqual(doc["a"], want) {
+		t.Errorf("sort() modified the document: %v, want %v", doc["a"], want)
+	}
+
+	for _, path := range []string{`$.nested.sort()`, `$.book.sort()`, `$.names[0].sort()`, `$.book[*].price.sort()`} {
+		if _, err := Query(data, path); err == nil {
+			t.Errorf("Query(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestSortByFunction(t *testing.T) {
+	data := `{"book": [
+		{"title": "B", "price": 12, "meta": {"rank": 2}},
+		{"title": "A", "price": 8, "meta": {"rank": 3}},
+		{"title": "D"},
+		{"title": "C", "price": 10, "meta": {"rank": 1}}
+	]}`
+
+	titles := func(v interface{}) []interface{} {
+		var out []interface{}
+		for _, item := range v.([]interface{}) {
+			out = append(out, item.(map[string]interface{})["title"])
+		}
+		return out
+	}
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.book.sort_by('price')`, []interface{}{"A", "C", "B", "D"}},
+		{`$.book.sort_by(@.price)`, []interface{}{"A", "C", "B", "D"}},
+		{`$.book.sort_by('price', 'desc')`, []interface{}{"B", "C", "A", "D"}},
+		{`$.book.sort_by(@.meta.rank, 'asc')`, []interface{}{"C", "B", "A", "D"}},
+		{`$.book.sort_by(@['title'], 'desc')`, []interface{}{"D", "C", "B", "A"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got := queryValues(t, data, tt.path)
+			if len(got) != 1 {
+				t.Fatalf("Query(%q) = %v, want one array", tt.path, got)
+			}
+			if got := titles(got[0]); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	// Each selected array is sorted separately
+	got := queryValues(t, `{"a": [[{"n": 2}, {"n": 1}], [{"n": 0}]]}`, `$.a[*].sort_by('n')`)
+	want := []interface{}{
+		[]interface{}{map[string]interface{}{"n": 1.0}, map[string]interface{}{"n": 2.0}},
+		[]interface{}{map[string]interface{}{"n": 0.0}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Query(`$.a[*].sort_by('n')`) = %v, want %v", got, want)
+	}
+
+	// The canonical form keeps relative query arguments
+	c, err := Compile(`$.book.sort_by(@.price, 'desc')`)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if got, want := c.String(), `$['book'].sort_by(@['price'], 'desc')`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	for _, path := range []string{
+		`$.book.sort_by('price', 'down')`,
+		`$.book.sort_by(1)`,
+		`$.book.sort_by()`,
+		`$.book.sort_by($.price)`,
+		`$.book[?@.price].sort_by('price')`,
+	} {
+		if _, err := Query(data, path); err == nil {
+			t.Errorf("Query(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestUniqueFunction(t *testing.T) {
+	data := `{
+		"store": {
+			"book": [
+				{"category": "fiction", "tags": ["a", "b"]},
+				{"category": "reference", "tags": ["b", "a"]},
+				{"category": "fiction", "tags": ["a", "b"]}
+			],
+			"bicycle": {"category": "sport"}
+		},
+		"nums": [1, 1.0, 2, "1", null, null, true, 1],
+		"lists": [[1, 1], [2, 1, 2]]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.nums.unique()`, []interface{}{[]interface{}{1.0, 2.0, "1", nil, true}}},
+		{`$.nums.distinct()`, []interface{}{[]interface{}{1.0, 2.0, "1", nil, true}}},
+		{`$.lists[*].unique()`, []interface{}{[]interface{}{1.0}, []interface{}{2.0, 1.0}}},
+		// Arrays and objects are compared deeply, arrays in order
+		{`$.store.book.map('tags').unique()`, []interface{}{[]interface{}{
+			[]interface{}{"a", "b"}, []interface{}{"b", "a"},
+		}}},
+		{`$.store.book.unique().length()`, []interface{}{2.0}},
+		{`$.store.book[?@.category == 'none'].category.unique()`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstAndLastFunctions(t *testing.T) {
+	data := `{
+		"store": {"book": [{"price": 12}, {"price": 8}, {"price": 10}]},
+		"empty": [],
+		"nested": [[1, 2], [3]]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.store.book.map('price').last()`, []interface{}{10.0}},
+		{`$.store.book.map('price').sort().last()`, []interface{}{12.0}},
+		{`$.store.book.map('price').sort().first()`, []interface{}{8.0}},
+		{`$.store.book.first().price`, []interface{}{12.0}},
+		{`$.nested.last()`, []interface{}{[]interface{}{3.0}}},
+		// Each selected array gives its own element
+		{`$.nested[*].first()`, []interface{}{1.0, 3.0}},
+		{`$.nested[*].last()`, []interface{}{2.0, 3.0}},
+		// Nothing is selected from an empty array or an empty nodelist
+		{`$.empty.first()`, nil},
+		{`$.store.book[?@.price > 20].price.last()`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapFunction(t *testing.T) {
+	data := `{"users": [
+		{"name": "ann", "address": {"city": "Oslo"}, "tags": ["a", "b"]},
+		{"name": "bob", "tags": []},
+		{"name": "cy", "address": {"city": "Rome"}, "tags": ["c"]},
+		7
+	]}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.users.map('name')`, []interface{}{[]interface{}{"ann", "bob", "cy"}}},
+		{`$.users.map(@.address.city)`, []interface{}{[]interface{}{"Oslo", "Rome"}}},
+		{`$.users.map(@.address.city, 'drop')`, []interface{}{[]interface{}{"Oslo", "Rome"}}},
+		{`$.users.map(@.address.city, 'null')`, []interface{}{[]interface{}{"Oslo", nil, "Rome", nil}}},
+		// A query selecting several values yields an array of them
+		{`$.users.map(@.tags[*])`, []interface{}{[]interface{}{[]interface{}{"a", "b"}, "c"}}},
+		{`$.users.map(@.tags)`, []interface{}{[]interface{}{[]interface{}{"a", "b"}, []interface{}{}, []interface{}{"c"}}}},
+		{`$.users[?@.tags].tags.map(@)`, []interface{}{
+			[]interface{}{"a", "b"}, []interface{}{}, []interface{}{"c"},
+		}},
+		{`$.users.map('name').sort().last()`, []interface{}{"cy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{`$.users.map('name', 'skip')`, `$.users.map(1)`, `$.users[0].map('name')`} {
+		if _, err := Query(data, path); err == nil {
+			t.Errorf("Query(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestNumericAggregates(t *testing.T) {
+	data := `{
+		"odd": [7, 1, 3],
+		"even": [4, 1, 3, 2],
+		"mixed": [10, "x", null, "20", true, 30],
+		"latency": [{"ms": 120}, {"ms": 80}, {"ms": 100}],
+		"empty": [],
+		"words": ["a", "b"],
+		"tags": ["a", "b", "c", "b", "a", "b"],
+		"points": [{"x": 1}, [1], "p", {"x": 1}, [1]],
+		"tens": [50, 10, 40, 20, 30]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.odd.median()`, []interface{}{3.0}},
+		{`$.even.median()`, []interface{}{2.5}},
+		// Values that are not numbers are skipped, as by avg()
+		{`$.mixed.median()`, []interface{}{20.0}},
+		{`$.latency[*].ms.median()`, []interface{}{100.0}},
+		{`$.even.variance()`, []interface{}{1.25}},
+		{`$.even.variance('sample')`, []interface{}{5.0 / 3}},
+		{`$.even.stddev()`, []interface{}{math.Sqrt(1.25)}},
+		{`$.mixed.variance()`, []interface{}{200.0 / 3}},
+		{`$.mixed.stddev('population')`, []interface{}{math.Sqrt(200.0 / 3)}},
+		{`$.latency[*].ms.variance('sample')`, []interface{}{400.0}},
+		{`$.odd.product()`, []interface{}{21.0}},
+		{`$.mixed.product()`, []interface{}{6000.0}},
+		{`$.latency[*].ms.product()`, []interface{}{960000.0}},
+		{`$.even.mode()`, []interface{}{[]interface{}{4.0, 1.0, 3.0, 2.0}}},
+		{`$.tags.mode()`, []interface{}{[]interface{}{"b"}}},
+		{`$.points.mode()`, []interface{}{[]interface{}{map[string]interface{}{"x": 1.0}, []interface{}{1.0}}}},
+		{`$.empty.mode()`, []interface{}{[]interface{}{}}},
+		{`$.latency[*].ms.mode()`, []interface{}{[]interface{}{120.0, 80.0, 100.0}}},
+		{`$.even.percentile(50)`, []interface{}{2.5}},
+		{`$.even.quantile(0.5)`, []interface{}{2.5}},
+		{`$.tens.percentile(62.5)`, []interface{}{35.0}},
+		{`$.tens.percentile(62.5, 'linear')`, []interface{}{35.0}},
+		{`$.tens.percentile(62.5, 'lower')`, []interface{}{30.0}},
+		{`$.tens.percentile(62.5, 'higher')`, []interface{}{40.0}},
+		{`$.tens.percentile(62.5, 'midpoint')`, []interface{}{35.0}},
+		{`$.tens.percentile(62.5, 'nearest')`, []interface{}{30.0}},
+		{`$.tens.percentile(87.5, 'nearest')`, []interface{}{50.0}},
+		{`$.tens.percentile(0)`, []interface{}{10.0}},
+		{`$.tens.quantile(1)`, []interface{}{50.0}},
+		{`$.latency[*].ms.percentile(50)`, []interface{}{100.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{
+		`$.empty.median()`,
+		`$.empty.product()`,
+		`$.words.product()`,
+		`$.even.percentile(101)`,
+		`$.even.quantile(-0.1)`,
+		`$.even.quantile('half')`,
+		`$.even.quantile(0.5, 'cubic')`,
+		`$.empty.percentile(50)`,
+		`$.words.median()`,
+		`$.odd[0].median()`,
+		`$.odd.variance('all')`,
+		`$.latency[:1].ms.stddev('sample')`,
+	} {
+		if _, err := Query(data, path); err == nil {
+			t.Errorf("Query(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestMathFunctions(t *testing.T) {
+	data := `{
+		"delta": -3.5,
+		"prices": [1.234, 5.678, -2.5],
+		"items": [{"price": 9.99}, {"price": 20.01}],
+		"mixed": [1, "2"]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.delta.abs()`, []interface{}{3.5}},
+		{`$.delta.ceil()`, []interface{}{-3.0}},
+		{`$.delta.floor()`, []interface{}{-4.0}},
+		{`$.delta.round()`, []interface{}{-4.0}},
+		{`$.prices.abs()`, []interface{}{[]interface{}{1.234, 5.678, 2.5}}},
+		{`$.prices.round(2)`, []interface{}{[]interface{}{1.23, 5.68, -2.5}}},
+		{`$.prices.round()`, []interface{}{[]interface{}{1.0, 6.0, -3.0}}},
+		{`$.prices.ceil()`, []interface{}{[]interface{}{2.0, 6.0, -2.0}}},
+		{`$.items[*].price.round()`, []interface{}{10.0, 20.0}},
+		{`$.items[?round(@.price) == 10].price`, []interface{}{9.99}},
+		{`$.items[?floor(@.price) > 9].price`, []interface{}{20.01}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{`$.mixed.abs()`, `$.items.floor()`, `$.delta.round(1.5)`, `$.delta.round('2')`} {
+		if _, err := Query(data, path); err == nil {
+			t.Errorf("Query(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestConversionFunctions(t *testing.T) {
+	data := `{
+		"ids": ["42", " 7 ", "x", 3, true, null, "1e2", "NaN", "0x10"],
+		"price": "19.90",
+		"nums": [1, 2.5, 1e21],
+		"misc": [true, null, {"a": 1}, [1, "b"], "s"],
+		"items": [{"qty": "2"}, {"qty": "10"}, {"qty": 5}]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.ids.to_number()`, []interface{}{[]interface{}{42.0, 7.0, nil, 3.0, 1.0, nil, 100.0, nil, nil}}},
+		{`$.ids.to_number().max()`, []interface{}{100.0}},
+		{`$.price.to_number()`, []interface{}{19.9}},
+		{`$.nums.to_string()`, []interface{}{[]interface{}{"1", "2.5", "1e+21"}}},
+		{`$.misc.to_string()`, []interface{}{[]interface{}{"true", "null", `{"a":1}`, `[1,"b"]`, "s"}}},
+		{`$.nums[1].to_string()`, []interface{}{"2.5"}},
+		{`$.items[?to_number(@.qty) > 4].qty`, []interface{}{"10", 5.0}},
+		{`$.items[?to_string(@.qty) == '5'].qty`, []interface{}{5.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimFunctions(t *testing.T) {
+	data := `{
+		"name": "  Ann Lee \t",
+		"tags": [" a ", "b  ", 3, null],
+		"ids": ["id-1", "id-2", "x-3"],
+		"files": ["a.json", "b.txt"],
+		"users": [{"status": " active"}, {"status": "inactive "}]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.name.trim()`, []interface{}{"Ann Lee"}},
+		{`$.tags.trim()`, []interface{}{[]interface{}{"a", "b", 3.0, nil}}},
+		{`$.ids.trimPrefix('id-')`, []interface{}{[]interface{}{"1", "2", "x-3"}}},
+		{`$.ids[0].trimPrefix('id-')`, []interface{}{"1"}},
+		{`$.files.trimSuffix('.json')`, []interface{}{[]interface{}{"a", "b.txt"}}},
+		{`$.users[?trim(@.status) == 'active'].status`, []interface{}{" active"}},
+		{`$.users[*].status.trim()`, []interface{}{"active", "inactive"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{`$.users[0].trim()`, `$.ids.trimPrefix()`, `$.ids.trimSuffix(1)`} {
+		if _, err := Query(data, path); err == nil {
+			t.Errorf("Query(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestSplitFunction(t *testing.T) {
+	data := `{"csv": "a,b,c,d", "path": "usr/local/bin", "word": "héllo", "rows": [{"tags": "x;y"}, {"tags": "z"}]}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.csv.split(',')`, []interface{}{[]interface{}{"a", "b", "c", "d"}}},
+		{`$.csv.split(',')[2]`, []interface{}{"c"}},
+		{`$.csv.split(',')[-1]`, []interface{}{"d"}},
+		{`$.csv.split(',').length()`, []interface{}{4.0}},
+		{`$.path.split('/')[1:]`, []interface{}{"local", "bin"}},
+		{`$.csv.split('|')`, []interface{}{[]interface{}{"a,b,c,d"}}},
+		{`$.word.split('')`, []interface{}{[]interface{}{"h", "é", "l", "l", "o"}}},
+		{`$.rows[*].tags.split(';')[0]`, []interface{}{"x", "z"}},
+		{`$.rows[?length(split(@.tags, ';')) > 1].tags`, []interface{}{"x;y"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{`$.rows.split(',')`, `$.csv.split()`, `$.csv.split(1)`} {
+		if _, err := Query(data, path); err == nil {
+			t.Errorf("Query(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestJoinFunction(t *testing.T) {
+	data := `{
+		"store": {"book": [{"author": "Rees"}, {"author": "Waugh"}, {"author": "Melville"}]},
+		"parts": ["a", 1, 2.5, true, null, "z"],
+		"csv": "a,b,c",
+		"nested": [["a"], "b"],
+		"rows": [["a", "b"], ["c"]]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.store.book.map('author').join('; ')`, []interface{}{"Rees; Waugh; Melville"}},
+		{`$.rows[*].join(',')`, []interface{}{"a,b", "c"}},
+		{`$.parts.join('-')`, []interface{}{"a-1-2.5-true--z"}},
+		{`$.parts.join('')`, []interface{}{"a12.5truez"}},
+		{`$.csv.split(',').join(' | ')`, []interface{}{"a | b | c"}},
+		{`$.store.book[?@.author == 'none'].author.join(',')`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{`$.nested.join(',')`, `$.parts.join()`, `$.csv.join(',')`} {
+		if _, err := Query(data, path); err == nil {
+			t.Errorf("Query(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestStringPredicateSegments(t *testing.T) {
+	data := `{
+		"name": "dev-api",
+		"hosts": [{"name": "dev-api"}, {"name": "prod-api"}, {"name": "dev-web"}, {"name": 5}],
+		"tags": ["a", "b"]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.name.startsWith('dev-')`, []interface{}{true}},
+		{`$.name.endsWith('-web')`, []interface{}{false}},
+		{`$.name.contains('v-a')`, []interface{}{true}},
+		{`$.tags.contains('b')`, []interface{}{true}},
+		{`$.hosts[*].name.startsWith('dev-')`, []interface{}{true, false, true, false}},
+		{`$.hosts[*].name.startsWith('dev-').occurrences(true)`, []interface{}{2.0}},
+		{`$.hosts[*].name.endsWith('-api').occurrences(false)`, []interface{}{2.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConcatFunction(t *testing.T) {
+	data := `{
+		"name": "Ann",
+		"users": [
+			{"first": "Ann", "last": "Lee", "age": 30},
+			{"first": "Bob", "last": "Ray", "age": null},
+			{"first": "Cy"}
+		]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.users[*].concat(@.last, ', ', @.first)`, []interface{}{"Lee, Ann", "Ray, Bob", ", Cy"}},
+		{`$.users[0].concat(@.first, ' (', @.age, ')')`, []interface{}{"Ann (30)"}},
+		{`$.users[1].concat(@.first, ' (', @.age, ')')`, []interface{}{"Bob ()"}},
+		{`$.name.concat('!')`, []interface{}{"Ann!"}},
+		{`$.name.concat(@, ' ', @)`, []interface{}{"Ann Ann"}},
+		{`$.users[?concat(@.first, ' ', @.last) == 'Bob Ray'].age`, []interface{}{nil}},
+		{`concat($.users[0].first, '-', $.users[1].first)`, []interface{}{"Ann-Bob"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateFunctions(t *testing.T) {
+	data := `{
+		"events": [
+			{"id": 1, "ts": "2024-03-01T08:00:00Z"},
+			{"id": 2, "ts": "2024-03-02T09:30:00+01:00"},
+			{"id": 3, "ts": "2024-02-20T12:00:00Z"},
+			{"id": 4, "ts": 1709308800},
+			{"id": 5, "ts": "yesterday"}
+		],
+		"day": "01/03/2024",
+		"precise": "2024-03-01T08:00:00.25Z"
+	}`
+
+	saved := timeNow
+	timeNow = func() time.Time { return time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC) }
+	defer func() { timeNow = saved }()
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.events[0].ts.parse_date()`, []interface{}{1709280000.0}},
+		{`$.events[1].ts.parse_date()`, []interface{}{1709368200.0}},
+		{`$.events[3].ts.parse_date()`, []interface{}{1709308800.0}},
+		{`$.precise.parse_date()`, []interface{}{1709280000.25}},
+		{`$.day.parse_date('02/01/2006')`, []interface{}{1709251200.0}},
+		{`$.events[0].ts.date_add('1h')`, []interface{}{1709283600.0}},
+		{`$.events[0].ts.date_add('-1.5d')`, []interface{}{1709150400.0}},
+		{`$.events[3].ts.date_add(60)`, []interface{}{1709308860.0}},
+		{`$.events[?parse_date(@.ts) > date_add(now(), '-24h')].id`, []interface{}{2.0, 4.0}},
+		{`$.events[?parse_date(@.ts) <= date_add(now(), '-7d')].id`, []interface{}{3.0}},
+		{`$.events[?parse_date(@.ts) < now()].id`, []interface{}{1.0, 2.0, 3.0, 4.0}},
+		{`$.events[0:4].ts.format_date('2006-01-02 15:04')`, []interface{}{"2024-03-01 08:00", "2024-03-02 08:30", "2024-02-20 12:00", "2024-03-01 16:00"}},
+		{`$.precise.format_date('15:04:05.000')`, []interface{}{"08:00:00.250"}},
+		{`$.events[0].ts.date_add('-1d').format_date('Jan 2')`, []interface{}{"Feb 29"}},
+		{`$.events[?format_date(@.ts, '2006-01') == '2024-02'].id`, []interface{}{3.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{
+		`$.events[4].ts.parse_date()`,
+		`$.events[0].ts.date_add('soon')`,
+		`$.events[0].id.now()`,
+		`$.events[4].ts.format_date('2006')`,
+	} {
+		if _, err := Query(data, path); err == nil {
+			t.Errorf("Query(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestEntriesFunction(t *testing.T) {
+	data := `{
+		"metrics": {"cpu": 42, "disk": 7, "mem": 12},
+		"empty": {},
+		"list": [1, 2]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.metrics.entries()`, []interface{}{[]interface{}{
+			map[string]interface{}{"key": "cpu", "value": 42.0},
+			map[string]interface{}{"key": "disk", "value": 7.0},
+			map[string]interface{}{"key": "mem", "value": 12.0},
+		}}},
+		{`$.metrics.entries()[?@.value > 10].key`, []interface{}{"cpu", "mem"}},
+		{`$.metrics.to_pairs()[0].value`, []interface{}{42.0}},
+		{`$.metrics.entries().sort_by('value')[*].key`, []interface{}{"disk", "mem", "cpu"}},
+		{`$.empty.entries()`, []interface{}{[]interface{}{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Query(data, `$.list.entries()`); err == nil {
+		t.Error("entries() of an array succeeded, want an error")
+	}
+}
+
+func TestFromEntriesFunction(t *testing.T) {
+	data := `{
+		"metrics": {"cpu": 42, "disk": 7, "mem": 12},
+		"pairs": [{"key": "a", "value": 1}, {"key": "b"}, {"key": "a", "value": [2]}],
+		"bad": [{"key": 1, "value": 2}],
+		"mixed": [{"key": "a", "value": 1}, 3],
+		"groups": [[{"key": "a", "value": 1}], [{"key": "b", "value": 2}]]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.pairs.from_entries()`, []interface{}{map[string]interface{}{"a": []interface{}{2.0}, "b": nil}}},
+		{`$.metrics.entries().from_entries()`, []interface{}{map[string]interface{}{"cpu": 42.0, "disk": 7.0, "mem": 12.0}}},
+		{`$.groups[*].from_entries()`, []interface{}{map[string]interface{}{"a": 1.0}, map[string]interface{}{"b": 2.0}}},
+		{`$.metrics.entries()[?@.key == 'gpu'].from_entries()`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{`$.bad.from_entries()`, `$.mixed.from_entries()`, `$.metrics.from_entries()`} {
+		if _, err := Query(data, path); err == nil {
+			t.Errorf("Query(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestFallbackFunctions(t *testing.T) {
+	data := `{
+		"config": {"retries": 3, "proxy": null},
+		"users": [
+			{"name": "ann", "nick": "annie", "age": 30},
+			{"name": "bob", "nick": null},
+			{"name": "cy"}
+		],
+		"list": [1, null]
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.config.retries.default(5)`, []interface{}{3.0}},
+		{`$.config.timeout.default(30)`, []interface{}{30.0}},
+		{`$.config.proxy.default('none')`, []interface{}{"none"}},
+		{`$.missing.timeout.default(30)`, []interface{}{30.0}},
+		{`$.list[5].default(0)`, []interface{}{0.0}},
+		{`$.list[*].default(0)`, []interface{}{1.0, 0.0}},
+		{`$.users[*].nick.default('-')`, []interface{}{"annie", "-", "-"}},
+		{`$.users[*].coalesce(@.nick, @.name)`, []interface{}{"annie", "bob", "cy"}},
+		{`$.users[*].age.coalesce(0)`, []interface{}{30.0, 0.0, 0.0}},
+		{`$.users[*].email.coalesce()`, nil},
+		{`$..nick.default('-')`, []interface{}{"annie", "-"}},
+		{`$.users[?default(@.age, 0) < 18].name`, []interface{}{"bob", "cy"}},
+		{`$.users[?coalesce(@.nick, @.name) == 'cy'].name`, []interface{}{"cy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	result, err := Query(data, `$.users[2].nick.default('-')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0].Location != "$['users'][2]['nick']" {
+		t.Errorf("default() of a missing member = %v, want one node at $['users'][2]['nick']", result)
+	}
+}
+
+func TestKeysDeepFunction(t *testing.T) {
+	data := `{
+		"records": [
+			{"id": 1, "user": {"name": "ann", "tags": [{"label": "x"}]}},
+			{"id": 2, "user": {"name": "bob", "email": "b@example.com"}, "note": null}
+		],
+		"flat": {"b": 1, "a": 2},
+		"empty": [],
+		"n": 1
+	}`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$.records.keys_deep()`, []interface{}{[]interface{}{"email", "id", "label", "name", "note", "tags", "user"}}},
+		{`$.records[0].keys_deep()`, []interface{}{[]interface{}{"id", "label", "name", "tags", "user"}}},
+		{`$.flat.keys_deep()`, []interface{}{[]interface{}{"a", "b"}}},
+		{`$.empty.keys_deep()`, []interface{}{[]interface{}{}}},
+		{`$.records.keys_deep().length()`, []interface{}{7.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Query(data, `$.n.keys_deep()`); err == nil {
+		t.Error("keys_deep() of a number succeeded, want an error")
+	}
+}
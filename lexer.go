@@ -0,0 +1,349 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// tokenKind identifies the kind of a lexical token
+type tokenKind int
+
+const (
+	tokenEOF      tokenKind = iota
+	tokenRoot               // $
+	tokenCurrent            // @
+	tokenDot                // .
+	tokenDotDot             // ..
+	tokenLBracket           // [
+	tokenRBracket           // ]
+	tokenLParen             // (
+	tokenRParen             // )
+	tokenLBrace             // {
+	tokenRBrace             // }
+	tokenComma              // ,
+	tokenColon              // :
+	tokenStar               // *
+	tokenPlus               // +
+	tokenSlash              // /
+	tokenPercent            // %
+	tokenCaret              // ^
+	tokenQuestion           // ?
+	tokenNot                // !
+	tokenAnd                // &&
+	tokenOr                 // ||
+	tokenPipe               // |
+	tokenEq                 // ==
+	tokenNe                 // !=
+	tokenLt                 // <
+	tokenLe                 // <=
+	tokenGt                 // >
+	tokenGe                 // >=
+	tokenMatch              // =~
+	tokenName               // member name, function name or keyword
+	tokenNumber             // number literal
+	tokenString             // quoted string literal
+	tokenRegex              // /pattern/flags after =~
+	tokenOperator           // symbolic operator added by RegisterOperator
+)
+
+// token is a lexical token. pos and end are byte offsets into the source,
+// so two tokens are adjacent when the end of one is the pos of the next.
+type token struct {
+	kind  tokenKind
+	pos   int
+	end   int
+	text  string // source text of the token
+	value string // unescaped contents of a string literal or regex
+}
+
+// String describes the token for error messages
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of input"
+	}
+	return strconv.Quote(t.text)
+}
+
+// isComparison reports whether the token is a comparison operator
+func (t token) isComparison() bool {
+	return t.kind >= tokenEq && t.kind <= tokenMatch
+}
+
+// punctuation maps operator and delimiter text to token kinds, longest
+// first so that ".." wins over "." and "<=" over "<"
+var punctuation = []struct {
+	text string
+	kind tokenKind
+}{
+	{"..", tokenDotDot},
+	{"&&", tokenAnd},
+	{"||", tokenOr},
+	{"==", tokenEq},
+	{"=~", tokenMatch},
+	{"!=", tokenNe},
+	{"<=", tokenLe},
+	{">=", tokenGe},
+	{"$", tokenRoot},
+	{"@", tokenCurrent},
+	{".", tokenDot},
+	{"[", tokenLBracket},
+	{"]", tokenRBracket},
+	{"(", tokenLParen},
+	{")", tokenRParen},
+	{"{", tokenLBrace},
+	{"}", tokenRBrace},
+	{",", tokenComma},
+	{":", tokenColon},
+	{"*", tokenStar},
+	{"+", tokenPlus},
+	{"/", tokenSlash},
+	{"%", tokenPercent},
+	{"^", tokenCaret},
+	{"|", tokenPipe},
+	{"?", tokenQuestion},
+	{"!", tokenNot},
+	{"<", tokenLt},
+	{">", tokenGt},
+}
+
+// lex splits a JSONPath expression into tokens. Whitespace separates
+// tokens and is otherwise dropped; the parser checks token offsets where
+// RFC 9535 forbids whitespace.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		if isBlank(c) {
+			i++
+			continue
+		}
+		start := i
+		switch {
+		case c == '\'' || c == '"':
+			value, n, err := lexString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			i += n
+			tokens = append(tokens, token{kind: tokenString, pos: start, end: i, text: src[start:i], value: value})
+		case c == '-' || isDigit(c):
+			i++
+			for i < len(src) && (isDigit(src[i]) || src[i] == '.' || src[i] == 'e' || src[i] == 'E' ||
+				((src[i] == '+' || src[i] == '-') && (src[i-1] == 'e' || src[i-1] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, pos: start, end: i, text: src[start:i]})
+		case isNameFirst(c):
+			for i < len(src) && isNameChar(src[i]) {
+				if src[i] < utf8.RuneSelf {
+					i++
+					continue
+				}
+				r, n := utf8.DecodeRuneInString(src[i:])
+				if r == utf8.RuneError && n == 1 {
+					return nil, syntaxError(ErrSyntax, "invalid UTF-8 in member name", src, i)
+				}
+				i += n
+			}
+			i += namespacedCall(src, i)
+			tokens = append(tokens, token{kind: tokenName, pos: start, end: i, text: src[start:i]})
+		default:
+			if op := matchOperatorSymbol(src[i:]); op != "" {
+				i += len(op)
+				tokens = append(tokens, token{kind: tokenOperator, pos: start, end: i, text: op})
+				continue
+			}
+			kind, ok := tokenEOF, false
+			for _, p := range punctuation {
+				if strings.HasPrefix(src[i:], p.text) {
+					kind, ok = p.kind, true
+					i += len(p.text)
+					break
+				}
+			}
+			if !ok {
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return nil, syntaxError(ErrSyntax, fmt.Sprintf("unexpected character %q", r), src, start)
+			}
+			tokens = append(tokens, token{kind: kind, pos: start, end: i, text: src[start:i]})
+			if kind != tokenMatch {
+				continue
+			}
+			// A / after =~ starts a regex literal rather than a division
+			for i < len(src) && isBlank(src[i]) {
+				i++
+			}
+			if i < len(src) && src[i] == '/' {
+				start = i
+				pattern, n, err := lexRegex(src, i)
+				if err != nil {
+					return nil, err
+				}
+				i += n
+				tokens = append(tokens, token{kind: tokenRegex, pos: start, end: i, text: src[start:i], value: pattern})
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(src), end: len(src)}), nil
+}
+
+// namespacedCall returns the length of the ":name" that continues the
+// name ending at src[i] when the two form a namespaced function call such
+// as str:upper(), which is then lexed as a single name, and 0 otherwise
+func namespacedCall(src string, i int) int {
+	if i+1 >= len(src) || src[i] != ':' || !isNameFirst(src[i+1]) || src[i+1] >= utf8.RuneSelf {
+		return 0
+	}
+	j := i + 1
+	for j < len(src) && isNameChar(src[j]) && src[j] < utf8.RuneSelf {
+		j++
+	}
+	if j == len(src) || src[j] != '(' {
+		return 0
+	}
+	return j - i
+}
+
+// lexString scans the string literal starting at src[start] and returns
+// its unescaped value and its length in bytes
+func lexString(src string, start int) (string, int, error) {
+	quote := src[start]
+	var b strings.Builder
+	i := start + 1
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1 - start, nil
+		case c == '\\':
+			r, n, err := lexEscape(src, i, quote)
+			if err != nil {
+				return "", 0, err
+			}
+			b.WriteRune(r)
+			i += n
+		case c < 0x20:
+			return "", 0, syntaxError(ErrSyntax, "control character in string literal", src, i)
+		default:
+			r, n := utf8.DecodeRuneInString(src[i:])
+			if r == utf8.RuneError && n == 1 {
+				return "", 0, syntaxError(ErrSyntax, "invalid UTF-8 in string literal", src, i)
+			}
+			b.WriteRune(r)
+			i += n
+		}
+	}
+	return "", 0, syntaxError(ErrSyntax, "unterminated string literal", src, start)
+}
+
+// lexRegex scans the regex literal /pattern/flags starting at src[start]
+// and returns its pattern and its length in bytes. \/ stands for a slash;
+// other escapes are left to the regexp package. The flags i, m and s
+// become a (?flags) prefix of the pattern.
+func lexRegex(src string, start int) (string, int, error) {
+	var b strings.Builder
+	i := start + 1
+	for ; i < len(src) && src[i] != '/'; i++ {
+		if src[i] == '\\' && i+1 < len(src) {
+			if src[i+1] != '/' {
+				b.WriteByte('\\')
+			}
+			i++
+		}
+		b.WriteByte(src[i])
+	}
+	if i == len(src) {
+		return "", 0, syntaxError(ErrSyntax, "unterminated regular expression", src, start)
+	}
+	i++
+	flags := i
+	for i < len(src) && strings.IndexByte("ims", src[i]) >= 0 {
+		i++
+	}
+	if i < len(src) && isNameChar(src[i]) {
+		return "", 0, syntaxError(ErrSyntax, fmt.Sprintf("invalid regular expression flag %q", src[i]), src, i)
+	}
+	pattern := b.String()
+	if flags < i {
+		pattern = "(?" + src[flags:i] + ")" + pattern
+	}
+	return pattern, i - start, nil
+}
+
+// lexEscape decodes the escape sequence at src[i] inside a string quoted
+// with quote, returning the rune and the length of the sequence
+func lexEscape(src string, i int, quote byte) (rune, int, error) {
+	if i+1 >= len(src) {
+		return 0, 0, syntaxError(ErrSyntax, "unterminated string literal", src, i)
+	}
+	switch c := src[i+1]; c {
+	case 'b':
+		return '\b', 2, nil
+	case 'f':
+		return '\f', 2, nil
+	case 'n':
+		return '\n', 2, nil
+	case 'r':
+		return '\r', 2, nil
+	case 't':
+		return '\t', 2, nil
+	case '/', '\\':
+		return rune(c), 2, nil
+	case 'u':
+		r, err := lexHex4(src, i)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !utf16.IsSurrogate(r) {
+			return r, 6, nil
+		}
+		// A high surrogate must be followed by an escaped low surrogate
+		if r < 0xDC00 && i+7 < len(src) && src[i+6] == '\\' && src[i+7] == 'u' {
+			low, err := lexHex4(src, i+6)
+			if err != nil {
+				return 0, 0, err
+			}
+			if pair := utf16.DecodeRune(r, low); pair != utf8.RuneError {
+				return pair, 12, nil
+			}
+		}
+		return 0, 0, syntaxError(ErrSyntax, "invalid surrogate pair in string literal", src, i)
+	default:
+		if c == quote {
+			return rune(c), 2, nil
+		}
+		return 0, 0, syntaxError(ErrSyntax, fmt.Sprintf("invalid escape sequence \\%c", c), src, i)
+	}
+}
+
+// lexHex4 decodes the four hex digits of the \uXXXX escape at src[i]
+func lexHex4(src string, i int) (rune, error) {
+	if i+6 > len(src) {
+		return 0, syntaxError(ErrSyntax, "invalid unicode escape", src, i)
+	}
+	v, err := strconv.ParseUint(src[i+2:i+6], 16, 16)
+	if err != nil {
+		return 0, syntaxError(ErrSyntax, "invalid unicode escape", src, i)
+	}
+	return rune(v), nil
+}
+
+// isBlank reports whether c is whitespace as defined by RFC 9535
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// isNameFirst reports whether c may start a member name shorthand. Any
+// byte of a multi-byte UTF-8 sequence is accepted, which admits every
+// non-ASCII character as RFC 9535 requires; lex checks the encoding.
+func isNameFirst(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c >= 0x80
+}
+
+func isNameChar(c byte) bool {
+	return isNameFirst(c) || isDigit(c)
+}
@@ -0,0 +1,113 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// OperatorFunc evaluates a custom filter operator. An operand that
+// selects nothing is passed as Nothing.
+type OperatorFunc func(left, right interface{}) bool
+
+// customOperators holds the operators added by RegisterOperator
+var (
+	customOperators   = map[string]OperatorFunc{}
+	customOperatorsMu sync.RWMutex
+)
+
+// reservedOperators are the words and symbols custom operators cannot use
+var reservedOperators = map[string]bool{
+	"true": true, "false": true, "null": true, "not": true, "=~": true,
+	"in": true, "nin": true, "contains": true, "subsetof": true, "anyof": true,
+}
+
+// operatorSymbols are the characters a symbolic operator is made of
+const operatorSymbols = "~#!=<>&|^%*+-/"
+
+// RegisterOperator adds a binary operator to filter expressions, so that
+// after RegisterOperator("olderThan", fn) the filter [?@.age olderThan 30]
+// calls fn with the values of @.age and 30. The result of fn is the result
+// of the comparison, and the operator may be negated with !( ).
+//
+// name is either a word of ASCII letters, digits and underscores, such as
+// olderThan, or a sequence of the symbols ~ # ! = < > & | ^ % * + - / that
+// contains ~ or #, such as ~=. Since the built-in grammar uses neither ~
+// nor #, except in =~, new symbols cannot change the meaning of existing
+// expressions. Built-in operators and operators already registered cannot
+// be redefined. Expressions are checked against the operators registered
+// when they are parsed.
+func RegisterOperator(name string, fn OperatorFunc) error {
+	if fn == nil {
+		return NewError(ErrInvalidArgument, "operator function is nil", name)
+	}
+	if !isOperatorWord(name) && !isOperatorSymbol(name) {
+		return NewError(ErrInvalidArgument, fmt.Sprintf("invalid operator name: %q", name), name)
+	}
+	customOperatorsMu.Lock()
+	defer customOperatorsMu.Unlock()
+	if _, exists := customOperators[name]; exists || reservedOperators[name] {
+		return NewError(ErrInvalidArgument, fmt.Sprintf("operator %s is already defined", name), name)
+	}
+	customOperators[name] = fn
+	return nil
+}
+
+// UnregisterOperator removes an operator added by RegisterOperator.
+// Expressions already compiled keep using it.
+func UnregisterOperator(name string) {
+	customOperatorsMu.Lock()
+	defer customOperatorsMu.Unlock()
+	delete(customOperators, name)
+}
+
+// lookupOperator returns the custom operator registered under name
+func lookupOperator(name string) (OperatorFunc, bool) {
+	customOperatorsMu.RLock()
+	defer customOperatorsMu.RUnlock()
+	fn, ok := customOperators[name]
+	return fn, ok
+}
+
+// matchOperatorSymbol returns the longest symbolic custom operator that
+// src starts with, or "" when there is none
+func matchOperatorSymbol(src string) string {
+	if strings.IndexByte(operatorSymbols, src[0]) < 0 {
+		return ""
+	}
+	customOperatorsMu.RLock()
+	defer customOperatorsMu.RUnlock()
+	longest := ""
+	for name := range customOperators {
+		if len(name) > len(longest) && isOperatorSymbol(name) && strings.HasPrefix(src, name) {
+			longest = name
+		}
+	}
+	return longest
+}
+
+// isOperatorWord reports whether name is a valid word operator
+func isOperatorWord(name string) bool {
+	if name == "" || isDigit(name[0]) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; !isNameChar(c) || c >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// isOperatorSymbol reports whether name is a valid symbolic operator
+func isOperatorSymbol(name string) bool {
+	if name == "" || !strings.ContainsAny(name, "~#") {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if strings.IndexByte(operatorSymbols, name[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package jsonpath
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegisterOperator(t *testing.T) {
+	fuzzy := func(left, right interface{}) bool {
+		l, ok1 := left.(string)
+		r, ok2 := right.(string)
+		return ok1 && ok2 && strings.EqualFold(strings.TrimSpace(l), strings.TrimSpace(r))
+	}
+	olderThan := func(left, right interface{}) bool {
+		l, r, ok := normalizeNumbers(left, right)
+		return ok && l > r
+	}
+	between := func(left, right interface{}) bool {
+		bounds, ok := right.([]interface{})
+		if !ok || len(bounds) != 2 {
+			return false
+		}
+		lo, _ := compareValues(left, ">=", bounds[0])
+		hi, _ := compareValues(left, "<=", bounds[1])
+		return lo && hi
+	}
+	for name, fn := range map[string]OperatorFunc{"~=": fuzzy, "olderThan": olderThan, "between": between} {
+		if err := RegisterOperator(name, fn); err != nil {
+			t.Fatalf("RegisterOperator(%q) error = %v", name, err)
+		}
+		defer UnregisterOperator(name)
+	}
+
+	data := `[
+		{"id": 1, "name": " Alice ", "age": 40},
+		{"id": 2, "name": "bob", "age": 25},
+		{"id": 3, "name": "ALICE", "age": 31}
+	]`
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{`$[?@.name ~= 'alice'].id`, []interface{}{1.0, 3.0}},
+		{`$[?@.name~='alice'].id`, []interface{}{1.0, 3.0}},
+		{`$[?@.age olderThan 30].id`, []interface{}{1.0, 3.0}},
+		{`$[?!(@.age olderThan 30) || @.name ~= 'Alice'].id`, []interface{}{1.0, 2.0, 3.0}},
+		{`$[?@.age between [25, 35]].id`, []interface{}{2.0, 3.0}},
+		{`$[?@.missing olderThan 0].id`, nil},
+		// Built-in operators and string contents are unaffected
+		{`$[?@.name =~ 'b'].id`, []interface{}{2.0}},
+		{`$[?@.name == '~='].id`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := queryValues(t, data, tt.path); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	// Compiled expressions keep working after the operator is removed
+	c := MustCompile(`$[?@.age olderThan 30].id`)
+	if got := MustCompile(`$[?@.name ~= 'bob']`).String(); got != `$[?@['name'] ~= 'bob']` {
+		t.Errorf("String() = %q", got)
+	}
+	UnregisterOperator("olderThan")
+	if result, err := c.Execute(data); err != nil || len(result) != 2 {
+		t.Errorf("Execute() after UnregisterOperator = %v, %v, want two nodes", result, err)
+	}
+	if _, err := Compile(`$[?@.age olderThan 30]`); err == nil {
+		t.Error("Compile() with an unregistered operator succeeded")
+	}
+}
+
+func TestRegisterOperatorErrors(t *testing.T) {
+	never := func(left, right interface{}) bool { return false }
+	if err := RegisterOperator("isNear", never); err != nil {
+		t.Fatalf("RegisterOperator() error = %v", err)
+	}
+	defer UnregisterOperator("isNear")
+
+	tests := []struct {
+		name string
+		fn   OperatorFunc
+	}{
+		{"isNear", never}, // already registered
+		{"in", never},     // keyword
+		{"null", never},   // literal
+		{"=~", never},     // built-in symbol
+		{"==", never},     // no ~ or #
+		{"<>", never},     // no ~ or #
+		{"~ =", never},    // space
+		{"1st", never},    // starts with a digit
+		{"größer", never}, // non-ASCII
+		{"", never},       // empty
+		{"matches", nil},  // nil function
+	}
+	for _, tt := range tests {
+		err := RegisterOperator(tt.name, tt.fn)
+		if jpErr, ok := err.(*Error); !ok || jpErr.Type != ErrInvalidArgument {
+			t.Errorf("RegisterOperator(%q) error = %v, want ErrInvalidArgument", tt.name, err)
+		}
+	}
+}
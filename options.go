@@ -0,0 +1,314 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Option configures how a query is evaluated
+type Option interface {
+	apply(*options)
+}
+
+// optionFunc adapts a function to the Option interface
+type optionFunc func(*options)
+
+func (f optionFunc) apply(o *options) { f(o) }
+
+// options holds the evaluation settings collected from Option values
+type options struct {
+	caseInsensitiveNames bool
+	caseInsensitiveComps bool
+	strictComparisons    bool
+	objectFilterSelf     bool
+	timeLayouts          []string
+	truthiness           bool
+	stringSlices         bool
+	maxDepth             int
+	maxResults           int
+	maxSteps             int
+	params               map[string]interface{}
+	parse                parseOptions
+}
+
+// WithCaseInsensitiveNames makes member-name selectors match object keys
+// regardless of case, so $.Store.Book matches {"store":{"book":...}}.
+// An exact match is always preferred; otherwise the first case-folded match
+// in key order is selected.
+func WithCaseInsensitiveNames() Option {
+	return optionFunc(func(o *options) {
+		o.caseInsensitiveNames = true
+	})
+}
+
+// WithCaseInsensitiveComparisons makes filter comparisons ignore the case
+// of strings, so [?@.status == 'active'] matches "Active" and "ACTIVE".
+// It applies to ==, !=, <, <=, > and >= and to the keyword operators such
+// as in and contains, including strings inside compared arrays and
+// objects. Regex matches and function calls are not affected.
+func WithCaseInsensitiveComparisons() Option {
+	return optionFunc(func(o *options) {
+		o.caseInsensitiveComps = true
+	})
+}
+
+// WithStrictComparisons makes a filter comparison with <, <=, > or >=
+// between values of different types, such as a string and a number, fail
+// the query with ErrEvaluation. By default, as RFC 9535 requires, such a
+// comparison is false and the filter moves on to the next node. Absent
+// values are not affected.
+func WithStrictComparisons() Option {
+	return optionFunc(func(o *options) {
+		o.strictComparisons = true
+	})
+}
+
+// WithObjectFilterSelf makes a filter selector applied to an object test
+// the object itself, with @ bound to it, and select the whole object when
+// the expression is true. This is the behavior of earlier releases. By
+// default, as RFC 9535 requires, the filter tests each member value and
+// selects those for which the expression is true. Arrays are not affected.
+func WithObjectFilterSelf() Option {
+	return optionFunc(func(o *options) {
+		o.objectFilterSelf = true
+	})
+}
+
+// WithTimeComparisons makes filter comparisons between two strings that
+// are both timestamps compare them chronologically rather than lexically,
+// so "2024-01-01T01:00:00+01:00" == "2024-01-01T00:00:00Z". Each string is
+// parsed with the first of layouts, in the format of time.Parse, that
+// accepts it; with no layouts RFC 3339 is used. A timestamp compared with
+// any other value is neither equal, less nor greater, and comparisons
+// without a timestamp are unaffected.
+func WithTimeComparisons(layouts ...string) Option {
+	if len(layouts) == 0 {
+		layouts = []string{time.RFC3339}
+	}
+	layouts = append([]string(nil), layouts...)
+	return optionFunc(func(o *options) {
+		o.timeLayouts = layouts
+	})
+}
+
+// WithTruthiness makes an existence test in a filter, such as
+// [?@.active], test whether a selected value is truthy as in JavaScript:
+// false, 0, "" and null are falsy, and so is a query that selects nothing;
+// every other value, including empty arrays and objects, is truthy. By
+// default, as RFC 9535 requires, the test only checks that the query
+// selects a node, whatever its value. exists() is not affected.
+func WithTruthiness() Option {
+	return optionFunc(func(o *options) {
+		o.truthiness = true
+	})
+}
+
+// WithStringSlices makes slice selectors applied to a string select a
+// substring, so $.name[0:3] yields the first three characters of name.
+// Indices count Unicode characters, and the substring is reported at the
+// location of the string. Without this option, as RFC 9535 requires, a
+// slice selects nothing from a string.
+func WithStringSlices() Option {
+	return optionFunc(func(o *options) {
+		o.stringSlices = true
+	})
+}
+
+// WithMaxDepth limits how many levels below its starting node a recursive
+// descent segment may traverse. Zero means no limit.
+func WithMaxDepth(n int) Option {
+	return optionFunc(func(o *options) {
+		o.maxDepth = n
+	})
+}
+
+// WithMaxResults limits the number of nodes any intermediate or final
+// nodelist may hold. Zero means no limit.
+func WithMaxResults(n int) Option {
+	return optionFunc(func(o *options) {
+		o.maxResults = n
+	})
+}
+
+// WithMaxSteps limits the total number of nodes visited during evaluation,
+// including nodes visited inside filter expressions. Zero means no limit.
+func WithMaxSteps(n int) Option {
+	return optionFunc(func(o *options) {
+		o.maxSteps = n
+	})
+}
+
+// WithMaxPathLength limits the length in bytes of the expressions accepted
+// by Compile, Query and Parse. Longer expressions fail with ErrSyntax before
+// they are parsed. Zero means no limit.
+func WithMaxPathLength(n int) Option {
+	return optionFunc(func(o *options) {
+		o.parse.maxLength = n
+	})
+}
+
+// WithMaxNesting limits how deeply brackets, parentheses and braces may
+// nest in an expression, failing with ErrSyntax at parse time. Zero means
+// no limit.
+func WithMaxNesting(n int) Option {
+	return optionFunc(func(o *options) {
+		o.parse.maxNesting = n
+	})
+}
+
+// WithMaxFilterDepth limits how deeply filter selectors may nest in an
+// expression, so $[?@[?@.a]] has depth 2, failing with ErrSyntax at parse
+// time. Zero means no limit.
+func WithMaxFilterDepth(n int) Option {
+	return optionFunc(func(o *options) {
+		o.parse.maxFilterDepth = n
+	})
+}
+
+// WithArithmetic enables arithmetic in filter expressions, so operands of
+// comparisons and function arguments may combine numbers with +, -, *, /
+// and %, e.g. $[?@.price * @.quantity > 100]. * / and % bind more tightly
+// than + and -, and parentheses group. An operation on a value that is not
+// a number, or one without a finite result such as a division by zero,
+// yields Nothing. Like the parse limits, it applies when the expression is
+// parsed.
+func WithArithmetic() Option {
+	return optionFunc(func(o *options) {
+		o.parse.arithmetic = true
+	})
+}
+
+// WithFunctions makes the functions of r available to the query, taking
+// precedence over global functions of the same name. Since expressions are
+// checked for unknown functions when they are parsed, give it to Compile
+// rather than Execute.
+func WithFunctions(r *Registry) Option {
+	return optionFunc(func(o *options) {
+		o.parse.functions = r
+	})
+}
+
+// evalContext carries the state of a single query evaluation
+type evalContext struct {
+	opts  options
+	steps int
+	err   error       // sticky error, survives errors swallowed by filters
+	key   interface{} // member name or array index a filter is testing, or nil
+}
+
+// newEvalContext creates an evaluation context from the given options
+func newEvalContext(opts []Option) *evalContext {
+	ctx := &evalContext{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.apply(&ctx.opts)
+		}
+	}
+	return ctx
+}
+
+// evaluate runs segments against data, starting from the root node
+func (ctx *evalContext) evaluate(segments []segmentV3, data interface{}) (NodeList, error) {
+	return ctx.evaluateFrom(segments, Node{Location: "$", Value: data, Root: data})
+}
+
+// evaluateFrom runs segments starting from the given node
+func (ctx *evalContext) evaluateFrom(segments []segmentV3, start Node) (NodeList, error) {
+	return ctx.evaluateList(segments, NodeList{start})
+}
+
+// evaluateList runs segments starting from the nodes of nodeList
+func (ctx *evalContext) evaluateList(segments []segmentV3, nodeList NodeList) (NodeList, error) {
+	for _, seg := range segments {
+		if s, ok := seg.(nodeListSegment); ok {
+			evaluated, err := s.evaluateAll(ctx, nodeList)
+			if ctx.err != nil {
+				return nil, ctx.err
+			}
+			if err != nil {
+				return nil, err
+			}
+			nodeList = evaluated
+			continue
+		}
+		var newNodeList NodeList
+		for _, n := range nodeList {
+			if err := ctx.step(); err != nil {
+				return nil, err
+			}
+			evaluated, err := seg.evaluate(ctx, n)
+			if ctx.err != nil {
+				return nil, ctx.err
+			}
+			if err != nil {
+				return nil, err
+			}
+			newNodeList = append(newNodeList, evaluated...)
+			if err := ctx.checkResults(len(newNodeList)); err != nil {
+				return nil, err
+			}
+		}
+		nodeList = newNodeList
+	}
+	return nodeList, nil
+}
+
+// step records one unit of evaluation work against the step limit
+func (ctx *evalContext) step() error {
+	if ctx.err != nil {
+		return ctx.err
+	}
+	ctx.steps++
+	if ctx.opts.maxSteps > 0 && ctx.steps > ctx.opts.maxSteps {
+		return ctx.fail(fmt.Sprintf("evaluation step limit exceeded: %d", ctx.opts.maxSteps))
+	}
+	return nil
+}
+
+// checkResults checks a nodelist size against the result limit
+func (ctx *evalContext) checkResults(n int) error {
+	if ctx.opts.maxResults > 0 && n > ctx.opts.maxResults {
+		return ctx.fail(fmt.Sprintf("result limit exceeded: %d", ctx.opts.maxResults))
+	}
+	return nil
+}
+
+// checkDepth checks a recursive descent depth against the depth limit
+func (ctx *evalContext) checkDepth(depth int) error {
+	if ctx.opts.maxDepth > 0 && depth > ctx.opts.maxDepth {
+		return ctx.fail(fmt.Sprintf("recursion depth limit exceeded: %d", ctx.opts.maxDepth))
+	}
+	return nil
+}
+
+// fail records a limit error so that it aborts the whole evaluation
+func (ctx *evalContext) fail(msg string) error {
+	return ctx.abort(NewError(ErrLimitExceeded, msg, ""))
+}
+
+// abort records err so that it aborts the whole evaluation, even when
+// raised inside a filter that would otherwise swallow it
+func (ctx *evalContext) abort(err error) error {
+	if ctx.err == nil {
+		ctx.err = err
+	}
+	return ctx.err
+}
+
+// lookupMember returns the value of the member name in obj along with the
+// key actually matched, honouring the case-insensitive option
+func (ctx *evalContext) lookupMember(obj map[string]interface{}, name string) (interface{}, string, bool) {
+	if val, exists := obj[name]; exists {
+		return val, name, true
+	}
+	if ctx == nil || !ctx.opts.caseInsensitiveNames {
+		return nil, "", false
+	}
+	for _, k := range sortedKeys(obj) {
+		if strings.EqualFold(k, name) {
+			return obj[k], k, true
+		}
+	}
+	return nil, "", false
+}
@@ -0,0 +1,639 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWithCaseInsensitiveNames(t *testing.T) {
+	data := map[string]interface{}{
+		"store": map[string]interface{}{
+			"book": []interface{}{
+				map[string]interface{}{"Title": "Sayings", "price": 8.95},
+				map[string]interface{}{"title": "Sword", "price": 12.99},
+			},
+		},
+		"Exact": 1,
+		"exact": 2,
+	}
+
+	tests := []struct {
+		name      string
+		path      string
+		opts      []Option
+		wantLocs  []string
+		wantValue []interface{}
+	}{
+		{
+			name:      "mixed case path",
+			path:      "$.Store.BOOK[0].title",
+			opts:      []Option{WithCaseInsensitiveNames()},
+			wantLocs:  []string{"$['store']['book'][0]['Title']"},
+			wantValue: []interface{}{"Sayings"},
+		},
+		{
+			name:      "without option",
+			path:      "$.Store.Book",
+			wantLocs:  []string{},
+			wantValue: []interface{}{},
+		},
+		{
+			name:      "exact match preferred",
+			path:      "$.exact",
+			opts:      []Option{WithCaseInsensitiveNames()},
+			wantLocs:  []string{"$['exact']"},
+			wantValue: []interface{}{2},
+		},
+		{
+			name:      "bracket names",
+			path:      "$['STORE']['book'][*]['TITLE']",
+			opts:      []Option{WithCaseInsensitiveNames()},
+			wantLocs:  []string{"$['store']['book'][0]['Title']", "$['store']['book'][1]['title']"},
+			wantValue: []interface{}{"Sayings", "Sword"},
+		},
+		{
+			name:      "filter fields",
+			path:      "$.store.book[?@.PRICE > 10].TITLE",
+			opts:      []Option{WithCaseInsensitiveNames()},
+			wantLocs:  []string{"$['store']['book'][1]['title']"},
+			wantValue: []interface{}{"Sword"},
+		},
+		{
+			name:      "function arguments",
+			path:      "$.STORE.book.map(@.TITLE)",
+			opts:      []Option{WithCaseInsensitiveNames()},
+			wantLocs:  []string{"$['store']['book']"},
+			wantValue: []interface{}{[]interface{}{"Sayings", "Sword"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Query(data, tt.path, tt.opts...)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			locs := make([]string, 0, len(result))
+			values := make([]interface{}, 0, len(result))
+			for _, n := range result {
+				locs = append(locs, n.Location)
+				values = append(values, n.Value)
+			}
+			if !reflect.DeepEqual(locs, tt.wantLocs) {
+				t.Errorf("locations = %v, want %v", locs, tt.wantLocs)
+			}
+			if !reflect.DeepEqual(values, tt.wantValue) {
+				t.Errorf("values = %v, want %v", values, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestEvaluationLimits(t *testing.T) {
+	// Build a document that is 10 levels deep and 3 wide at every level
+	var build func(depth int) interface{}
+	build = func(depth int) interface{} {
+		if depth == 0 {
+			return 1.0
+		}
+		return []interface{}{build(depth - 1), build(depth - 1), build(depth - 1)}
+	}
+	data := map[string]interface{}{"tree": build(10), "items": []interface{}{1.0, 2.0, 3.0}}
+
+	tests := []struct {
+		name    string
+		path    string
+		opts    []Option
+		wantErr bool
+	}{
+		{"unlimited", "$..*", nil, false},
+		{"max results exceeded", "$..*", []Option{WithMaxResults(100)}, true},
+		{"max results respected", "$.items[*]", []Option{WithMaxResults(3)}, false},
+		{"max steps exceeded", "$..*", []Option{WithMaxSteps(1000)}, true},
+		{"max steps inside filter", "$[?count(@..*) > 0]", []Option{WithMaxSteps(1000)}, true},
+		{"max steps inside map()", "$.tree.map(@..*)", []Option{WithMaxSteps(1000)}, true},
+		{"max steps inside concat()", "$.tree.concat(@..*)", []Option{WithMaxSteps(1000)}, true},
+		{"max steps inside coalesce()", "$.tree.coalesce(@..*)", []Option{WithMaxSteps(1000)}, true},
+		{"max results inside map()", "$.tree.map(@..*)", []Option{WithMaxResults(100)}, true},
+		{"max depth exceeded", "$..*", []Option{WithMaxDepth(5)}, true},
+		{"max depth respected", "$.items..*", []Option{WithMaxDepth(1)}, false},
+		{"max depth inside sort_by()", "$.tree.sort_by(@..*)", []Option{WithMaxDepth(5)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Query(data, tt.path, tt.opts...)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Query() unexpected error = %v", err)
+				}
+				return
+			}
+			jpErr, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("Query() error = %v (%T), want *Error", err, err)
+			}
+			if jpErr.Type != ErrLimitExceeded {
+				t.Errorf("Query() error type = %v, want ErrLimitExceeded", jpErr.Type)
+			}
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		opts       []Option
+		wantOffset int // -1 when the expression is accepted
+	}{
+		{"length respected", "$.a.b", []Option{WithMaxPathLength(5)}, -1},
+		{"length exceeded", "$.a.bc", []Option{WithMaxPathLength(5)}, 5},
+		{"nesting respected", "$[?@.a[0] == 1]", []Option{WithMaxNesting(2)}, -1},
+		{"nesting exceeded", "$[?@.a[?@.b[0]]]", []Option{WithMaxNesting(2)}, 11},
+		{"parentheses count as nesting", "$[?((@.a))]", []Option{WithMaxNesting(2)}, 4},
+		{"filter depth respected", "$[?@[?@.a]]", []Option{WithMaxFilterDepth(2)}, -1},
+		{"filter depth exceeded", "$[?@[?@[?@.a]]]", []Option{WithMaxFilterDepth(2)}, 8},
+		{"unlimited", "$[?@[?@[?@.a]]]", nil, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.path, tt.opts...)
+			if tt.wantOffset < 0 {
+				if err != nil {
+					t.Fatalf("Compile() unexpected error = %v", err)
+				}
+				return
+			}
+			jpErr, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("Compile() error = %v (%T), want *Error", err, err)
+			}
+			if jpErr.Type != ErrSyntax || !strings.Contains(jpErr.Message, "limit exceeded") {
+				t.Errorf("Compile() error = %v (type %v), want ErrSyntax limit exceeded", err, jpErr.Type)
+			}
+			if jpErr.Offset != tt.wantOffset {
+				t.Errorf("Compile() error offset = %d, want %d", jpErr.Offset, tt.wantOffset)
+			}
+			if _, err := Parse(tt.path, tt.opts...); err == nil {
+				t.Error("Parse() expected error")
+			}
+			if _, err := Query(`{}`, tt.path, tt.opts...); err == nil {
+				t.Error("Query() expected error")
+			}
+		})
+	}
+}
+
+func TestWithStringSlices(t *testing.T) {
+	data := `{"name": "Grüße aus Köln", "tags": ["alpha", "beta"], "n": 5}`
+
+	tests := []struct {
+		path string
+		opts []Option
+		want []interface{}
+	}{
+		{"$.name[0:5]", []Option{WithStringSlices()}, []interface{}{"Grüße"}},
+		{"$.name[-4:]", []Option{WithStringSlices()}, []interface{}{"Köln"}},
+		{"$.name[::-1]", []Option{WithStringSlices()}, []interface{}{"nlöK sua eßürG"}},
+		{"$.name[0:5:2]", []Option{WithStringSlices()}, []interface{}{"Güe"}},
+		{"$.name[20:]", []Option{WithStringSlices()}, []interface{}{""}},
+		{"$.name[::0]", []Option{WithStringSlices()}, nil},
+		{"$.tags[*][:2]", []Option{WithStringSlices()}, []interface{}{"al", "be"}},
+		{"$.tags[0:1]", []Option{WithStringSlices()}, []interface{}{"alpha"}},
+		{"$.n[0:1]", []Option{WithStringSlices()}, nil},
+		// RFC 9535: slices select nothing from a string by default
+		{"$.name[0:5]", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path, tt.opts...)
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+
+			streamed, err := MustCompile(tt.path, tt.opts...).ExecuteDecoder(json.NewDecoder(strings.NewReader(data)))
+			if err != nil {
+				t.Fatalf("ExecuteDecoder(%q) error = %v", tt.path, err)
+			}
+			got = nil
+			for _, n := range streamed {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExecuteDecoder(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	result, _ := Query(data, "$.name[0:5]", WithStringSlices())
+	if len(result) != 1 || result[0].Location != "$['name']" {
+		t.Errorf("Query() = %v, want a node located at $['name']", result)
+	}
+}
+
+func TestWithArithmetic(t *testing.T) {
+	data := `[
+		{"id": 1, "price": 10, "quantity": 20, "a": 1, "b": 7},
+		{"id": 2, "price": 5, "quantity": 3, "a": 4, "b": 2},
+		{"id": 3, "price": "x", "quantity": 3}
+	]`
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{path: "$[?@.price * @.quantity > 100].id", want: []interface{}{float64(1)}},
+		{path: "$[?@.a + 5 < @.b].id", want: []interface{}{float64(1)}},
+		{path: "$[?@.a-3 < 0].id", want: []interface{}{float64(1)}},
+		{path: "$[?@.a -3 < 0].id", want: []interface{}{float64(1)}},
+		{path: "$[?@.a - -5 == 6].id", want: []interface{}{float64(1)}},
+		{path: "$[?1 + 2 * 3 == 7 && @.a].id", want: []interface{}{float64(1), float64(2)}},
+		{path: "$[?10 - 4 - 3 == @.b + 1].id", want: []interface{}{float64(2)}},
+		{path: "$[?(@.a + 1) * 2 == 4].id", want: []interface{}{float64(1)}},
+		{path: "$[?(@.a + 1 == 2) || @.b == 2].id", want: []interface{}{float64(1), float64(2)}},
+		{path: "$[?@.b % 2 == 1].id", want: []interface{}{float64(1)}},
+		{path: "$[?@.price / 2 == 5].id", want: []interface{}{float64(1)}},
+		{path: "$[?@.a / 0 > 0].id"},
+		{path: "$[?@.price * 2 > 0].id", want: []interface{}{float64(1), float64(2)}},
+		{path: "$[?@.a + {n} == 3].id", want: []interface{}{float64(1)}},
+		{path: "length($[?@.a * 2 > 1])", want: []interface{}{float64(2)}},
+		{path: "$[?@.a + 1]", wantErr: true},
+		{path: "$[?@.* + 1 == 2]", wantErr: true},
+		{path: "$[?@.a + == 2]", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path, WithArithmetic(), Params{"n": 2})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{"$[?@.price * @.quantity > 100]", "$[?index() % 2 == 0]", "$[?@.a -1 > 2]", "$[?length(@.a + 'b') > 2]"} {
+		_, err := Compile(path)
+		if err == nil || !strings.Contains(err.Error(), "requires WithArithmetic") {
+			t.Errorf("Compile(%q) without WithArithmetic error = %v", path, err)
+		}
+	}
+
+	for path, want := range map[string]string{
+		"$[?(@.a + 1) * 2 == 4]": "$[?(@['a'] + 1) * 2 == 4]",
+		"$[?@.a - (1 - 2) == 4]": "$[?@['a'] - (1 - 2) == 4]",
+		"$[?1 + 2 * @.a == 4]":   "$[?1 + 2 * @['a'] == 4]",
+	} {
+		q, err := Parse(path, WithArithmetic())
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", path, err)
+		}
+		if got := q.String(); got != want {
+			t.Errorf("Parse(%q).String() = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestWithCaseInsensitiveComparisons(t *testing.T) {
+	data := `[
+		{"id": 1, "status": "active", "tags": ["Go"]},
+		{"id": 2, "status": "Active", "tags": ["go", "CLI"]},
+		{"id": 3, "status": "ACTIVE"},
+		{"id": 4, "status": "inactive", "meta": {"owner": "OPS"}},
+		{"id": 5, "status": 1}
+	]`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{"$[?@.status == 'active'].id", []interface{}{float64(1), float64(2), float64(3)}},
+		{"$[?@.status != 'ACTIVE'].id", []interface{}{float64(4), float64(5)}},
+		{"$[?@.status < 'B'].id", []interface{}{float64(1), float64(2), float64(3)}},
+		{"$[?@.status in ['Inactive']].id", []interface{}{float64(4)}},
+		{"$[?@.tags contains 'GO'].id", []interface{}{float64(1), float64(2)}},
+		{"$[?@.tags subsetof ['GO', 'cli']].id", []interface{}{float64(1), float64(2)}},
+		{"$[?@.status contains 'TIVE'].id", []interface{}{float64(1), float64(2), float64(3), float64(4)}},
+		{"$[?@.meta.owner == 'ops'].id", []interface{}{float64(4)}},
+		{"$[?@.status =~ /^active$/].id", []interface{}{float64(1)}},
+		{"$[?@.status == 1].id", []interface{}{float64(5)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path, WithCaseInsensitiveComparisons())
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	result, err := Query(data, "$[?@.status == 'active'].id")
+	if err != nil || len(result) != 1 {
+		t.Errorf("Query() without the option = %v, %v, want one node", result, err)
+	}
+}
+
+func TestWithStrictComparisons(t *testing.T) {
+	data := `[
+		{"id": 1, "version": 3},
+		{"id": 2, "version": "2.1"},
+		{"id": 3}
+	]`
+
+	tests := []struct {
+		path    string
+		want    []interface{}
+		wantErr bool
+	}{
+		{"$[?@.version > 2].id", nil, true},
+		{"$[?@.version <= 'z'].id", nil, true},
+		{"$[?@.version == 3].id", []interface{}{float64(1)}, false},
+		{"$[?@.version != 3].id", []interface{}{float64(2), float64(3)}, false},
+		{"$[?@.missing > 2].id", nil, false},
+		{"$[?@.id > 2].id", []interface{}{float64(3)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path, WithStrictComparisons())
+			if tt.wantErr {
+				if jpErr, ok := err.(*Error); !ok || jpErr.Type != ErrEvaluation {
+					t.Fatalf("Query(%q) error = %v, want ErrEvaluation", tt.path, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	// Without the option a mismatch is false for that node only
+	result, err := Query(data, "$[?@.version > 2].id")
+	if err != nil || len(result) != 1 || result[0].Value != float64(1) {
+		t.Errorf("Query() without the option = %v, %v, want [1]", result, err)
+	}
+}
+
+func TestWithObjectFilterSelf(t *testing.T) {
+	data := `{
+		"config": {"enabled": true, "level": 3, "db": {"enabled": false}},
+		"list": [{"enabled": true}, {"enabled": false}]
+	}`
+
+	tests := []struct {
+		path      string
+		want      []interface{}
+		locations []string
+	}{
+		// By default the filter tests the member values of config
+		{"$.config[?@.enabled == false]", []interface{}{map[string]interface{}{"enabled": false}}, []string{"$['config']['db']"}},
+		{"$.config[?@ == 3]", []interface{}{float64(3)}, []string{"$['config']['level']"}},
+		{"$.list[?@.enabled]", []interface{}{map[string]interface{}{"enabled": true}, map[string]interface{}{"enabled": false}}, []string{"$['list'][0]", "$['list'][1]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path)
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			var locations []string
+			for _, n := range result {
+				got = append(got, n.Value)
+				locations = append(locations, n.Location)
+			}
+			if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(locations, tt.locations) {
+				t.Errorf("Query(%q) = %v at %v, want %v at %v", tt.path, got, locations, tt.want, tt.locations)
+			}
+		})
+	}
+
+	legacy := []struct {
+		path      string
+		locations []string
+	}{
+		// With the option the object itself is tested and selected whole
+		{"$.config[?@.enabled == true]", []string{"$['config']"}},
+		{"$.config[?@.level > 5]", nil},
+		{"$.config[?@.db.enabled == false].level", []string{"$['config']['level']"}},
+		{"$.list[?@.enabled == true]", []string{"$['list'][0]"}},
+		{"$..[?@.level == 3]", []string{"$['config']"}},
+	}
+	for _, tt := range legacy {
+		t.Run("legacy "+tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path, WithObjectFilterSelf())
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var locations []string
+			for _, n := range result {
+				locations = append(locations, n.Location)
+			}
+			if !reflect.DeepEqual(locations, tt.locations) {
+				t.Errorf("Query(%q) locations = %v, want %v", tt.path, locations, tt.locations)
+			}
+		})
+	}
+}
+
+func TestWithTimeComparisons(t *testing.T) {
+	data := `[
+		{"id": 1, "at": "2023-12-31T23:30:00-01:00", "day": "2024-01-01"},
+		{"id": 2, "at": "2024-01-01T00:30:00Z", "day": "2023-12-31"},
+		{"id": 3, "at": "2024-01-01T01:00:00.5+01:00", "day": "31/12/2023"},
+		{"id": 4, "at": "yesterday"},
+		{"id": 5, "at": 1704067200}
+	]`
+
+	tests := []struct {
+		path    string
+		layouts []string
+		want    []interface{}
+	}{
+		// 2023-12-31T23:30:00-01:00 is 2024-01-01T00:30:00Z, although it sorts first as text
+		{"$[?@.at > '2024-01-01T00:00:00Z'].id", nil, []interface{}{float64(1), float64(2), float64(3)}},
+		{"$[?@.at == '2024-01-01T00:30:00Z'].id", nil, []interface{}{float64(1), float64(2)}},
+		{"$[?@.at < '2024-01-01T00:00:01Z'].id", nil, []interface{}{float64(3)}},
+		{"$[?@.at >= @.at].id", nil, []interface{}{float64(1), float64(2), float64(3), float64(4), float64(5)}},
+		// Other strings are compared as usual, but not with timestamps
+		{"$[?@.at > 'x'].id", nil, []interface{}{float64(4)}},
+		{"$[?@.at != '2024-01-01T00:30:00Z'].id", nil, []interface{}{float64(3), float64(4), float64(5)}},
+		// Layouts give the formats accepted
+		{"$[?@.day >= '2024-01-01'].id", []string{"2006-01-02"}, []interface{}{float64(1)}},
+		{"$[?@.day < '2024-01-01'].id", []string{"2006-01-02", "02/01/2006"}, []interface{}{float64(2), float64(3)}},
+		{"$[?@.day == '2023-12-31'].id", []string{"2006-01-02", "02/01/2006"}, []interface{}{float64(2), float64(3)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path, WithTimeComparisons(tt.layouts...))
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	// Without the option timestamps are compared as strings
+	result, err := Query(data, "$[?@.at > '2024-01-01T00:00:00Z'].id")
+	if err != nil || len(result) != 3 {
+		t.Errorf("Query() without the option = %v, %v, want three nodes", result, err)
+	}
+}
+
+func TestWithTruthiness(t *testing.T) {
+	data := `[
+		{"id": 1, "active": true},
+		{"id": 2, "active": false},
+		{"id": 3, "active": 0},
+		{"id": 4, "active": ""},
+		{"id": 5, "active": null},
+		{"id": 6},
+		{"id": 7, "active": "no"},
+		{"id": 8, "active": []},
+		{"id": 9, "active": {}},
+		{"id": 10, "active": -1.5}
+	]`
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{"$[?@.active].id", []interface{}{float64(1), float64(7), float64(8), float64(9), float64(10)}},
+		{"$[?!@.active].id", []interface{}{float64(2), float64(3), float64(4), float64(5), float64(6)}},
+		{"$[?@.active && @.id > 7].id", []interface{}{float64(8), float64(9), float64(10)}},
+		// A query selecting several nodes is truthy when any value is
+		{"$[?@.*].id", []interface{}{float64(1), float64(2), float64(3), float64(4), float64(5), float64(6), float64(7), float64(8), float64(9), float64(10)}},
+		{"$[?@.active.*].id", nil},
+		// Comparisons are not affected
+		{"$[?@.active == false].id", []interface{}{float64(2)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result, err := Query(data, tt.path, WithTruthiness())
+			if err != nil {
+				t.Fatalf("Query(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	// Without the option any existing member passes
+	result, err := Query(data, "$[?@.active].id")
+	if err != nil || len(result) != 9 {
+		t.Errorf("Query() without the option = %d nodes, %v, want 9", len(result), err)
+	}
+}
+
+func TestWithFunctions(t *testing.T) {
+	tax := func(rate float64) Function {
+		return NewTypedFunction("tax", Signature{Params: []ArgType{ArgNumber}},
+			func(args []interface{}) (interface{}, error) {
+				return args[0].(float64) * rate, nil
+			})
+	}
+	tenantA, tenantB := NewRegistry(), NewRegistry()
+	if err := tenantA.Register("tax", tax(0.1)); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := tenantB.Register("tax", tax(0.2)); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	double := NewFunction("length", func(args []interface{}) (interface{}, error) {
+		return 2.0, nil
+	})
+	if err := tenantB.Register("length", double); err == nil {
+		t.Error("Register() replaced a built-in function")
+	}
+	if err := tenantB.Override("length", double); err != nil {
+		t.Fatalf("Override() error = %v", err)
+	}
+
+	data := `{"items": [{"price": 10}, {"price": 50}], "name": "abc"}`
+	tests := []struct {
+		path     string
+		registry *Registry
+		want     []interface{}
+	}{
+		{"$.items[?tax(@.price) >= 2].price", tenantA, []interface{}{float64(50)}},
+		{"$.items[?tax(@.price) >= 2].price", tenantB, []interface{}{float64(10), float64(50)}},
+		{"$.items[?tax(@.price) >= 6].price", tenantA, nil},
+		{"$.items[0].price.tax()", tenantB, []interface{}{float64(2)}},
+		{"tax($.items[1].price)", tenantA, []interface{}{float64(5)}},
+		{"length($.items[?tax(@.price) >= 2])", tenantB, []interface{}{float64(2)}},
+		{"$.name.length()", tenantA, []interface{}{float64(3)}},
+		{"$.name.length()", tenantB, []interface{}{float64(2)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			c, err := Compile(tt.path, WithFunctions(tt.registry))
+			if err != nil {
+				t.Fatalf("Compile(%q) error = %v", tt.path, err)
+			}
+			result, err := c.Execute(data)
+			if err != nil {
+				t.Fatalf("Execute(%q) error = %v", tt.path, err)
+			}
+			var got []interface{}
+			for _, n := range result {
+				got = append(got, n.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Execute(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	// Functions in a registry are not global
+	for _, path := range []string{"$.items[?tax(@.price) > 1]", "$.items[0].price.tax()", "tax($.items[1].price)"} {
+		if _, err := Compile(path); err == nil {
+			t.Errorf("Compile(%q) without WithFunctions found a registry function", path)
+		}
+	}
+	if result, err := Query(data, "$.name.length()"); err != nil || result[0].Value != float64(3) {
+		t.Errorf("Query() without WithFunctions = %v, %v, want the built-in length()", result, err)
+	}
+}
@@ -0,0 +1,130 @@
+package jsonpath
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/davidhoo/jsonpath/ast"
+)
+
+// Params binds values to the named placeholders of an expression, such as
+// {maxPrice} in $.items[?@.price > {maxPrice}]. Params is an Option, so it
+// is passed to Execute or Query along with any other options:
+//
+//	c := jsonpath.MustCompile("$.items[?@.price > {maxPrice}]")
+//	result, err := c.Execute(data, jsonpath.Params{"maxPrice": 10})
+//
+// Values are used as they are, never parsed as JSONPath, so strings need no
+// quoting or escaping. Go integer and float types are compared as JSON
+// numbers. Several Params options are merged; later values win.
+type Params map[string]interface{}
+
+func (p Params) apply(o *options) {
+	merged := make(map[string]interface{}, len(o.params)+len(p))
+	for name, value := range o.params {
+		merged[name] = value
+	}
+	for name, value := range p {
+		merged[name] = normalizeParam(value)
+	}
+	o.params = merged
+}
+
+// paramRef is a named placeholder in a filter expression
+type paramRef struct {
+	name string
+}
+
+func (r paramRef) String() string {
+	return "{" + r.name + "}"
+}
+
+// isValidParamName reports whether name is a valid placeholder name:
+// a letter or underscore followed by letters, digits or underscores
+func isValidParamName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, ch := range name {
+		isLetter := (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+		if !isLetter && (i == 0 || ch < '0' || ch > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeParam converts Go numeric types to float64, the type of JSON
+// numbers in decoded documents
+func normalizeParam(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int8:
+		return float64(v)
+	case int16:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	default:
+		return value
+	}
+}
+
+// resolveParam returns the value bound to a placeholder. An unbound
+// placeholder aborts the evaluation.
+func (ctx *evalContext) resolveParam(ref paramRef) interface{} {
+	if value, ok := ctx.opts.params[ref.name]; ok {
+		return value
+	}
+	ctx.abort(NewError(ErrInvalidArgument, fmt.Sprintf("no value bound to parameter %s", ref), ref.String()))
+	return Nothing{}
+}
+
+// Params returns the names of the placeholders in the compiled expression,
+// sorted and without duplicates
+func (c *Compiled) Params() []string {
+	return append([]string(nil), c.params...)
+}
+
+// collectParams returns the sorted placeholder names used in tree
+func collectParams(tree ast.Node) []string {
+	seen := make(map[string]bool)
+	ast.Inspect(tree, func(n ast.Node) bool {
+		if p, ok := n.(*ast.Parameter); ok {
+			seen[p.Name] = true
+		}
+		return true
+	})
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkParams reports the first placeholder that o binds no value to
+func (c *Compiled) checkParams(o options) error {
+	for _, name := range c.params {
+		if _, ok := o.params[name]; !ok {
+			ref := paramRef{name: name}
+			return NewError(ErrInvalidArgument, fmt.Sprintf("no value bound to parameter %s", ref), ref.String())
+		}
+	}
+	return nil
+}
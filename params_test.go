@@ -0,0 +1,126 @@
+package jsonpath
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParams(t *testing.T) {
+	data := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "it's", "price": 5.0},
+			map[string]interface{}{"name": "b", "price": 15.0},
+			map[string]interface{}{"name": "c", "price": 25.0},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		params Params
+		want   []interface{}
+	}{
+		{
+			name:   "number",
+			path:   "$.items[?@.price > {maxPrice}].name",
+			params: Params{"maxPrice": 10},
+			want:   []interface{}{"b", "c"},
+		},
+		{
+			name:   "float32",
+			path:   "$.items[?@.price == {p}].name",
+			params: Params{"p": float32(25)},
+			want:   []interface{}{"c"},
+		},
+		{
+			name:   "string with quote",
+			path:   "$.items[?@.name == {name}].price",
+			params: Params{"name": "it's"},
+			want:   []interface{}{5.0},
+		},
+		{
+			name:   "string is not parsed as a path",
+			path:   "$.items[?@.name == {name}].price",
+			params: Params{"name": "@.name"},
+			want:   nil,
+		},
+		{
+			name:   "several placeholders",
+			path:   "$.items[?@.price >= {min} && @.price <= {max}].name",
+			params: Params{"min": 10, "max": 20},
+			want:   []interface{}{"b"},
+		},
+		{
+			name:   "function comparison",
+			path:   "$.items[?length(@.name) == {n}].name",
+			params: Params{"n": 4},
+			want:   []interface{}{"it's"},
+		},
+		{
+			name:   "match pattern",
+			path:   "$.items[?match(@.name, {re})].name",
+			params: Params{"re": "[bc]"},
+			want:   []interface{}{"b", "c"},
+		},
+		{
+			name:   "null",
+			path:   "$.items[?@.missing == {v}].name",
+			params: Params{"v": nil},
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Compile(tt.path)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			result, err := c.Execute(data, tt.params)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			var values []interface{}
+			for _, n := range result {
+				values = append(values, n.Value)
+			}
+			if !reflect.DeepEqual(values, tt.want) {
+				t.Errorf("Execute() = %v, want %v", values, tt.want)
+			}
+		})
+	}
+}
+
+func TestParamsMissing(t *testing.T) {
+	c := MustCompile("$.items[?@.price > {maxPrice} || @.name == {name}]")
+	if got, want := c.Params(), []string{"maxPrice", "name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Params() = %v, want %v", got, want)
+	}
+
+	// Unbound placeholders are reported even when no filter is evaluated
+	_, err := c.Execute(map[string]interface{}{}, Params{"maxPrice": 1})
+	var jpErr *Error
+	if !errors.As(err, &jpErr) || jpErr.Type != ErrInvalidArgument {
+		t.Errorf("Execute() error = %v, want ErrInvalidArgument", err)
+	}
+
+	// Several Params options are merged
+	_, err = c.Execute(map[string]interface{}{}, Params{"maxPrice": 1}, Params{"name": "x"})
+	if err != nil {
+		t.Errorf("Execute() with merged params error = %v", err)
+	}
+}
+
+func TestParamsInvalid(t *testing.T) {
+	paths := []string{
+		"$[?@.a == {}]",
+		"$[?@.a == {1x}]",
+		"$[?@.a == {a-b}]",
+	}
+	for _, path := range paths {
+		if _, err := Compile(path); err == nil {
+			t.Errorf("Compile(%q) expected error", path)
+		}
+	}
+}
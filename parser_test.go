@@ -5,6 +5,8 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/davidhoo/jsonpath/ast"
 )
 
 func TestParseRecursive(t *testing.T) {
@@ -18,72 +20,72 @@ func TestParseRecursive(t *testing.T) {
 	}{
 		{
 			name:        "empty path (bare recursive descent)",
-			path:        "",
+			path:        "$..",
 			wantErr:     true,
 			errType:     ErrSyntax,
 			errContains: "bare recursive descent",
 		},
 		{
 			name:    "simple recursive",
-			path:    "name",
+			path:    "$..name",
 			wantLen: 2, // 递归段 + 名称段
 		},
-		{
-			name:    "recursive with dot notation",
-			path:    ".name",
-			wantLen: 2,
-		},
 		{
 			name:    "recursive with nested path",
-			path:    "books.title",
+			path:    "$..books.title",
 			wantLen: 3, // 递归段 + books段 + title段
 		},
 		{
 			name:    "recursive with bracket notation",
-			path:    "[0].name",
+			path:    "$..[0].name",
 			wantLen: 3, // 递归段 + 索引段 + 名称段
 		},
 		{
 			name:    "recursive with filter",
-			path:    "[?(@.price > 10)]",
+			path:    "$..[?(@.price > 10)]",
 			wantLen: 2, // 递归段 + 过滤器段
 		},
 		{
-			name:        "invalid filter syntax",
-			path:        "[?(@.price >=)]",
-			wantErr:     true,
-			errType:     ErrInvalidFilter,
-			errContains: "invalid value",
+			name:    "recursive followed by dot",
+			path:    "$...name",
+			wantErr: true,
+			errType: ErrSyntax,
+		},
+		{
+			name:    "invalid filter syntax",
+			path:    "$..[?(@.price >=)]",
+			wantErr: true,
+			errType: ErrInvalidFilter,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseRecursive(tt.path)
+			c, err := Compile(tt.path)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("parseRecursive() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("Compile() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if err != nil {
 				if jsonErr, ok := err.(*Error); ok {
 					if tt.errType != jsonErr.Type {
-						t.Errorf("parseRecursive() error type = %v, want %v", jsonErr.Type, tt.errType)
+						t.Errorf("Compile() error type = %v, want %v", jsonErr.Type, tt.errType)
 					}
 					if tt.errContains != "" && !strings.Contains(jsonErr.Message, tt.errContains) {
-						t.Errorf("parseRecursive() error = %v, want error containing %v", jsonErr.Message, tt.errContains)
+						t.Errorf("Compile() error = %v, want error containing %v", jsonErr.Message, tt.errContains)
 					}
 				} else {
-					t.Errorf("parseRecursive() error is not a JSONPath error: %v", err)
+					t.Errorf("Compile() error is not a JSONPath error: %v", err)
 				}
 				return
 			}
-			if len(got) != tt.wantLen {
-				t.Errorf("parseRecursive() returned %d segments, want %d", len(got), tt.wantLen)
+			if len(c.segments) != tt.wantLen {
+				t.Errorf("Compile() returned %d segments, want %d", len(c.segments), tt.wantLen)
 			}
 			// 验证第一个段是否为递归段
-			if len(got) > 0 {
-				if _, ok := got[0].(*recursiveSegment); !ok {
-					t.Error("First segment is not a recursiveSegment")
+			if len(c.segments) > 0 {
+				if _, ok := c.segments[0].(*recursiveSegmentV3); !ok {
+					t.Error("First segment is not a recursiveSegmentV3")
 				}
 			}
 		})
@@ -91,10 +93,10 @@ func TestParseRecursive(t *testing.T) {
 }
 
 func TestRecursiveSegmentString(t *testing.T) {
-	seg := &recursiveSegment{}
+	seg := &recursiveSegmentV3{}
 	expected := ".."
 	if got := seg.String(); got != expected {
-		t.Errorf("recursiveSegment.String() = %v, want %v", got, expected)
+		t.Errorf("recursiveSegmentV3.String() = %v, want %v", got, expected)
 	}
 }
 
@@ -102,44 +104,38 @@ func TestParseMultiIndexSegment(t *testing.T) {
 	tests := []struct {
 		name    string
 		content string
-		want    segment
+		want    segmentV3
 		wantErr bool
 	}{
 		{
 			name:    "single index",
 			content: "1",
-			want:    &multiIndexSegment{indices: []int{1}},
-			wantErr: false,
+			want:    &indexSegmentV3{index: 1},
 		},
 		{
 			name:    "multiple indices",
 			content: "1,2,3",
-			want:    &multiIndexSegment{indices: []int{1, 2, 3}},
-			wantErr: false,
+			want:    &multiIndexSegmentV3{indices: []int{1, 2, 3}},
 		},
 		{
 			name:    "negative indices",
 			content: "-1,-2,-3",
-			want:    &multiIndexSegment{indices: []int{-1, -2, -3}},
-			wantErr: false,
+			want:    &multiIndexSegmentV3{indices: []int{-1, -2, -3}},
 		},
 		{
 			name:    "mixed indices",
 			content: "0,1,-1,2,-2",
-			want:    &multiIndexSegment{indices: []int{0, 1, -1, 2, -2}},
-			wantErr: false,
+			want:    &multiIndexSegmentV3{indices: []int{0, 1, -1, 2, -2}},
 		},
 		{
 			name:    "with spaces",
 			content: "1, 2, 3",
-			want:    &multiIndexSegment{indices: []int{1, 2, 3}},
-			wantErr: false,
+			want:    &multiIndexSegmentV3{indices: []int{1, 2, 3}},
 		},
 		{
-			name:    "invalid index",
+			name:    "unquoted name among indices",
 			content: "1,a,3",
-			want:    &unionSegment{selectors: []segment{&indexSegment{index: 1}, &nameSegment{name: "a"}, &indexSegment{index: 3}}},
-			wantErr: false,
+			wantErr: true,
 		},
 		{
 			name:    "empty index",
@@ -159,56 +155,55 @@ func TestParseMultiIndexSegment(t *testing.T) {
 		{
 			name:    "single quoted name",
 			content: "'name'",
-			want:    &multiNameSegment{names: []string{"name"}},
-			wantErr: false,
+			want:    &nameSegmentV3{name: "name"},
 		},
 		{
 			name:    "multiple quoted names",
 			content: "'name','age'",
-			want:    &multiNameSegment{names: []string{"name", "age"}},
-			wantErr: false,
+			want:    &multiNameSegmentV3{names: []string{"name", "age"}},
 		},
 		{
 			name:    "mixed quoted names and indices",
 			content: "'name',1,'age'",
-			want:    &unionSegment{selectors: []segment{&nameSegment{name: "name"}, &indexSegment{index: 1}, &nameSegment{name: "age"}}},
-			wantErr: false,
+			want:    &unionSegmentV3{selectors: []segmentV3{&nameSegmentV3{name: "name"}, &indexSegmentV3{index: 1}, &nameSegmentV3{name: "age"}}},
 		},
 		{
 			name:    "unquoted names",
 			content: "name,age",
-			want:    &multiNameSegment{names: []string{"name", "age"}},
-			wantErr: false,
+			wantErr: true,
 		},
 		{
 			name:    "quoted names with spaces",
 			content: "'first name','last name'",
-			want:    &multiNameSegment{names: []string{"first name", "last name"}},
-			wantErr: false,
+			want:    &multiNameSegmentV3{names: []string{"first name", "last name"}},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseMultiIndexSegment(tt.content)
+			c, err := Compile("$[" + tt.content + "]")
 			if (err != nil) != tt.wantErr {
-				t.Errorf("parseMultiIndexSegment() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("Compile() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !tt.wantErr {
-				if !reflect.DeepEqual(got, tt.want) {
-					t.Errorf("parseMultiIndexSegment() = %v, want %v", got, tt.want)
-				}
+			if tt.wantErr {
+				return
+			}
+			if len(c.segments) != 1 {
+				t.Fatalf("Compile() returned %d segments, want 1", len(c.segments))
+			}
+			if !reflect.DeepEqual(c.segments[0], tt.want) {
+				t.Errorf("Compile() = %v, want %v", c.segments[0], tt.want)
 			}
 		})
 	}
 }
 
 func TestMultiIndexSegmentString(t *testing.T) {
-	seg := &multiIndexSegment{indices: []int{1, 2, 3}}
+	seg := &multiIndexSegmentV3{indices: []int{1, 2, 3}}
 	expected := "[1,2,3]"
 	if got := seg.String(); got != expected {
-		t.Errorf("multiIndexSegment.String() = %v, want %v", got, expected)
+		t.Errorf("multiIndexSegmentV3.String() = %v, want %v", got, expected)
 	}
 }
 
@@ -216,62 +211,53 @@ func TestParseSliceSegment(t *testing.T) {
 	tests := []struct {
 		name    string
 		content string
-		want    *sliceSegment
+		want    *sliceSegmentV3
 		wantErr bool
 	}{
 		{
 			name:    "empty slice",
 			content: ":",
-			want:    &sliceSegment{start: 0, end: 0, step: 1, hasStart: false, hasEnd: false},
-			wantErr: false,
+			want:    &sliceSegmentV3{start: 0, end: 0, step: 1, hasStart: false, hasEnd: false},
 		},
 		{
 			name:    "start only",
 			content: "1:",
-			want:    &sliceSegment{start: 1, end: 0, step: 1, hasStart: true, hasEnd: false},
-			wantErr: false,
+			want:    &sliceSegmentV3{start: 1, end: 0, step: 1, hasStart: true, hasEnd: false},
 		},
 		{
 			name:    "end only",
 			content: ":2",
-			want:    &sliceSegment{start: 0, end: 2, step: 1, hasStart: false, hasEnd: true},
-			wantErr: false,
+			want:    &sliceSegmentV3{start: 0, end: 2, step: 1, hasStart: false, hasEnd: true},
 		},
 		{
 			name:    "start and end",
 			content: "1:2",
-			want:    &sliceSegment{start: 1, end: 2, step: 1, hasStart: true, hasEnd: true},
-			wantErr: false,
+			want:    &sliceSegmentV3{start: 1, end: 2, step: 1, hasStart: true, hasEnd: true},
 		},
 		{
 			name:    "negative indices",
 			content: "-2:-1",
-			want:    &sliceSegment{start: -2, end: -1, step: 1, hasStart: true, hasEnd: true},
-			wantErr: false,
+			want:    &sliceSegmentV3{start: -2, end: -1, step: 1, hasStart: true, hasEnd: true},
 		},
 		{
 			name:    "with step",
 			content: "1:5:2",
-			want:    &sliceSegment{start: 1, end: 5, step: 2, hasStart: true, hasEnd: true},
-			wantErr: false,
+			want:    &sliceSegmentV3{start: 1, end: 5, step: 2, hasStart: true, hasEnd: true},
 		},
 		{
 			name:    "negative step",
 			content: "5:1:-1",
-			want:    &sliceSegment{start: 5, end: 1, step: -1, hasStart: true, hasEnd: true},
-			wantErr: false,
+			want:    &sliceSegmentV3{start: 5, end: 1, step: -1, hasStart: true, hasEnd: true},
 		},
 		{
 			name:    "empty start with step",
 			content: ":5:2",
-			want:    &sliceSegment{start: 0, end: 5, step: 2, hasStart: false, hasEnd: true},
-			wantErr: false,
+			want:    &sliceSegmentV3{start: 0, end: 5, step: 2, hasStart: false, hasEnd: true},
 		},
 		{
 			name:    "empty end with step",
 			content: "1::2",
-			want:    &sliceSegment{start: 1, end: 0, step: 2, hasStart: true, hasEnd: false},
-			wantErr: false,
+			want:    &sliceSegmentV3{start: 1, end: 0, step: 2, hasStart: true, hasEnd: false},
 		},
 		{
 			name:    "invalid start",
@@ -291,8 +277,7 @@ func TestParseSliceSegment(t *testing.T) {
 		{
 			name:    "zero step",
 			content: "1:2:0",
-			want:    &sliceSegment{start: 1, end: 2, step: 0, hasStart: true, hasEnd: true},
-			wantErr: false,
+			want:    &sliceSegmentV3{start: 1, end: 2, step: 0, hasStart: true, hasEnd: true},
 		},
 		{
 			name:    "too many parts",
@@ -303,15 +288,16 @@ func TestParseSliceSegment(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseSliceSegment(tt.content)
+			c, err := Compile("$[" + tt.content + "]")
 			if (err != nil) != tt.wantErr {
-				t.Errorf("parseSliceSegment() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("Compile() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !tt.wantErr {
-				if !reflect.DeepEqual(got, tt.want) {
-					t.Errorf("parseSliceSegment() = %v, want %v", got, tt.want)
-				}
+			if tt.wantErr {
+				return
+			}
+			if len(c.segments) != 1 || !reflect.DeepEqual(c.segments[0], tt.want) {
+				t.Errorf("Compile() = %v, want %v", c.segments, tt.want)
 			}
 		})
 	}
@@ -320,30 +306,35 @@ func TestParseSliceSegment(t *testing.T) {
 func TestSliceSegmentString(t *testing.T) {
 	tests := []struct {
 		name     string
-		segment  *sliceSegment
+		segment  *sliceSegmentV3
 		expected string
 	}{
 		{
 			name:     "full slice",
-			segment:  &sliceSegment{start: 1, end: 5, step: 2},
+			segment:  &sliceSegmentV3{start: 1, end: 5, step: 2, hasStart: true, hasEnd: true},
 			expected: "[1:5:2]",
 		},
 		{
 			name:     "without step",
-			segment:  &sliceSegment{start: 1, end: 5, step: 1},
+			segment:  &sliceSegmentV3{start: 1, end: 5, step: 1, hasStart: true, hasEnd: true},
 			expected: "[1:5]",
 		},
 		{
 			name:     "negative indices",
-			segment:  &sliceSegment{start: -2, end: -1, step: 1},
+			segment:  &sliceSegmentV3{start: -2, end: -1, step: 1, hasStart: true, hasEnd: true},
 			expected: "[-2:-1]",
 		},
+		{
+			name:     "open ended",
+			segment:  &sliceSegmentV3{step: -1},
+			expected: "[::-1]",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if got := tt.segment.String(); got != tt.expected {
-				t.Errorf("sliceSegment.String() = %v, want %v", got, tt.expected)
+				t.Errorf("sliceSegmentV3.String() = %v, want %v", got, tt.expected)
 			}
 		})
 	}
@@ -439,35 +430,29 @@ func TestParseFunctionCall(t *testing.T) {
 		},
 
 		// 错误情况
-		{
-			name:        "missing opening parenthesis",
-			content:     "length",
-			wantErr:     true,
-			errContains: "missing opening parenthesis",
-		},
 		{
 			name:        "missing closing parenthesis",
 			content:     "length(",
 			wantErr:     true,
-			errContains: "missing closing parenthesis",
+			errContains: "end of input",
 		},
 		{
 			name:        "invalid argument type",
 			content:     "func(invalid)",
 			wantErr:     true,
-			errContains: "unsupported argument type",
+			errContains: "invalid argument",
 		},
 		{
 			name:        "unclosed string argument",
 			content:     "func('unclosed)",
 			wantErr:     true,
-			errContains: "unsupported argument type",
+			errContains: "unterminated string literal",
 		},
 		{
 			name:        "unmatched quotes",
 			content:     "func('test\", 42)",
 			wantErr:     true,
-			errContains: "unsupported argument type",
+			errContains: "unterminated string literal",
 		},
 
 		// 边界情况
@@ -497,38 +482,56 @@ func TestParseFunctionCall(t *testing.T) {
 		},
 		{
 			name:     "string with escaped quotes",
-			content:  "escape('I''m here')",
+			content:  `escape('I\'m here')`,
 			wantName: "escape",
 			wantArgs: []interface{}{"I'm here"},
 		},
 	}
 
+	// 注册测试用的函数名，使解析阶段的函数检查通过
+	functions := NewRegistry()
+	for _, name := range []string{"range", "format", "transform", "func", "my_func", "func123", "big", "small", "escape"} {
+		stub := NewFunction(name, func(args []interface{}) (interface{}, error) { return nil, nil })
+		if err := functions.Register(name, stub); err != nil {
+			t.Fatalf("Register(%q) error = %v", name, err)
+		}
+	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseFunctionCall(tt.content)
+			node, err := parsePath("$."+tt.content, parseOptions{functions: functions})
 			if (err != nil) != tt.wantErr {
-				t.Errorf("parseFunctionCall() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("parsePath() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if err != nil {
 				if !strings.Contains(err.Error(), tt.errContains) {
-					t.Errorf("parseFunctionCall() error = %v, want error containing %v", err, tt.errContains)
+					t.Errorf("parsePath() error = %v, want error containing %v", err, tt.errContains)
 				}
 				return
 			}
 
-			fs, ok := got.(*functionSegment)
+			q := node.(*ast.Query)
+			fs, ok := q.Segments[0].Selectors[0].(*ast.FunctionSelector)
 			if !ok {
-				t.Errorf("parseFunctionCall() returned %T, want *functionSegment", got)
+				t.Errorf("parsePath() returned %T, want *ast.FunctionSelector", q.Segments[0].Selectors[0])
 				return
 			}
 
-			if fs.name != tt.wantName {
-				t.Errorf("parseFunctionCall() name = %v, want %v", fs.name, tt.wantName)
+			if fs.Name != tt.wantName {
+				t.Errorf("parsePath() name = %v, want %v", fs.Name, tt.wantName)
 			}
 
-			if !reflect.DeepEqual(fs.args, tt.wantArgs) {
-				t.Errorf("parseFunctionCall() args = %v, want %v", fs.args, tt.wantArgs)
+			args := make([]interface{}, len(fs.Args))
+			for i, arg := range fs.Args {
+				lit, ok := arg.(*ast.Literal)
+				if !ok {
+					t.Fatalf("parsePath() arg %d = %T, want *ast.Literal", i, arg)
+				}
+				args[i] = lit.Value
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("parsePath() args = %v, want %v", args, tt.wantArgs)
 			}
 		})
 	}
@@ -537,40 +540,40 @@ func TestParseFunctionCall(t *testing.T) {
 func TestFunctionSegmentString(t *testing.T) {
 	tests := []struct {
 		name     string
-		segment  *functionSegment
+		segment  *callSegmentV3
 		expected string
 	}{
 		{
 			name:     "no args",
-			segment:  &functionSegment{name: "length", args: []interface{}{}},
-			expected: "length()",
+			segment:  &callSegmentV3{name: "length", args: []interface{}{}},
+			expected: ".length()",
 		},
 		{
 			name:     "single number arg",
-			segment:  &functionSegment{name: "min", args: []interface{}{float64(1)}},
-			expected: "min(1)",
+			segment:  &callSegmentV3{name: "min", args: []interface{}{float64(1)}},
+			expected: ".min(1)",
 		},
 		{
 			name:     "multiple args",
-			segment:  &functionSegment{name: "sum", args: []interface{}{float64(1), float64(2), float64(3)}},
-			expected: "sum(1,2,3)",
+			segment:  &callSegmentV3{name: "sum", args: []interface{}{float64(1), float64(2), float64(3)}},
+			expected: ".sum(1, 2, 3)",
 		},
 		{
 			name:     "string arg",
-			segment:  &functionSegment{name: "match", args: []interface{}{"pattern"}},
-			expected: "match('pattern')",
+			segment:  &callSegmentV3{name: "match", args: []interface{}{"pattern"}},
+			expected: ".match('pattern')",
 		},
 		{
 			name:     "mixed args",
-			segment:  &functionSegment{name: "format", args: []interface{}{"value", float64(42)}},
-			expected: "format('value',42)",
+			segment:  &callSegmentV3{name: "format", args: []interface{}{"value", float64(42)}},
+			expected: ".format('value', 42)",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if got := tt.segment.String(); got != tt.expected {
-				t.Errorf("functionSegment.String() = %v, want %v", got, tt.expected)
+				t.Errorf("callSegmentV3.String() = %v, want %v", got, tt.expected)
 			}
 		})
 	}
@@ -580,7 +583,6 @@ func TestParseIndexOrName(t *testing.T) {
 	tests := []struct {
 		name        string
 		content     string
-		wantType    string
 		wantValue   interface{}
 		wantErr     bool
 		errContains string
@@ -589,83 +591,70 @@ func TestParseIndexOrName(t *testing.T) {
 		{
 			name:      "positive index",
 			content:   "42",
-			wantType:  "indexSegment",
 			wantValue: 42,
 		},
 		{
 			name:      "negative index",
 			content:   "-1",
-			wantType:  "indexSegment",
 			wantValue: -1,
 		},
 		{
 			name:      "zero index",
 			content:   "0",
-			wantType:  "indexSegment",
 			wantValue: 0,
 		},
 		{
 			name:      "max int (RFC 9535 limit)",
 			content:   "9007199254740991",
-			wantType:  "indexSegment",
 			wantValue: 9007199254740991,
 		},
 		{
 			name:      "min int (RFC 9535 limit)",
 			content:   "-9007199254740991",
-			wantType:  "indexSegment",
 			wantValue: -9007199254740991,
 		},
 		{
-			name:      "max int overflow",
-			content:   "99999999999999999999",
-			wantErr:   true,
-			wantType:  "indexSegment",
-			wantValue: 0,
+			name:        "max int overflow",
+			content:     "99999999999999999999",
+			wantErr:     true,
+			errContains: "index out of range",
 		},
 		{
-			name:      "min int overflow",
-			content:   "-99999999999999999999",
-			wantErr:   true,
-			wantType:  "indexSegment",
-			wantValue: 0,
+			name:        "min int overflow",
+			content:     "-99999999999999999999",
+			wantErr:     true,
+			errContains: "index out of range",
 		},
 
 		// 字符串字面量
 		{
 			name:      "quoted string",
 			content:   "'hello'",
-			wantType:  "nameSegment",
 			wantValue: "hello",
 		},
 		{
 			name:      "empty quoted string",
 			content:   "''",
-			wantType:  "nameSegment",
 			wantValue: "",
 		},
 		{
 			name:      "quoted string with spaces",
 			content:   "'hello world'",
-			wantType:  "nameSegment",
 			wantValue: "hello world",
 		},
 		{
 			name:      "quoted string with special characters",
 			content:   "'hello.world'",
-			wantType:  "nameSegment",
 			wantValue: "hello.world",
 		},
 		{
 			name:      "quoted string with numbers",
 			content:   "'123'",
-			wantType:  "nameSegment",
 			wantValue: "123",
 		},
 		{
 			name:      "quoted string with unicode",
 			content:   "'你好'",
-			wantType:  "nameSegment",
 			wantValue: "你好",
 		},
 
@@ -673,186 +662,195 @@ func TestParseIndexOrName(t *testing.T) {
 		{
 			name:      "double quoted string",
 			content:   "\"hello\"",
-			wantType:  "nameSegment",
 			wantValue: "hello",
 		},
 		{
 			name:      "empty double quoted string",
 			content:   "\"\"",
-			wantType:  "nameSegment",
 			wantValue: "",
 		},
 		{
 			name:      "double quoted string with spaces",
 			content:   "\"hello world\"",
-			wantType:  "nameSegment",
 			wantValue: "hello world",
 		},
 		{
 			name:      "double quoted string with special characters",
 			content:   "\"Number of Moons\"",
-			wantType:  "nameSegment",
 			wantValue: "Number of Moons",
 		},
 		{
 			name:      "double quoted string with numbers",
 			content:   "\"123\"",
-			wantType:  "nameSegment",
 			wantValue: "123",
 		},
 		{
 			name:      "double quoted string with unicode",
 			content:   "\"你好\"",
-			wantType:  "nameSegment",
 			wantValue: "你好",
 		},
 
-		// 普通名称
-		{
-			name:      "unquoted string",
-			content:   "hello",
-			wantType:  "nameSegment",
-			wantValue: "hello",
-		},
-		{
-			name:      "unquoted string with underscore",
-			content:   "hello_world",
-			wantType:  "nameSegment",
-			wantValue: "hello_world",
-		},
-		{
-			name:      "unquoted string with numbers",
-			content:   "hello123",
-			wantType:  "nameSegment",
-			wantValue: "hello123",
-		},
-		{
-			name:      "unquoted string starting with underscore",
-			content:   "_hello",
-			wantType:  "nameSegment",
-			wantValue: "_hello",
-		},
-
-		// 函数调用
-		{
-			name:      "function call without args",
-			content:   "length()",
-			wantType:  "functionSegment",
-			wantValue: "length",
-		},
-		{
-			name:      "function call with number arg",
-			content:   "min(1)",
-			wantType:  "functionSegment",
-			wantValue: "min",
-		},
-		{
-			name:      "function call with string arg",
-			content:   "match('pattern')",
-			wantType:  "functionSegment",
-			wantValue: "match",
-		},
+		// 边界情况和错误
 		{
-			name:      "function call with multiple args",
-			content:   "format('value', 42)",
-			wantType:  "functionSegment",
-			wantValue: "format",
+			name:        "single quote",
+			content:     "'",
+			wantErr:     true,
+			errContains: "unterminated string literal",
 		},
-
-		// 边界情况和错误
 		{
-			name:      "single quote",
-			content:   "'",
-			wantType:  "nameSegment",
-			wantValue: "'",
+			name:        "unclosed quote",
+			content:     "'hello",
+			wantErr:     true,
+			errContains: "unterminated string literal",
 		},
 		{
-			name:      "unclosed quote",
-			content:   "'hello",
-			wantType:  "nameSegment",
-			wantValue: "'hello",
+			name:        "empty string",
+			content:     "",
+			wantErr:     true,
+			errContains: "empty bracket segment",
 		},
 		{
-			name:      "empty string",
-			content:   "",
-			wantType:  "nameSegment",
-			wantValue: "",
+			name:        "whitespace only",
+			content:     "   ",
+			wantErr:     true,
+			errContains: "empty bracket segment",
 		},
 		{
-			name:      "whitespace only",
-			content:   "   ",
-			wantType:  "nameSegment",
-			wantValue: "   ",
+			name:        "unquoted name",
+			content:     "hello",
+			wantErr:     true,
+			errContains: "invalid selector",
 		},
 		{
-			name:      "special characters",
-			content:   "@#$%",
-			wantType:  "nameSegment",
-			wantValue: "@#$%",
+			name:        "special characters",
+			content:     "@#$%",
+			wantErr:     true,
+			errContains: "unexpected character",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseIndexOrName(tt.content)
+			c, err := Compile("$[" + tt.content + "]")
 			if (err != nil) != tt.wantErr {
-				t.Errorf("parseIndexOrName() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("Compile() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if err != nil {
 				if !strings.Contains(err.Error(), tt.errContains) {
-					t.Errorf("parseIndexOrName() error = %v, want error containing %v", err, tt.errContains)
+					t.Errorf("Compile() error = %v, want error containing %v", err, tt.errContains)
 				}
 				return
 			}
 
-			// 检查段类型
-			gotType := reflect.TypeOf(got).String()
-			if !strings.HasSuffix(gotType, tt.wantType) {
-				t.Errorf("parseIndexOrName() returned %v, want type %v", gotType, tt.wantType)
-				return
-			}
-
 			// 检查段值
-			switch v := got.(type) {
-			case *indexSegment:
-				if v.index != tt.wantValue.(int) {
-					t.Errorf("parseIndexOrName() index = %v, want %v", v.index, tt.wantValue)
-				}
-			case *nameSegment:
-				if v.name != tt.wantValue.(string) {
-					t.Errorf("parseIndexOrName() name = %v, want %v", v.name, tt.wantValue)
+			switch v := c.segments[0].(type) {
+			case *indexSegmentV3:
+				if want, ok := tt.wantValue.(int); !ok || v.index != want {
+					t.Errorf("Compile() index = %v, want %v", v.index, tt.wantValue)
 				}
-			case *functionSegment:
-				if v.name != tt.wantValue.(string) {
-					t.Errorf("parseIndexOrName() function name = %v, want %v", v.name, tt.wantValue)
+			case *nameSegmentV3:
+				if want, ok := tt.wantValue.(string); !ok || v.name != want {
+					t.Errorf("Compile() name = %v, want %v", v.name, tt.wantValue)
 				}
 			default:
-				t.Errorf("parseIndexOrName() returned unexpected type %T", got)
+				t.Errorf("Compile() returned unexpected type %T", c.segments[0])
 			}
 		})
 	}
 }
 
-func TestParseFilterValue(t *testing.T) {
+func TestParseDotMemberName(t *testing.T) {
+	for _, name := range []string{"hello", "hello_world", "hello123", "_hello", "你好"} {
+		c, err := Compile("$." + name)
+		if err != nil {
+			t.Fatalf("Compile(%q) error: %v", "$."+name, err)
+		}
+		seg, ok := c.segments[0].(*nameSegmentV3)
+		if !ok || seg.name != name {
+			t.Errorf("Compile(%q) = %v, want name segment %q", "$."+name, c.segments[0], name)
+		}
+	}
+}
+
+func TestParseComparison(t *testing.T) {
 	tests := []struct {
-		name    string
-		value   string
-		want    interface{}
-		wantErr bool
+		name     string
+		path     string
+		left     string
+		operator string
+		value    interface{}
 	}{
-		// 测试用例
+		{
+			name:     "simple field condition",
+			path:     `$[?@.name == "John"]`,
+			left:     "@['name']",
+			operator: "==",
+			value:    "John",
+		},
+		{
+			name:     "nested field condition",
+			path:     `$[?@.user.name != 'Jane']`,
+			left:     "@['user']['name']",
+			operator: "!=",
+			value:    "Jane",
+		},
+		{
+			name:     "numeric comparison",
+			path:     `$[?@.age > 18]`,
+			left:     "@['age']",
+			operator: ">",
+			value:    float64(18),
+		},
+		{
+			name:     "boolean condition",
+			path:     `$[?@.active == true]`,
+			left:     "@['active']",
+			operator: "==",
+			value:    true,
+		},
+		{
+			name:     "null comparison",
+			path:     `$[?@.optional == null]`,
+			left:     "@['optional']",
+			operator: "==",
+			value:    nil,
+		},
+		{
+			name:     "array index condition",
+			path:     `$[?@.items[0].id < 100]`,
+			left:     "@['items'][0]['id']",
+			operator: "<",
+			value:    float64(100),
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseFilterValue(tt.value)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("parseFilterValue() error = %v, wantErr %v", err, tt.wantErr)
-				return
+			q, err := Parse(tt.path)
+			if err != nil {
+				t.Fatalf("Parse() error: %v", err)
 			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("parseFilterValue() = %v, want %v", got, tt.want)
+			filter, ok := q.Segments[0].Selectors[0].(*ast.FilterSelector)
+			if !ok {
+				t.Fatalf("selector = %T, want *ast.FilterSelector", q.Segments[0].Selectors[0])
+			}
+			cmp, ok := filter.Expr.(*ast.ComparisonExpr)
+			if !ok {
+				t.Fatalf("filter expression = %T, want *ast.ComparisonExpr", filter.Expr)
+			}
+			if got := cmp.Left.String(); got != tt.left {
+				t.Errorf("left = %v, want %v", got, tt.left)
+			}
+			if cmp.Op != tt.operator {
+				t.Errorf("operator = %v, want %v", cmp.Op, tt.operator)
+			}
+			lit, ok := cmp.Right.(*ast.Literal)
+			if !ok {
+				t.Fatalf("right = %T, want *ast.Literal", cmp.Right)
+			}
+			if lit.Value != tt.value {
+				t.Errorf("value = %v, want %v", lit.Value, tt.value)
 			}
 		})
 	}
@@ -913,6 +911,31 @@ func TestOperatorPrecedence(t *testing.T) {
 				map[string]interface{}{"a": 0, "b": 2, "c": 3},
 			},
 		},
+		{
+			name: "two AND pairs joined by OR",
+			path: `$[?@.a==1 && @.b==2 || @.c==3 && @.d==4]`,
+			document: []interface{}{
+				map[string]interface{}{"a": 1, "b": 2, "c": 0, "d": 0},
+				map[string]interface{}{"a": 1, "b": 0, "c": 3, "d": 0},
+				map[string]interface{}{"a": 0, "b": 0, "c": 3, "d": 4},
+			},
+			expected: []interface{}{
+				map[string]interface{}{"a": 1, "b": 2, "c": 0, "d": 0},
+				map[string]interface{}{"a": 0, "b": 0, "c": 3, "d": 4},
+			},
+		},
+		{
+			name: "Negation inside a group",
+			path: `$[?(!@.a && @.b)]`,
+			document: []interface{}{
+				map[string]interface{}{"b": 1},
+				map[string]interface{}{"a": 1, "b": 1},
+				map[string]interface{}{"c": 1},
+			},
+			expected: []interface{}{
+				map[string]interface{}{"b": 1},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -940,70 +963,70 @@ func TestOperatorPrecedence(t *testing.T) {
 
 func TestExistenceFilterParsing(t *testing.T) {
 	tests := []struct {
-		name         string
-		path         string
-		wantOperator string
-		wantField    string
-		wantErr      bool
+		name      string
+		path      string
+		wantNode  string
+		wantField string
+		wantErr   bool
 	}{
 		{
-			name:         "simple existence test",
-			path:         `$[?@.name]`,
-			wantOperator: "exists",
-			wantField:    "name",
+			name:      "simple existence test",
+			path:      `$[?@.name]`,
+			wantNode:  "exists",
+			wantField: "@['name']",
 		},
 		{
-			name:         "existence test with parentheses",
-			path:         `$[?(@.name)]`,
-			wantOperator: "exists",
-			wantField:    "name",
+			name:      "existence test with parentheses",
+			path:      `$[?(@.name)]`,
+			wantNode:  "exists",
+			wantField: "@['name']",
 		},
 		{
-			name:         "nested field existence",
-			path:         `$[?@.nested.field]`,
-			wantOperator: "exists",
-			wantField:    "nested.field",
+			name:      "nested field existence",
+			path:      `$[?@.nested.field]`,
+			wantNode:  "exists",
+			wantField: "@['nested']['field']",
 		},
 		{
-			name:         "negated existence test",
-			path:         `$[?!@.name]`,
-			wantOperator: "not_exists",
-			wantField:    "name",
+			name:      "negated existence test",
+			path:      `$[?!@.name]`,
+			wantNode:  "not_exists",
+			wantField: "@['name']",
 		},
 		{
-			name:         "negated existence with parentheses",
-			path:         `$[?!(@.name)]`,
-			wantOperator: "not_exists",
-			wantField:    "name",
+			name:      "negated existence with parentheses",
+			path:      `$[?!(@.name)]`,
+			wantNode:  "not_exists",
+			wantField: "@['name']",
 		},
 		{
-			name:         "bare @ with > operator",
-			path:         `$[?@>3]`,
-			wantOperator: ">",
-			wantField:    "",
+			name:      "bare @ with > operator",
+			path:      `$[?@>3]`,
+			wantNode:  ">",
+			wantField: "@",
 		},
 		{
-			name:         "bare @ with == operator",
-			path:         `$[?@=="b"]`,
-			wantOperator: "==",
-			wantField:    "",
+			name:      "bare @ with == operator",
+			path:      `$[?@=="b"]`,
+			wantNode:  "==",
+			wantField: "@",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			segments, err := parse(tt.path)
+			c, err := Compile(tt.path)
 			if (err != nil) != tt.wantErr {
-				t.Fatalf("parse() error = %v, wantErr %v", err, tt.wantErr)
+				t.Fatalf("Compile() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			if tt.wantErr {
 				return
 			}
 
 			// Find the filter segment
-			var filterSeg *filterSegment
-			for _, seg := range segments {
-				if fs, ok := seg.(*filterSegment); ok {
+			var filterSeg *filterSegmentV3
+			for _, seg := range c.segments {
+				if fs, ok := seg.(*filterSegmentV3); ok {
 					filterSeg = fs
 					break
 				}
@@ -1012,17 +1035,31 @@ func TestExistenceFilterParsing(t *testing.T) {
 				t.Fatal("No filter segment found")
 			}
 
-			// Extract condition node
-			condNode, ok := filterSeg.expr.(*conditionNode)
-			if !ok {
-				t.Fatalf("Expected conditionNode, got %T", filterSeg.expr)
+			var gotNode, gotField string
+			switch n := filterSeg.expr.(type) {
+			case *existenceNode:
+				gotNode, gotField = "exists", n.query.query.String()
+			case *notNode:
+				inner, ok := n.child.(*existenceNode)
+				if !ok {
+					t.Fatalf("Expected existenceNode under notNode, got %T", n.child)
+				}
+				gotNode, gotField = "not_exists", inner.query.query.String()
+			case *comparisonNode:
+				left, ok := n.left.(*queryOperand)
+				if !ok {
+					t.Fatalf("Expected queryOperand on the left, got %T", n.left)
+				}
+				gotNode, gotField = n.op, left.query.String()
+			default:
+				t.Fatalf("Unexpected filter node %T", filterSeg.expr)
 			}
 
-			if condNode.cond.operator != tt.wantOperator {
-				t.Errorf("operator = %v, want %v", condNode.cond.operator, tt.wantOperator)
+			if gotNode != tt.wantNode {
+				t.Errorf("node = %v, want %v", gotNode, tt.wantNode)
 			}
-			if condNode.cond.field != tt.wantField {
-				t.Errorf("field = %v, want %v", condNode.cond.field, tt.wantField)
+			if gotField != tt.wantField {
+				t.Errorf("field = %v, want %v", gotField, tt.wantField)
 			}
 		})
 	}
@@ -1096,3 +1133,42 @@ func TestBareAtReference(t *testing.T) {
 		})
 	}
 }
+
+func TestRelativeQueryIndices(t *testing.T) {
+	obj := map[string]interface{}{
+		"scores": []interface{}{95.0, 80.0},
+		"tags":   []interface{}{"draft", "final"},
+		"grid":   []interface{}{[]interface{}{1.0, 2.0}, []interface{}{3.0, 4.0}},
+	}
+	tests := []struct {
+		field   string
+		want    interface{}
+		wantErr bool
+	}{
+		{field: "@.scores[0]", want: 95.0},
+		{field: "@.tags[-1]", want: "final"},
+		{field: "@.grid[1][0]", want: 3.0},
+		{field: "@['tags'][0]", want: "draft"},
+		{field: "@.scores[2]", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			c, err := Compile("$[?" + tt.field + " == 0]")
+			if err != nil {
+				t.Fatalf("Compile() error: %v", err)
+			}
+			cmp := c.segments[0].(*filterSegmentV3).expr.(*comparisonNode)
+			query, ok := cmp.left.(*queryOperand)
+			if !ok || !isSingularSegments(query.segments) {
+				t.Fatalf("left operand of %q is not a singular query", tt.field)
+			}
+			nodes := query.nodes(newEvalContext(nil), obj, obj)
+			if (len(nodes) == 0) != tt.wantErr {
+				t.Fatalf("%q selected %v, wantErr %v", tt.field, nodes, tt.wantErr)
+			}
+			if !tt.wantErr && nodes[0].Value != tt.want {
+				t.Errorf("%q = %v, want %v", tt.field, nodes[0].Value, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// QueryPointer resolves an RFC 6901 JSON Pointer, such as /store/book/0,
+// against data. Like Query it returns a NodeList whose Location is a
+// Normalized Path; the list is empty when the pointer refers to no value.
+func QueryPointer(data interface{}, pointer string, opts ...Option) (NodeList, error) {
+	data, err := decodeDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return newEvalContext(opts).evaluate(segments, data)
+}
+
+// parsePointer splits a JSON Pointer into one segment per reference token
+func parsePointer(pointer string) ([]segmentV3, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, NewError(ErrInvalidPath, "JSON Pointer must be empty or start with '/'", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	segments := make([]segmentV3, len(tokens))
+	for i, token := range tokens {
+		unescaped, err := unescapePointerToken(token)
+		if err != nil {
+			return nil, NewError(ErrInvalidPath, err.Error(), pointer)
+		}
+		segments[i] = newPointerSegment(unescaped)
+	}
+	return segments, nil
+}
+
+// ToPointer converts a singular JSONPath, such as a Normalized Path, into
+// an RFC 6901 JSON Pointer: $['store']['book'][0] becomes /store/book/0.
+// Paths selecting more than one node, and negative indices, which have no
+// JSON Pointer equivalent, are rejected.
+func ToPointer(path string) (string, error) {
+	segments, _, err := compilePath(path, parseOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, seg := range segments {
+		switch s := seg.(type) {
+		case *callSegmentV3, *functionSegmentV3, *fallbackSegmentV3:
+			return "", NewError(ErrInvalidPath, "function segment has no JSON Pointer equivalent", path)
+		case *nameSegmentV3:
+			b.WriteByte('/')
+			b.WriteString(escapePointerToken(s.name))
+		case *indexSegmentV3:
+			if s.index < 0 {
+				return "", NewError(ErrInvalidPath, fmt.Sprintf("negative index %d has no JSON Pointer equivalent", s.index), path)
+			}
+			b.WriteByte('/')
+			b.WriteString(strconv.Itoa(s.index))
+		default:
+			return "", NewError(ErrInvalidPath, "path is not singular", path)
+		}
+	}
+	return b.String(), nil
+}
+
+// Pointer returns the location of the node as an RFC 6901 JSON Pointer.
+// The root node yields the empty pointer "". Nodes produced by a function
+// segment report the location of the node the function was applied to.
+func (n Node) Pointer() string {
+	pointer, err := ToPointer(n.Location)
+	if err != nil {
+		return ""
+	}
+	return pointer
+}
+
+// Pointers returns the locations of all nodes as RFC 6901 JSON Pointers
+func (nl NodeList) Pointers() []string {
+	pointers := make([]string, len(nl))
+	for i, n := range nl {
+		pointers[i] = n.Pointer()
+	}
+	return pointers
+}
+
+// escapePointerToken escapes a reference token per RFC 6901
+func escapePointerToken(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	return strings.ReplaceAll(token, "/", "~1")
+}
+
+// unescapePointerToken reverses escapePointerToken, rejecting "~" not
+// followed by "0" or "1"
+func unescapePointerToken(token string) (string, error) {
+	if !strings.Contains(token, "~") {
+		return token, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(token); i++ {
+		if token[i] != '~' {
+			b.WriteByte(token[i])
+			continue
+		}
+		if i+1 >= len(token) || (token[i+1] != '0' && token[i+1] != '1') {
+			return "", fmt.Errorf("invalid escape sequence in token %q", token)
+		}
+		if token[i+1] == '0' {
+			b.WriteByte('~')
+		} else {
+			b.WriteByte('/')
+		}
+		i++
+	}
+	return b.String(), nil
+}
+
+// pointerSegmentV3 implements a JSON Pointer reference token, which names
+// an object member or, for arrays, an element index
+type pointerSegmentV3 struct {
+	token string
+	index int // array index, or -1 if the token is not a valid array index
+}
+
+func newPointerSegment(token string) *pointerSegmentV3 {
+	s := &pointerSegmentV3{token: token, index: -1}
+	// RFC 6901: array indices are "0" or digits without a leading zero
+	if token == "0" || (token != "" && token[0] != '0' && strings.Trim(token, "0123456789") == "") {
+		if idx, err := strconv.Atoi(token); err == nil {
+			s.index = idx
+		}
+	}
+	return s
+}
+
+func (s *pointerSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
+	switch v := node.Value.(type) {
+	case map[string]interface{}:
+		val, key, exists := ctx.lookupMember(v, s.token)
+		if !exists {
+			return NodeList{}, nil
+		}
+		return NodeList{{
+			Location: node.Location + "['" + escapeNormalizedPathKey(key) + "']",
+			Value:    val,
+			Root:     node.Root,
+			computed: node.computed,
+		}}, nil
+	case []interface{}:
+		if s.index < 0 || s.index >= len(v) {
+			return NodeList{}, nil
+		}
+		return NodeList{{
+			Location: node.Location + "[" + strconv.Itoa(s.index) + "]",
+			Value:    v[s.index],
+			Root:     node.Root,
+			computed: node.computed,
+		}}, nil
+	default:
+		return NodeList{}, nil
+	}
+}
+
+func (s *pointerSegmentV3) String() string {
+	return "[" + canonicalName(s.token) + "]"
+}
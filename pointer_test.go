@@ -0,0 +1,147 @@
+package jsonpath
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToPointer(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "$", want: ""},
+		{path: "$['store']['book'][0]['price']", want: "/store/book/0/price"},
+		{path: "$.store.book[1]", want: "/store/book/1"},
+		{path: "$['a/b']['m~n']", want: "/a~1b/m~0n"},
+		{path: `$['it\'s']`, want: "/it's"},
+		{path: "$['']", want: "/"},
+		{path: "$['tab\\u0009x']", want: "/tab\tx"},
+		{path: "$['日本']", want: "/日本"},
+		{path: "$['a.b c[0]']['x]']", want: "/a.b c[0]/x]"},
+		{path: "$[-1]", wantErr: true},
+		{path: "$[*]", wantErr: true},
+		{path: "$..a", wantErr: true},
+		{path: "$[0,1]", wantErr: true},
+		{path: "$[?@.a]", wantErr: true},
+		{path: "$.a.length()", wantErr: true},
+		{path: "$[", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := ToPointer(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToPointer(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ToPointer(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeListPointers(t *testing.T) {
+	data := map[string]interface{}{
+		"store": map[string]interface{}{
+			"book": []interface{}{
+				map[string]interface{}{"price": 8.95},
+				map[string]interface{}{"price": 12.99},
+			},
+			"a/b": map[string]interface{}{"price": 1.0},
+		},
+	}
+
+	result, err := Query(data, "$..price")
+	if err != nil {
+		t.Fatalf("Query error = %v", err)
+	}
+	want := []string{"/store/a~1b/price", "/store/book/0/price", "/store/book/1/price"}
+	if got := result.Pointers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Pointers() = %v, want %v", got, want)
+	}
+
+	root, err := Query(data, "$")
+	if err != nil {
+		t.Fatalf("Query error = %v", err)
+	}
+	if got := root[0].Pointer(); got != "" {
+		t.Errorf("root Pointer() = %q, want empty", got)
+	}
+}
+
+func TestQueryPointer(t *testing.T) {
+	data := `{
+		"store": {"book": [{"title": "A"}, {"title": "B"}]},
+		"a/b": 1, "m~n": 2, "": 3, "0": "zero", "01": "leading",
+		" ": 7, "arr": [10, 20]
+	}`
+
+	tests := []struct {
+		pointer  string
+		location string
+		value    interface{}
+		empty    bool
+		wantErr  bool
+	}{
+		{pointer: "/store/book/0/title", location: "$['store']['book'][0]['title']", value: "A"},
+		{pointer: "/store/book/1", location: "$['store']['book'][1]", value: map[string]interface{}{"title": "B"}},
+		{pointer: "/a~1b", location: "$['a/b']", value: float64(1)},
+		{pointer: "/m~0n", location: "$['m~n']", value: float64(2)},
+		{pointer: "/", location: "$['']", value: float64(3)},
+		{pointer: "/ ", location: "$[' ']", value: float64(7)},
+		{pointer: "/0", location: "$['0']", value: "zero"},
+		{pointer: "/01", location: "$['01']", value: "leading"},
+		{pointer: "/arr/1", location: "$['arr'][1]", value: float64(20)},
+		{pointer: "/arr/01", empty: true},
+		{pointer: "/arr/-", empty: true},
+		{pointer: "/arr/5", empty: true},
+		{pointer: "/missing", empty: true},
+		{pointer: "/arr/0/x", empty: true},
+		{pointer: "store", wantErr: true},
+		{pointer: "/a~2b", wantErr: true},
+		{pointer: "/a~", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pointer, func(t *testing.T) {
+			result, err := QueryPointer(data, tt.pointer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("QueryPointer(%q) error = %v, wantErr %v", tt.pointer, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.empty {
+				if len(result) != 0 {
+					t.Errorf("QueryPointer(%q) = %v, want empty", tt.pointer, result)
+				}
+				return
+			}
+			if len(result) != 1 {
+				t.Fatalf("QueryPointer(%q) returned %d nodes, want 1", tt.pointer, len(result))
+			}
+			if result[0].Location != tt.location {
+				t.Errorf("Location = %q, want %q", result[0].Location, tt.location)
+			}
+			if !reflect.DeepEqual(result[0].Value, tt.value) {
+				t.Errorf("Value = %v, want %v", result[0].Value, tt.value)
+			}
+			if got := result[0].Pointer(); got != tt.pointer {
+				t.Errorf("Pointer() = %q, want %q", got, tt.pointer)
+			}
+		})
+	}
+}
+
+func TestQueryPointerRoot(t *testing.T) {
+	data := map[string]interface{}{"a": 1}
+	result, err := QueryPointer(data, "")
+	if err != nil {
+		t.Fatalf("QueryPointer error = %v", err)
+	}
+	if len(result) != 1 || result[0].Location != "$" || !reflect.DeepEqual(result[0].Value, data) {
+		t.Errorf("QueryPointer(\"\") = %v, want root node", result)
+	}
+}
@@ -0,0 +1,135 @@
+package jsonpath
+
+import (
+	"fmt"
+)
+
+// Ref identifies where a matched value lives inside its document: the
+// container holding it and its member name or array index. A Ref allows
+// the value to be replaced or removed in place.
+//
+// A Ref is only valid as long as the containers on its path are not
+// restructured; removing an array element shifts the indices of the
+// elements after it, so remove several elements in descending index order.
+type Ref struct {
+	Parent interface{} // map[string]interface{} or []interface{} holding the value
+	Key    interface{} // member name (string) or array index (int)
+
+	root  interface{}
+	steps []interface{} // path from root to the value, names and indices
+}
+
+// Ref returns a handle on the location of n within its document. It fails
+// for the root node, which has no parent, and for nodes whose Location does
+// not identify a value in Root, such as nodes produced by functions or by
+// slicing a string.
+func (n Node) Ref() (*Ref, error) {
+	if n.computed {
+		return nil, NewError(ErrInvalidPath, "the node was produced by a function or a string slice", n.Location)
+	}
+	segments, err := locationSteps(n.Location)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return nil, NewError(ErrInvalidPath, "the root node has no parent", n.Location)
+	}
+	steps := make([]interface{}, len(segments))
+	for i, seg := range segments {
+		switch s := seg.(type) {
+		case *nameSegmentV3:
+			steps[i] = s.name
+		case *indexSegmentV3:
+			steps[i] = s.index
+		}
+	}
+	return newRef(n.Root, steps, n.Location)
+}
+
+// newRef resolves the container addressed by all but the last step
+func newRef(root interface{}, steps []interface{}, location string) (*Ref, error) {
+	parent := root
+	for _, step := range steps[:len(steps)-1] {
+		child, ok := lookupStep(parent, step)
+		if !ok {
+			return nil, NewError(ErrInvalidPath, "location does not exist in the document", location)
+		}
+		parent = child
+	}
+	if _, ok := lookupStep(parent, steps[len(steps)-1]); !ok {
+		return nil, NewError(ErrInvalidPath, "location does not exist in the document", location)
+	}
+	return &Ref{Parent: parent, Key: steps[len(steps)-1], root: root, steps: steps}, nil
+}
+
+// lookupStep returns the child of container selected by a name or index
+func lookupStep(container interface{}, step interface{}) (interface{}, bool) {
+	switch key := step.(type) {
+	case string:
+		obj, ok := container.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		val, exists := obj[key]
+		return val, exists
+	case int:
+		arr, ok := container.([]interface{})
+		if !ok || key < 0 || key >= len(arr) {
+			return nil, false
+		}
+		return arr[key], true
+	}
+	return nil, false
+}
+
+// Value returns the current value at the referenced location
+func (r *Ref) Value() (interface{}, bool) {
+	return lookupStep(r.Parent, r.Key)
+}
+
+// Set replaces the value at the referenced location
+func (r *Ref) Set(value interface{}) error {
+	switch key := r.Key.(type) {
+	case string:
+		r.Parent.(map[string]interface{})[key] = value
+		return nil
+	case int:
+		arr := r.Parent.([]interface{})
+		if key >= len(arr) {
+			return NewError(ErrInvalidArgument, fmt.Sprintf("index %d out of range", key), "")
+		}
+		arr[key] = value
+		return nil
+	}
+	return NewError(ErrInvalidArgument, "invalid reference", "")
+}
+
+// Delete removes the referenced member or array element. Removing an array
+// element shortens the array, which is stored back into its own container;
+// elements of an array that is the document root cannot be removed.
+func (r *Ref) Delete() error {
+	switch key := r.Key.(type) {
+	case string:
+		delete(r.Parent.(map[string]interface{}), key)
+		return nil
+	case int:
+		arr := r.Parent.([]interface{})
+		if key >= len(arr) {
+			return NewError(ErrInvalidArgument, fmt.Sprintf("index %d out of range", key), "")
+		}
+		if len(r.steps) == 1 {
+			return NewError(ErrInvalidArgument, "cannot remove an element of the root array", "")
+		}
+		shortened := append(arr[:key:key], arr[key+1:]...)
+		parentRef, err := newRef(r.root, r.steps[:len(r.steps)-1], "")
+		if err != nil {
+			return err
+		}
+		if err := parentRef.Set(shortened); err != nil {
+			return err
+		}
+		r.Parent = shortened
+		return nil
+	}
+	return NewError(ErrInvalidArgument, "invalid reference", "")
+}
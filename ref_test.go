@@ -0,0 +1,147 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func decodeTestJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid test JSON: %v", err)
+	}
+	return v
+}
+
+func encodeTestJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	return string(b)
+}
+
+func TestNodeRef(t *testing.T) {
+	data := decodeTestJSON(t, `{"store":{"book":[{"title":"A","price":8},{"title":"B","price":12}]}}`)
+
+	result, err := Query(data, "$.store.book[1].price")
+	if err != nil || len(result) != 1 {
+		t.Fatalf("Query error = %v, result = %v", err, result)
+	}
+	ref, err := result[0].Ref()
+	if err != nil {
+		t.Fatalf("Ref() error = %v", err)
+	}
+	if ref.Key != "price" {
+		t.Errorf("Key = %v, want price", ref.Key)
+	}
+	if v, ok := ref.Value(); !ok || v != float64(12) {
+		t.Errorf("Value() = %v, %v, want 12, true", v, ok)
+	}
+
+	if err := ref.Set(10.5); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	want := `{"store":{"book":[{"price":8,"title":"A"},{"price":10.5,"title":"B"}]}}`
+	if got := encodeTestJSON(t, data); got != want {
+		t.Errorf("after Set() = %s, want %s", got, want)
+	}
+
+	if err := ref.Delete(); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	want = `{"store":{"book":[{"price":8,"title":"A"},{"title":"B"}]}}`
+	if got := encodeTestJSON(t, data); got != want {
+		t.Errorf("after Delete() = %s, want %s", got, want)
+	}
+}
+
+func TestNodeRefArrayElements(t *testing.T) {
+	data := decodeTestJSON(t, `{"items":[1,2,3,4,5]}`)
+
+	result, err := Query(data, "$.items[?@ > 2]")
+	if err != nil {
+		t.Fatalf("Query error = %v", err)
+	}
+	if got := len(result); got != 3 {
+		t.Fatalf("Query returned %d nodes, want 3", got)
+	}
+	first, err := result[0].Ref()
+	if err != nil {
+		t.Fatalf("Ref() error = %v", err)
+	}
+	if first.Key != 2 {
+		t.Errorf("Key = %v, want 2", first.Key)
+	}
+	if err := first.Set("three"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// Remove in descending index order so earlier indices stay valid
+	for i := len(result) - 1; i >= 1; i-- {
+		ref, err := result[i].Ref()
+		if err != nil {
+			t.Fatalf("Ref() error = %v", err)
+		}
+		if err := ref.Delete(); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+	}
+	if got, want := encodeTestJSON(t, data), `{"items":[1,2,"three"]}`; got != want {
+		t.Errorf("data = %s, want %s", got, want)
+	}
+}
+
+func TestNodeRefErrors(t *testing.T) {
+	data := decodeTestJSON(t, `[{"a":1},{"a":2}]`)
+
+	root, _ := Query(data, "$")
+	if _, err := root[0].Ref(); err == nil {
+		t.Error("Ref() of root expected error")
+	}
+
+	elems, _ := Query(data, "$[0]")
+	ref, err := elems[0].Ref()
+	if err != nil {
+		t.Fatalf("Ref() error = %v", err)
+	}
+	if err := ref.Delete(); err == nil {
+		t.Error("Delete() of root array element expected error")
+	}
+
+	detached := Node{Location: "$[5]", Value: 1, Root: data}
+	if _, err := detached.Ref(); err == nil {
+		t.Error("Ref() of missing location expected error")
+	}
+}
+
+func TestNodeRefFunctionResults(t *testing.T) {
+	data := decodeTestJSON(t, `{"a":[1,2,3],"o":{"x":[4,5]}}`)
+
+	paths := []string{
+		"$.a.length()",
+		"$.a.sum()",
+		"$.a[*].sum()",
+		"$.o.keys()",
+		"$.o.values()[0][1]",
+		"$.missing.default(1)",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			nodes, err := Query(data, path)
+			if err != nil {
+				t.Fatalf("Query() error = %v", err)
+			}
+			if len(nodes) == 0 {
+				t.Fatal("Query() returned no nodes")
+			}
+			for _, n := range nodes {
+				if _, err := n.Ref(); err == nil {
+					t.Errorf("Ref() of %s at %s expected error", path, n.Location)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RelativePointer evaluates a Relative JSON Pointer (draft-handrews), such
+// as 1/name or 0#, starting from n. The leading integer moves up that many
+// levels towards the root, an optional +N or -N then moves between array
+// siblings, and the remainder is either a JSON Pointer applied from there
+// or "#", which yields the member name or array index of the node reached.
+//
+// n must have been returned by a query so that its Root and Location are
+// set. The result is empty when the pointer refers to no value.
+func (n Node) RelativePointer(rel string) (NodeList, error) {
+	up, shift, rest, err := parseRelativePointer(rel)
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := locationSteps(n.Location)
+	if err != nil {
+		return nil, err
+	}
+	if up > len(steps) {
+		return nil, NewError(ErrInvalidPath, fmt.Sprintf("relative pointer moves %d levels above %s", up, n.Location), rel)
+	}
+	if up > 0 && n.Root == nil {
+		return nil, NewError(ErrInvalidPath, "node has no root to move up to", rel)
+	}
+
+	steps = steps[:len(steps)-up]
+	if shift != 0 {
+		if len(steps) == 0 {
+			return nil, NewError(ErrInvalidPath, "index manipulation requires an array element", rel)
+		}
+		last, ok := steps[len(steps)-1].(*indexSegmentV3)
+		if !ok {
+			return nil, NewError(ErrInvalidPath, "index manipulation requires an array element", rel)
+		}
+		if last.index+shift < 0 {
+			return NodeList{}, nil
+		}
+		steps[len(steps)-1] = &indexSegmentV3{index: last.index + shift}
+	}
+
+	ctx := newEvalContext(nil)
+	var base NodeList
+	if up == 0 && shift == 0 {
+		base = NodeList{n}
+	} else {
+		base, err = ctx.evaluate(steps, n.Root)
+		if err != nil || len(base) == 0 {
+			return NodeList{}, err
+		}
+	}
+
+	if rest == "#" {
+		if len(steps) == 0 {
+			return nil, NewError(ErrInvalidPath, "the root has no name or index", rel)
+		}
+		var name interface{}
+		switch s := steps[len(steps)-1].(type) {
+		case *indexSegmentV3:
+			name = float64(s.index)
+		case *nameSegmentV3:
+			name = s.name
+		}
+		return NodeList{{Location: base[0].Location, Value: name, Root: base[0].Root}}, nil
+	}
+
+	segments, err := parsePointer(rest)
+	if err != nil {
+		return nil, err
+	}
+	nodes := base
+	for _, seg := range segments {
+		if len(nodes) == 0 {
+			break
+		}
+		if nodes, err = seg.evaluate(ctx, nodes[0]); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// parseRelativePointer splits a relative pointer into its level count,
+// index manipulation and trailing "#" or JSON Pointer
+func parseRelativePointer(rel string) (up, shift int, rest string, err error) {
+	i := 0
+	for i < len(rel) && rel[i] >= '0' && rel[i] <= '9' {
+		i++
+	}
+	if i == 0 || (i > 1 && rel[0] == '0') {
+		return 0, 0, "", NewError(ErrInvalidPath, "relative pointer must start with a non-negative integer", rel)
+	}
+	up, err = strconv.Atoi(rel[:i])
+	if err != nil {
+		return 0, 0, "", NewError(ErrInvalidPath, "relative pointer level out of range", rel)
+	}
+
+	if i < len(rel) && (rel[i] == '+' || rel[i] == '-') {
+		j := i + 1
+		for j < len(rel) && rel[j] >= '0' && rel[j] <= '9' {
+			j++
+		}
+		if j == i+1 || (j > i+2 && rel[i+1] == '0') {
+			return 0, 0, "", NewError(ErrInvalidPath, "invalid index manipulation", rel)
+		}
+		shift, err = strconv.Atoi(rel[i:j])
+		if err != nil {
+			return 0, 0, "", NewError(ErrInvalidPath, "index manipulation out of range", rel)
+		}
+		i = j
+	}
+
+	rest = rel[i:]
+	if rest != "" && rest != "#" && !strings.HasPrefix(rest, "/") {
+		return 0, 0, "", NewError(ErrInvalidPath, "relative pointer must end with '#' or a JSON Pointer", rel)
+	}
+	return up, shift, rest, nil
+}
+
+// locationSteps parses a Normalized Path into its name and index segments
+func locationSteps(location string) ([]segmentV3, error) {
+	steps, _, err := compilePath(location, parseOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, seg := range steps {
+		switch seg.(type) {
+		case *indexSegmentV3, *nameSegmentV3:
+		default:
+			return nil, NewError(ErrInvalidPath, "location is not a normalized path", location)
+		}
+	}
+	return steps, nil
+}
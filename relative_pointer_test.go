@@ -0,0 +1,101 @@
+package jsonpath
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRelativePointer(t *testing.T) {
+	data := `{
+		"store": {
+			"book": [
+				{"title": "A", "price": 8.95, "tags": ["x", "y"]},
+				{"title": "B", "price": 12.99, "tags": ["z"]}
+			]
+		}
+	}`
+
+	result, err := Query(data, "$.store.book[0].tags[1]")
+	if err != nil || len(result) != 1 {
+		t.Fatalf("Query error = %v, result = %v", err, result)
+	}
+	start := result[0]
+
+	tests := []struct {
+		rel      string
+		location string
+		value    interface{}
+		empty    bool
+		wantErr  bool
+	}{
+		{rel: "0", location: "$['store']['book'][0]['tags'][1]", value: "y"},
+		{rel: "1/0", location: "$['store']['book'][0]['tags'][0]", value: "x"},
+		{rel: "2/title", location: "$['store']['book'][0]['title']", value: "A"},
+		{rel: "0-1", location: "$['store']['book'][0]['tags'][0]", value: "x"},
+		{rel: "2+1/title", location: "$['store']['book'][1]['title']", value: "B"},
+		{rel: "0#", location: "$['store']['book'][0]['tags'][1]", value: float64(1)},
+		{rel: "1#", location: "$['store']['book'][0]['tags']", value: "tags"},
+		{rel: "3#", location: "$['store']['book']", value: "book"},
+		{rel: "5", location: "$", value: nil},
+		{rel: "0+1", empty: true},
+		{rel: "0-2", empty: true},
+		{rel: "2/missing", empty: true},
+		{rel: "6", wantErr: true},
+		{rel: "5#", wantErr: true},
+		{rel: "1+1", wantErr: true},
+		{rel: "", wantErr: true},
+		{rel: "01", wantErr: true},
+		{rel: "0+", wantErr: true},
+		{rel: "1name", wantErr: true},
+		{rel: "1/a~2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rel, func(t *testing.T) {
+			got, err := start.RelativePointer(tt.rel)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RelativePointer(%q) error = %v, wantErr %v", tt.rel, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.empty {
+				if len(got) != 0 {
+					t.Errorf("RelativePointer(%q) = %v, want empty", tt.rel, got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("RelativePointer(%q) returned %d nodes, want 1", tt.rel, len(got))
+			}
+			if got[0].Location != tt.location {
+				t.Errorf("Location = %q, want %q", got[0].Location, tt.location)
+			}
+			if tt.value != nil && !reflect.DeepEqual(got[0].Value, tt.value) {
+				t.Errorf("Value = %v, want %v", got[0].Value, tt.value)
+			}
+		})
+	}
+}
+
+func TestRelativePointerSiblings(t *testing.T) {
+	data := `{"items": [{"name": "a", "price": 1}, {"name": "b", "price": 2}]}`
+	result, err := Query(data, "$..price")
+	if err != nil {
+		t.Fatalf("Query error = %v", err)
+	}
+
+	var names []interface{}
+	for _, n := range result {
+		sibling, err := n.RelativePointer("1/name")
+		if err != nil {
+			t.Fatalf("RelativePointer error = %v", err)
+		}
+		for _, s := range sibling {
+			names = append(names, s.Value)
+		}
+	}
+	if want := []interface{}{"a", "b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("sibling names = %v, want %v", names, want)
+	}
+}
@@ -10,104 +10,18 @@ import (
 	"testing"
 )
 
-func TestMapToArray(t *testing.T) {
-	tests := []struct {
-		name string
-		m    map[string]interface{}
-		want []interface{}
-	}{
-		{
-			name: "empty map",
-			m:    map[string]interface{}{},
-			want: []interface{}{},
-		},
-		{
-			name: "single key-value pair",
-			m: map[string]interface{}{
-				"key": "value",
-			},
-			want: []interface{}{"value"},
-		},
-		{
-			name: "multiple key-value pairs",
-			m: map[string]interface{}{
-				"name": "John",
-				"age":  30,
-				"city": "New York",
-			},
-			want: []interface{}{"John", 30, "New York"},
-		},
-		{
-			name: "nested objects",
-			m: map[string]interface{}{
-				"name": "John",
-				"address": map[string]interface{}{
-					"city":  "New York",
-					"state": "NY",
-				},
-				"age": 30,
-			},
-			want: []interface{}{
-				"John",
-				map[string]interface{}{
-					"city":  "New York",
-					"state": "NY",
-				},
-				30,
-			},
-		},
-		{
-			name: "array values",
-			m: map[string]interface{}{
-				"numbers": []interface{}{1, 2, 3},
-				"letters": []interface{}{"a", "b", "c"},
-			},
-			want: []interface{}{
-				[]interface{}{1, 2, 3},
-				[]interface{}{"a", "b", "c"},
-			},
-		},
-		{
-			name: "mixed types",
-			m: map[string]interface{}{
-				"string":  "text",
-				"number":  42,
-				"boolean": true,
-				"null":    nil,
-				"array":   []interface{}{1, "two", true},
-				"object": map[string]interface{}{
-					"key": "value",
-				},
-			},
-			want: []interface{}{
-				"text",
-				42,
-				true,
-				nil,
-				[]interface{}{1, "two", true},
-				map[string]interface{}{
-					"key": "value",
-				},
-			},
-		},
+// evalSegment applies seg to value as the root node and returns the
+// selected values
+func evalSegment(seg segmentV3, value interface{}) ([]interface{}, error) {
+	nodes, err := seg.evaluate(newEvalContext(nil), Node{Location: "$", Value: value, Root: value})
+	if err != nil {
+		return nil, err
 	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := mapToArray(tt.m)
-			// 由于 map 的迭代顺序是不确定的，我们需要排序后再比较
-			sortSlice := func(s []interface{}) {
-				sort.Slice(s, func(i, j int) bool {
-					return fmt.Sprintf("%v", s[i]) < fmt.Sprintf("%v", s[j])
-				})
-			}
-			sortSlice(got)
-			sortSlice(tt.want)
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("mapToArray() = %v, want %v", got, tt.want)
-			}
-		})
+	values := make([]interface{}, len(nodes))
+	for i, n := range nodes {
+		values[i] = n.Value
 	}
+	return values, nil
 }
 
 // toString 将任意值转换为字符串以便比较
@@ -231,16 +145,15 @@ func TestRecursiveCollect(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := &recursiveSegment{}
-			got, err := s.evaluate(tt.value)
+			got, err := evalSegment(&recursiveSegmentV3{}, tt.value)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("recursiveSegment.evaluate() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("recursiveSegmentV3.evaluate() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
 			if tt.want == nil {
 				if len(got) != 0 {
-					t.Errorf("recursiveSegment.evaluate() = %v, want empty result", got)
+					t.Errorf("recursiveSegmentV3.evaluate() = %v, want empty result", got)
 				}
 				return
 			}
@@ -254,13 +167,13 @@ func TestRecursiveCollect(t *testing.T) {
 			sortSlice(got)
 			sortSlice(tt.want)
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("recursiveSegment.evaluate() = %v, want %v", got, tt.want)
+				t.Errorf("recursiveSegmentV3.evaluate() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
-func TestCalculateIndex(t *testing.T) {
+func TestSliceBoundClamping(t *testing.T) {
 	tests := []struct {
 		name   string
 		idx    int
@@ -325,9 +238,11 @@ func TestCalculateIndex(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := calculateIndex(tt.idx, tt.length); got != tt.want {
-				t.Errorf("calculateIndex(%v, %v) = %v, want %v",
-					tt.idx, tt.length, got, tt.want)
+			seg := &sliceSegmentV3{start: tt.idx, end: tt.idx, step: 1, hasStart: true, hasEnd: true}
+			start, end, _ := seg.normalizeRange(tt.length)
+			if start != tt.want || end != tt.want {
+				t.Errorf("normalizeRange() bounds for (%v, %v) = %v, %v, want %v",
+					tt.idx, tt.length, start, end, tt.want)
 			}
 		})
 	}
@@ -399,7 +314,7 @@ func TestGenerateIndices(t *testing.T) {
 func TestNameSegmentEvaluate(t *testing.T) {
 	tests := []struct {
 		name        string
-		segment     *nameSegment
+		segment     *nameSegmentV3
 		value       interface{}
 		want        []interface{}
 		wantErr     bool
@@ -407,7 +322,7 @@ func TestNameSegmentEvaluate(t *testing.T) {
 	}{
 		{
 			name:    "simple field access",
-			segment: &nameSegment{name: "name"},
+			segment: &nameSegmentV3{name: "name"},
 			value: map[string]interface{}{
 				"name": "John",
 				"age":  30,
@@ -416,7 +331,7 @@ func TestNameSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name:    "nested field access",
-			segment: &nameSegment{name: "address"},
+			segment: &nameSegmentV3{name: "address"},
 			value: map[string]interface{}{
 				"name": "John",
 				"address": map[string]interface{}{
@@ -428,8 +343,8 @@ func TestNameSegmentEvaluate(t *testing.T) {
 			}},
 		},
 		{
-			name:        "field not found",
-			segment:     &nameSegment{name: "phone"},
+			name:    "field not found",
+			segment: &nameSegmentV3{name: "phone"},
 			value: map[string]interface{}{
 				"name": "John",
 				"age":  30,
@@ -439,83 +354,43 @@ func TestNameSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name:    "value is not an object",
-			segment: &nameSegment{name: "name"},
+			segment: &nameSegmentV3{name: "name"},
 			value:   "not an object",
 			want:    []interface{}{},
 		},
-		{
-			name:    "function call without arguments",
-			segment: &nameSegment{name: "length()"},
-			value:   []interface{}{1, 2, 3},
-			want:    []interface{}{float64(3)},
-		},
-		{
-			name:        "function call with arguments",
-			segment:     &nameSegment{name: "min(1,2,3)"},
-			value:       []interface{}{4, 5, 6},
-			wantErr:     true,
-			errContains: "invalid argument: min() requires exactly 1 argument",
-		},
-		{
-			name:    "function call with string argument",
-			segment: &nameSegment{name: "match('test pattern')"},
-			value:   "test pattern",
-			want:    []interface{}{true},
-		},
-		{
-			name:        "invalid function call syntax",
-			segment:     &nameSegment{name: "invalid("},
-			value:       []interface{}{1, 2, 3},
-			wantErr:     true,
-			errContains: "invalid function call syntax",
-		},
-		{
-			name:        "unknown function",
-			segment:     &nameSegment{name: "unknown()"},
-			value:       []interface{}{1, 2, 3},
-			wantErr:     true,
-			errContains: "unknown function",
-		},
-		{
-			name:        "invalid function arguments",
-			segment:     &nameSegment{name: "min('invalid')"},
-			value:       []interface{}{1, 2, 3},
-			wantErr:     true,
-			errContains: "invalid argument",
-		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.segment.evaluate(tt.value)
+			got, err := evalSegment(tt.segment, tt.value)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("nameSegment.evaluate() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("nameSegmentV3.evaluate() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if err != nil {
 				if !strings.Contains(err.Error(), tt.errContains) {
-					t.Errorf("nameSegment.evaluate() error = %v, want error containing %v", err, tt.errContains)
+					t.Errorf("nameSegmentV3.evaluate() error = %v, want error containing %v", err, tt.errContains)
 				}
 				return
 			}
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("nameSegment.evaluate() = %v, want %v", got, tt.want)
+				t.Errorf("nameSegmentV3.evaluate() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
 func TestNameSegmentString(t *testing.T) {
-	segment := &nameSegment{name: "test"}
-	if got := segment.String(); got != "test" {
-		t.Errorf("nameSegment.String() = %v, want %v", got, "test")
+	segment := &nameSegmentV3{name: "test"}
+	if got := segment.String(); got != "['test']" {
+		t.Errorf("nameSegmentV3.String() = %v, want %v", got, "['test']")
 	}
 }
 
 func TestIndexSegmentEvaluate(t *testing.T) {
 	tests := []struct {
 		name        string
-		segment     *indexSegment
+		segment     *indexSegmentV3
 		value       interface{}
 		want        []interface{}
 		wantErr     bool
@@ -523,64 +398,64 @@ func TestIndexSegmentEvaluate(t *testing.T) {
 	}{
 		{
 			name:    "positive index within bounds",
-			segment: &indexSegment{index: 1},
+			segment: &indexSegmentV3{index: 1},
 			value:   []interface{}{1, 2, 3},
 			want:    []interface{}{2},
 		},
 		{
 			name:    "zero index",
-			segment: &indexSegment{index: 0},
+			segment: &indexSegmentV3{index: 0},
 			value:   []interface{}{1, 2, 3},
 			want:    []interface{}{1},
 		},
 		{
 			name:    "negative index",
-			segment: &indexSegment{index: -1},
+			segment: &indexSegmentV3{index: -1},
 			value:   []interface{}{1, 2, 3},
 			want:    []interface{}{3},
 		},
 		{
 			name:    "index out of bounds (positive)",
-			segment: &indexSegment{index: 3},
+			segment: &indexSegmentV3{index: 3},
 			value:   []interface{}{1, 2, 3},
 			want:    []interface{}{},
 		},
 		{
 			name:    "index out of bounds (negative)",
-			segment: &indexSegment{index: -4},
+			segment: &indexSegmentV3{index: -4},
 			value:   []interface{}{1, 2, 3},
 			want:    []interface{}{},
 		},
 		{
 			name:    "empty array",
-			segment: &indexSegment{index: 0},
+			segment: &indexSegmentV3{index: 0},
 			value:   []interface{}{},
 			want:    []interface{}{},
 		},
 		{
-			name:        "value is not an array",
-			segment:     &indexSegment{index: 0},
-			value:       "not an array",
-			want:        []interface{}{},
-			wantErr:     false,
+			name:    "value is not an array",
+			segment: &indexSegmentV3{index: 0},
+			value:   "not an array",
+			want:    []interface{}{},
+			wantErr: false,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.segment.evaluate(tt.value)
+			got, err := evalSegment(tt.segment, tt.value)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("indexSegment.evaluate() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("indexSegmentV3.evaluate() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if err != nil {
 				if !strings.Contains(err.Error(), tt.errContains) {
-					t.Errorf("indexSegment.evaluate() error = %v, want error containing %v", err, tt.errContains)
+					t.Errorf("indexSegmentV3.evaluate() error = %v, want error containing %v", err, tt.errContains)
 				}
 				return
 			}
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("indexSegment.evaluate() = %v, want %v", got, tt.want)
+				t.Errorf("indexSegmentV3.evaluate() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -589,22 +464,22 @@ func TestIndexSegmentEvaluate(t *testing.T) {
 func TestIndexSegmentString(t *testing.T) {
 	tests := []struct {
 		name    string
-		segment *indexSegment
+		segment *indexSegmentV3
 		want    string
 	}{
 		{
 			name:    "positive index",
-			segment: &indexSegment{index: 1},
+			segment: &indexSegmentV3{index: 1},
 			want:    "[1]",
 		},
 		{
 			name:    "zero index",
-			segment: &indexSegment{index: 0},
+			segment: &indexSegmentV3{index: 0},
 			want:    "[0]",
 		},
 		{
 			name:    "negative index",
-			segment: &indexSegment{index: -1},
+			segment: &indexSegmentV3{index: -1},
 			want:    "[-1]",
 		},
 	}
@@ -612,7 +487,7 @@ func TestIndexSegmentString(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if got := tt.segment.String(); got != tt.want {
-				t.Errorf("indexSegment.String() = %v, want %v", got, tt.want)
+				t.Errorf("indexSegmentV3.String() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -621,43 +496,43 @@ func TestIndexSegmentString(t *testing.T) {
 func TestIndexSegmentNormalizeIndex(t *testing.T) {
 	tests := []struct {
 		name    string
-		segment *indexSegment
+		segment *indexSegmentV3
 		length  int
 		want    int
 	}{
 		{
 			name:    "positive index",
-			segment: &indexSegment{index: 1},
+			segment: &indexSegmentV3{index: 1},
 			length:  3,
 			want:    1,
 		},
 		{
 			name:    "zero index",
-			segment: &indexSegment{index: 0},
+			segment: &indexSegmentV3{index: 0},
 			length:  3,
 			want:    0,
 		},
 		{
 			name:    "negative index",
-			segment: &indexSegment{index: -1},
+			segment: &indexSegmentV3{index: -1},
 			length:  3,
 			want:    2,
 		},
 		{
 			name:    "negative index with length 1",
-			segment: &indexSegment{index: -1},
+			segment: &indexSegmentV3{index: -1},
 			length:  1,
 			want:    0,
 		},
 		{
 			name:    "negative index equals negative length",
-			segment: &indexSegment{index: -3},
+			segment: &indexSegmentV3{index: -3},
 			length:  3,
 			want:    0,
 		},
 		{
 			name:    "negative index exceeds length",
-			segment: &indexSegment{index: -4},
+			segment: &indexSegmentV3{index: -4},
 			length:  3,
 			want:    -1,
 		},
@@ -666,13 +541,13 @@ func TestIndexSegmentNormalizeIndex(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if got := tt.segment.normalizeIndex(tt.length); got != tt.want {
-				t.Errorf("indexSegment.normalizeIndex() = %v, want %v", got, tt.want)
+				t.Errorf("indexSegmentV3.normalizeIndex() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
-func TestCalculateStep(t *testing.T) {
+func TestSliceStepNormalization(t *testing.T) {
 	tests := []struct {
 		name string
 		step int
@@ -707,8 +582,9 @@ func TestCalculateStep(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := calculateStep(tt.step); got != tt.want {
-				t.Errorf("calculateStep() = %v, want %v", got, tt.want)
+			seg := &sliceSegmentV3{step: tt.step}
+			if _, _, got := seg.normalizeRange(5); got != tt.want {
+				t.Errorf("normalizeRange() step = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -717,7 +593,7 @@ func TestCalculateStep(t *testing.T) {
 func TestNormalizeRange(t *testing.T) {
 	tests := []struct {
 		name      string
-		segment   *sliceSegment
+		segment   *sliceSegmentV3
 		length    int
 		wantStart int
 		wantEnd   int
@@ -725,7 +601,7 @@ func TestNormalizeRange(t *testing.T) {
 	}{
 		{
 			name:      "default values with positive step",
-			segment:   &sliceSegment{start: 0, end: 0, step: 1, hasStart: false, hasEnd: false},
+			segment:   &sliceSegmentV3{start: 0, end: 0, step: 1, hasStart: false, hasEnd: false},
 			length:    5,
 			wantStart: 0,
 			wantEnd:   5,
@@ -733,7 +609,7 @@ func TestNormalizeRange(t *testing.T) {
 		},
 		{
 			name:      "default values with negative step",
-			segment:   &sliceSegment{start: 0, end: 0, step: -1, hasStart: false, hasEnd: false},
+			segment:   &sliceSegmentV3{start: 0, end: 0, step: -1, hasStart: false, hasEnd: false},
 			length:    5,
 			wantStart: 4,
 			wantEnd:   -1,
@@ -741,7 +617,7 @@ func TestNormalizeRange(t *testing.T) {
 		},
 		{
 			name:      "positive indices",
-			segment:   &sliceSegment{start: 1, end: 3, step: 1, hasStart: true, hasEnd: true},
+			segment:   &sliceSegmentV3{start: 1, end: 3, step: 1, hasStart: true, hasEnd: true},
 			length:    5,
 			wantStart: 1,
 			wantEnd:   3,
@@ -749,7 +625,7 @@ func TestNormalizeRange(t *testing.T) {
 		},
 		{
 			name:      "negative indices",
-			segment:   &sliceSegment{start: -2, end: -1, step: 1, hasStart: true, hasEnd: true},
+			segment:   &sliceSegmentV3{start: -2, end: -1, step: 1, hasStart: true, hasEnd: true},
 			length:    5,
 			wantStart: 3,
 			wantEnd:   4,
@@ -757,7 +633,7 @@ func TestNormalizeRange(t *testing.T) {
 		},
 		{
 			name:      "out of range indices",
-			segment:   &sliceSegment{start: 10, end: 20, step: 1, hasStart: true, hasEnd: true},
+			segment:   &sliceSegmentV3{start: 10, end: 20, step: 1, hasStart: true, hasEnd: true},
 			length:    5,
 			wantStart: 5,
 			wantEnd:   5,
@@ -765,7 +641,7 @@ func TestNormalizeRange(t *testing.T) {
 		},
 		{
 			name:      "negative out of range indices",
-			segment:   &sliceSegment{start: -10, end: -8, step: 1, hasStart: true, hasEnd: true},
+			segment:   &sliceSegmentV3{start: -10, end: -8, step: 1, hasStart: true, hasEnd: true},
 			length:    5,
 			wantStart: 0,
 			wantEnd:   0,
@@ -773,7 +649,7 @@ func TestNormalizeRange(t *testing.T) {
 		},
 		{
 			name:      "custom step",
-			segment:   &sliceSegment{start: 0, end: 5, step: 2, hasStart: true, hasEnd: true},
+			segment:   &sliceSegmentV3{start: 0, end: 5, step: 2, hasStart: true, hasEnd: true},
 			length:    5,
 			wantStart: 0,
 			wantEnd:   5,
@@ -781,7 +657,7 @@ func TestNormalizeRange(t *testing.T) {
 		},
 		{
 			name:      "negative step",
-			segment:   &sliceSegment{start: 4, end: 0, step: -1, hasStart: true, hasEnd: true},
+			segment:   &sliceSegmentV3{start: 4, end: 0, step: -1, hasStart: true, hasEnd: true},
 			length:    5,
 			wantStart: 4,
 			wantEnd:   0,
@@ -789,7 +665,7 @@ func TestNormalizeRange(t *testing.T) {
 		},
 		{
 			name:      "zero step",
-			segment:   &sliceSegment{start: 0, end: 5, step: 0, hasStart: true, hasEnd: true},
+			segment:   &sliceSegmentV3{start: 0, end: 5, step: 0, hasStart: true, hasEnd: true},
 			length:    5,
 			wantStart: 0,
 			wantEnd:   5,
@@ -797,7 +673,7 @@ func TestNormalizeRange(t *testing.T) {
 		},
 		{
 			name:      "empty array",
-			segment:   &sliceSegment{start: 0, end: 0, step: 1, hasStart: false, hasEnd: false},
+			segment:   &sliceSegmentV3{start: 0, end: 0, step: 1, hasStart: false, hasEnd: false},
 			length:    0,
 			wantStart: 0,
 			wantEnd:   0,
@@ -821,7 +697,7 @@ func TestNormalizeRange(t *testing.T) {
 	}
 }
 
-func TestGetArrayElements(t *testing.T) {
+func TestMultiIndexSegmentElements(t *testing.T) {
 	tests := []struct {
 		name    string
 		arr     []interface{}
@@ -849,13 +725,13 @@ func TestGetArrayElements(t *testing.T) {
 		{
 			name:    "out of range indices",
 			arr:     []interface{}{1, 2, 3},
-			indices: []int{-1, 3, 4},
+			indices: []int{-4, 3, 4},
 			want:    nil,
 		},
 		{
 			name:    "mixed valid and invalid indices",
 			arr:     []interface{}{1, 2, 3},
-			indices: []int{0, -1, 1, 3, 2},
+			indices: []int{0, -4, 1, 3, 2},
 			want:    []interface{}{1, 2, 3},
 		},
 		{
@@ -880,13 +756,13 @@ func TestGetArrayElements(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := getArrayElements(tt.arr, tt.indices)
+			got, _ := evalSegment(&multiIndexSegmentV3{indices: tt.indices}, tt.arr)
 			if tt.want == nil {
 				if len(got) != 0 {
-					t.Errorf("getArrayElements() = %v, want nil or empty slice", got)
+					t.Errorf("multiIndexSegmentV3.evaluate() = %v, want nil or empty slice", got)
 				}
 			} else if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("getArrayElements() = %v, want %v", got, tt.want)
+				t.Errorf("multiIndexSegmentV3.evaluate() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -895,14 +771,14 @@ func TestGetArrayElements(t *testing.T) {
 func TestSliceSegmentEvaluate(t *testing.T) {
 	tests := []struct {
 		name    string
-		segment *sliceSegment
+		segment *sliceSegmentV3
 		value   interface{}
 		want    []interface{}
 		wantErr bool
 	}{
 		{
 			name: "full slice",
-			segment: &sliceSegment{
+			segment: &sliceSegmentV3{
 				start: 0,
 				end:   0,
 				step:  1,
@@ -913,7 +789,7 @@ func TestSliceSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "positive step slice",
-			segment: &sliceSegment{
+			segment: &sliceSegmentV3{
 				start:    1,
 				end:      4,
 				step:     1,
@@ -926,7 +802,7 @@ func TestSliceSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "negative step slice",
-			segment: &sliceSegment{
+			segment: &sliceSegmentV3{
 				start: 0,
 				end:   0,
 				step:  -1,
@@ -937,7 +813,7 @@ func TestSliceSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "step with gap",
-			segment: &sliceSegment{
+			segment: &sliceSegmentV3{
 				start:    0,
 				end:      5,
 				step:     2,
@@ -950,7 +826,7 @@ func TestSliceSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "negative indices",
-			segment: &sliceSegment{
+			segment: &sliceSegmentV3{
 				start:    -2,
 				end:      0,
 				step:     1,
@@ -963,7 +839,7 @@ func TestSliceSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "out of range indices",
-			segment: &sliceSegment{
+			segment: &sliceSegmentV3{
 				start:    5,
 				end:      10,
 				step:     1,
@@ -976,7 +852,7 @@ func TestSliceSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "non-array value",
-			segment: &sliceSegment{
+			segment: &sliceSegmentV3{
 				start:    0,
 				end:      5,
 				step:     1,
@@ -989,7 +865,7 @@ func TestSliceSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "nil value",
-			segment: &sliceSegment{
+			segment: &sliceSegmentV3{
 				start:    0,
 				end:      5,
 				step:     1,
@@ -1004,18 +880,18 @@ func TestSliceSegmentEvaluate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.segment.evaluate(tt.value)
+			got, err := evalSegment(tt.segment, tt.value)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("sliceSegment.evaluate() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("sliceSegmentV3.evaluate() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !tt.wantErr {
 				if tt.want == nil {
 					if len(got) != 0 {
-						t.Errorf("sliceSegment.evaluate() = %v, want nil or empty slice", got)
+						t.Errorf("sliceSegmentV3.evaluate() = %v, want nil or empty slice", got)
 					}
 				} else if !reflect.DeepEqual(got, tt.want) {
-					t.Errorf("sliceSegment.evaluate() = %v, want %v", got, tt.want)
+					t.Errorf("sliceSegmentV3.evaluate() = %v, want %v", got, tt.want)
 				}
 			}
 		})
@@ -1024,62 +900,44 @@ func TestSliceSegmentEvaluate(t *testing.T) {
 
 func TestFilterSegmentString(t *testing.T) {
 	tests := []struct {
-		name    string
-		segment *filterSegment
-		want    string
+		name string
+		path string
+		want string
 	}{
 		{
 			name: "single condition",
-			segment: &filterSegment{
-				expr: &conditionNode{
-					cond: filterCondition{field: "price", operator: ">", value: float64(10)},
-				},
-			},
-			want: "[?@.price > 10]",
+			path: "$[?(@.price > 10)]",
+			want: "[?@['price'] > 10]",
 		},
 		{
 			name: "multiple conditions with AND",
-			segment: &filterSegment{
-				expr: &andNode{
-					children: []exprNode{
-						&conditionNode{cond: filterCondition{field: "price", operator: ">", value: float64(10)}},
-						&conditionNode{cond: filterCondition{field: "category", operator: "==", value: "book"}},
-					},
-				},
-			},
-			want: "[?@.price > 10 && @.category == 'book']",
+			path: "$[?(@.price > 10 && @.category == 'book')]",
+			want: "[?@['price'] > 10 && @['category'] == 'book']",
 		},
 		{
 			name: "multiple conditions with OR",
-			segment: &filterSegment{
-				expr: &orNode{
-					children: []exprNode{
-						&conditionNode{cond: filterCondition{field: "price", operator: "<", value: float64(5)}},
-						&conditionNode{cond: filterCondition{field: "price", operator: ">", value: float64(100)}},
-					},
-				},
-			},
-			want: "[?@.price < 5 || @.price > 100]",
+			path: "$[?(@.price < 5 || @.price > 100)]",
+			want: "[?@['price'] < 5 || @['price'] > 100]",
 		},
 		{
 			name: "complex conditions",
-			segment: &filterSegment{
-				expr: &andNode{
-					children: []exprNode{
-						&conditionNode{cond: filterCondition{field: "price", operator: ">", value: float64(10)}},
-						&conditionNode{cond: filterCondition{field: "category", operator: "==", value: "book"}},
-						&conditionNode{cond: filterCondition{field: "inStock", operator: "==", value: true}},
-					},
-				},
-			},
-			want: "[?@.price > 10 && @.category == 'book' && @.inStock == true]",
+			path: "$[?(@.price > 10 && @.category == 'book' && @.inStock == true)]",
+			want: "[?@['price'] > 10 && @['category'] == 'book' && @['inStock'] == true]",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.segment.String(); got != tt.want {
-				t.Errorf("filterSegment.String() = %v, want %v", got, tt.want)
+			c, err := Compile(tt.path)
+			if err != nil {
+				t.Fatalf("Compile() error: %v", err)
+			}
+			seg, ok := c.segments[0].(*filterSegmentV3)
+			if !ok {
+				t.Fatalf("Compile() returned %T, want *filterSegmentV3", c.segments[0])
+			}
+			if got := seg.String(); got != tt.want {
+				t.Errorf("filterSegmentV3.String() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -1088,14 +946,14 @@ func TestFilterSegmentString(t *testing.T) {
 func TestMultiIndexSegmentEvaluate(t *testing.T) {
 	tests := []struct {
 		name    string
-		segment *multiIndexSegment
+		segment *multiIndexSegmentV3
 		value   interface{}
 		want    []interface{}
 		wantErr bool
 	}{
 		{
 			name: "simple indices",
-			segment: &multiIndexSegment{
+			segment: &multiIndexSegmentV3{
 				indices: []int{0, 2, 4},
 			},
 			value:   []interface{}{1, 2, 3, 4, 5},
@@ -1104,7 +962,7 @@ func TestMultiIndexSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "negative indices",
-			segment: &multiIndexSegment{
+			segment: &multiIndexSegmentV3{
 				indices: []int{-1, -2},
 			},
 			value:   []interface{}{1, 2, 3, 4, 5},
@@ -1113,7 +971,7 @@ func TestMultiIndexSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "mixed indices",
-			segment: &multiIndexSegment{
+			segment: &multiIndexSegmentV3{
 				indices: []int{0, -1, 2},
 			},
 			value:   []interface{}{1, 2, 3, 4, 5},
@@ -1122,7 +980,7 @@ func TestMultiIndexSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "out of range indices",
-			segment: &multiIndexSegment{
+			segment: &multiIndexSegmentV3{
 				indices: []int{5, 6, -6},
 			},
 			value:   []interface{}{1, 2, 3, 4, 5},
@@ -1131,7 +989,7 @@ func TestMultiIndexSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "empty array",
-			segment: &multiIndexSegment{
+			segment: &multiIndexSegmentV3{
 				indices: []int{0, 1, 2},
 			},
 			value:   []interface{}{},
@@ -1140,7 +998,7 @@ func TestMultiIndexSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "empty indices",
-			segment: &multiIndexSegment{
+			segment: &multiIndexSegmentV3{
 				indices: []int{},
 			},
 			value:   []interface{}{1, 2, 3},
@@ -1149,7 +1007,7 @@ func TestMultiIndexSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "duplicate indices",
-			segment: &multiIndexSegment{
+			segment: &multiIndexSegmentV3{
 				indices: []int{0, 0, 1, 1},
 			},
 			value:   []interface{}{1, 2, 3},
@@ -1158,7 +1016,7 @@ func TestMultiIndexSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "non-array value",
-			segment: &multiIndexSegment{
+			segment: &multiIndexSegmentV3{
 				indices: []int{0, 1},
 			},
 			value:   "not an array",
@@ -1167,7 +1025,7 @@ func TestMultiIndexSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "nil value",
-			segment: &multiIndexSegment{
+			segment: &multiIndexSegmentV3{
 				indices: []int{0, 1},
 			},
 			value:   nil,
@@ -1178,18 +1036,18 @@ func TestMultiIndexSegmentEvaluate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.segment.evaluate(tt.value)
+			got, err := evalSegment(tt.segment, tt.value)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("multiIndexSegment.evaluate() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("multiIndexSegmentV3.evaluate() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !tt.wantErr {
 				if tt.want == nil {
 					if len(got) != 0 {
-						t.Errorf("multiIndexSegment.evaluate() = %v, want nil or empty slice", got)
+						t.Errorf("multiIndexSegmentV3.evaluate() = %v, want nil or empty slice", got)
 					}
 				} else if !reflect.DeepEqual(got, tt.want) {
-					t.Errorf("multiIndexSegment.evaluate() = %v, want %v", got, tt.want)
+					t.Errorf("multiIndexSegmentV3.evaluate() = %v, want %v", got, tt.want)
 				}
 			}
 		})
@@ -1199,14 +1057,14 @@ func TestMultiIndexSegmentEvaluate(t *testing.T) {
 func TestMultiNameSegmentEvaluate(t *testing.T) {
 	tests := []struct {
 		name    string
-		segment *multiNameSegment
+		segment *multiNameSegmentV3
 		value   interface{}
 		want    []interface{}
 		wantErr bool
 	}{
 		{
 			name: "simple names",
-			segment: &multiNameSegment{
+			segment: &multiNameSegmentV3{
 				names: []string{"name", "age"},
 			},
 			value: map[string]interface{}{
@@ -1219,7 +1077,7 @@ func TestMultiNameSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "missing field",
-			segment: &multiNameSegment{
+			segment: &multiNameSegmentV3{
 				names: []string{"name", "salary"},
 			},
 			value: map[string]interface{}{
@@ -1231,7 +1089,7 @@ func TestMultiNameSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "empty names",
-			segment: &multiNameSegment{
+			segment: &multiNameSegmentV3{
 				names: []string{},
 			},
 			value: map[string]interface{}{
@@ -1243,7 +1101,7 @@ func TestMultiNameSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "duplicate names",
-			segment: &multiNameSegment{
+			segment: &multiNameSegmentV3{
 				names: []string{"name", "name", "age"},
 			},
 			value: map[string]interface{}{
@@ -1255,7 +1113,7 @@ func TestMultiNameSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "non-object value",
-			segment: &multiNameSegment{
+			segment: &multiNameSegmentV3{
 				names: []string{"name", "age"},
 			},
 			value:   "not an object",
@@ -1264,7 +1122,7 @@ func TestMultiNameSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "nil value",
-			segment: &multiNameSegment{
+			segment: &multiNameSegmentV3{
 				names: []string{"name", "age"},
 			},
 			value:   nil,
@@ -1273,7 +1131,7 @@ func TestMultiNameSegmentEvaluate(t *testing.T) {
 		},
 		{
 			name: "mixed value types",
-			segment: &multiNameSegment{
+			segment: &multiNameSegmentV3{
 				names: []string{"name", "age", "active", "score"},
 			},
 			value: map[string]interface{}{
@@ -1289,18 +1147,18 @@ func TestMultiNameSegmentEvaluate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.segment.evaluate(tt.value)
+			got, err := evalSegment(tt.segment, tt.value)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("multiNameSegment.evaluate() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("multiNameSegmentV3.evaluate() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !tt.wantErr {
 				if tt.want == nil {
 					if len(got) != 0 {
-						t.Errorf("multiNameSegment.evaluate() = %v, want nil or empty slice", got)
+						t.Errorf("multiNameSegmentV3.evaluate() = %v, want nil or empty slice", got)
 					}
 				} else if !reflect.DeepEqual(got, tt.want) {
-					t.Errorf("multiNameSegment.evaluate() = %v, want %v", got, tt.want)
+					t.Errorf("multiNameSegmentV3.evaluate() = %v, want %v", got, tt.want)
 				}
 			}
 		})
@@ -1310,102 +1168,95 @@ func TestMultiNameSegmentEvaluate(t *testing.T) {
 func TestFunctionSegmentEvaluate(t *testing.T) {
 	tests := []struct {
 		name    string
-		segment *functionSegment
+		segment *callSegmentV3
 		value   interface{}
 		want    []interface{}
 		wantErr bool
 	}{
 		{
 			name: "length function without args",
-			segment: &functionSegment{
+			segment: &callSegmentV3{
 				name: "length",
-				args: []interface{}{},
 			},
-			value:   []interface{}{1, 2, 3},
-			want:    []interface{}{float64(3)},
-			wantErr: false,
+			value: []interface{}{1, 2, 3},
+			want:  []interface{}{float64(3)},
 		},
 		{
 			name: "min function with array",
-			segment: &functionSegment{
+			segment: &callSegmentV3{
 				name: "min",
-				args: []interface{}{[]interface{}{3, 1, 4, 1, 5}},
 			},
-			value:   nil,
-			want:    []interface{}{float64(1)},
-			wantErr: false,
+			value: []interface{}{3, 1, 4, 1, 5},
+			want:  []interface{}{float64(1)},
 		},
 		{
 			name: "max function with array",
-			segment: &functionSegment{
+			segment: &callSegmentV3{
 				name: "max",
-				args: []interface{}{[]interface{}{3, 1, 4, 1, 5}},
 			},
-			value:   nil,
-			want:    []interface{}{float64(5)},
-			wantErr: false,
+			value: []interface{}{3, 1, 4, 1, 5},
+			want:  []interface{}{float64(5)},
 		},
 		{
 			name: "avg function with array",
-			segment: &functionSegment{
+			segment: &callSegmentV3{
 				name: "avg",
-				args: []interface{}{[]interface{}{1, 2, 3, 4, 5}},
 			},
-			value:   nil,
-			want:    []interface{}{float64(3)},
-			wantErr: false,
+			value: []interface{}{1, 2, 3, 4, 5},
+			want:  []interface{}{float64(3)},
 		},
 		{
 			name: "unknown function",
-			segment: &functionSegment{
+			segment: &callSegmentV3{
 				name: "unknown",
-				args: []interface{}{},
 			},
 			value:   []interface{}{1, 2, 3},
-			want:    nil,
 			wantErr: true,
 		},
 		{
-			name: "function with invalid args",
-			segment: &functionSegment{
+			name: "function with invalid input",
+			segment: &callSegmentV3{
 				name: "min",
-				args: []interface{}{"not a number"},
 			},
-			value:   nil,
-			want:    nil,
+			value:   "not a number",
+			wantErr: true,
+		},
+		{
+			name: "function with extra args",
+			segment: &callSegmentV3{
+				name: "min",
+				args: []interface{}{float64(1)},
+			},
+			value:   []interface{}{1, 2, 3},
 			wantErr: true,
 		},
 		{
 			name: "function with empty array",
-			segment: &functionSegment{
+			segment: &callSegmentV3{
 				name: "min",
-				args: []interface{}{[]interface{}{}},
 			},
-			value:   nil,
-			want:    nil,
+			value:   []interface{}{},
 			wantErr: true,
 		},
 		{
 			name: "function with nil value",
-			segment: &functionSegment{
+			segment: &callSegmentV3{
 				name: "length",
-				args: []interface{}{nil},
 			},
 			value:   nil,
-			want:    nil,
 			wantErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.segment.evaluate(tt.value)
+			got, err := evalSegment(tt.segment, tt.value)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("functionSegment.evaluate() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("callSegmentV3.evaluate() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("functionSegment.evaluate() = %v, want %v", got, tt.want)
+				t.Errorf("callSegmentV3.evaluate() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -1502,10 +1353,9 @@ func TestWildcardSegmentEvaluate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := &wildcardSegment{}
-			got, err := s.evaluate(tt.value)
+			got, err := evalSegment(&wildcardSegmentV3{}, tt.value)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("wildcardSegment.evaluate() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("wildcardSegmentV3.evaluate() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !tt.wantErr {
@@ -1518,7 +1368,7 @@ func TestWildcardSegmentEvaluate(t *testing.T) {
 				sortSlice(got)
 				sortSlice(tt.want)
 				if !reflect.DeepEqual(got, tt.want) {
-					t.Errorf("wildcardSegment.evaluate() = %v, want %v", got, tt.want)
+					t.Errorf("wildcardSegmentV3.evaluate() = %v, want %v", got, tt.want)
 				}
 			}
 		})
@@ -1526,28 +1376,24 @@ func TestWildcardSegmentEvaluate(t *testing.T) {
 }
 
 func TestWildcardSegmentString(t *testing.T) {
-	s := &wildcardSegment{}
-	want := "*"
+	s := &wildcardSegmentV3{}
+	want := "[*]"
 	if got := s.String(); got != want {
-		t.Errorf("wildcardSegment.String() = %v, want %v", got, want)
+		t.Errorf("wildcardSegmentV3.String() = %v, want %v", got, want)
 	}
 }
 
 func TestFilterSegmentEvaluate(t *testing.T) {
 	tests := []struct {
 		name    string
-		segment *filterSegment
+		path    string
 		value   interface{}
 		want    []interface{}
 		wantErr bool
 	}{
 		{
 			name: "single condition - array",
-			segment: &filterSegment{
-				expr: &conditionNode{
-					cond: filterCondition{field: "price", operator: ">", value: float64(10)},
-				},
-			},
+			path: "$[?(@.price > 10)]",
 			value: []interface{}{
 				map[string]interface{}{"price": float64(5)},
 				map[string]interface{}{"price": float64(15)},
@@ -1558,31 +1404,20 @@ func TestFilterSegmentEvaluate(t *testing.T) {
 				map[string]interface{}{"price": float64(15)},
 				map[string]interface{}{"price": float64(20)},
 			},
-			wantErr: false,
 		},
 		{
 			name: "single condition - object",
-			segment: &filterSegment{
-				expr: &conditionNode{
-					cond: filterCondition{field: "price", operator: ">", value: float64(10)},
-				},
+			path: "$[?(@.price > 10)]",
+			value: map[string]interface{}{
+				"a": map[string]interface{}{"price": float64(15)},
 			},
-			value: map[string]interface{}{"price": float64(15)},
 			want: []interface{}{
 				map[string]interface{}{"price": float64(15)},
 			},
-			wantErr: false,
 		},
 		{
 			name: "multiple conditions with AND",
-			segment: &filterSegment{
-				expr: &andNode{
-					children: []exprNode{
-						&conditionNode{cond: filterCondition{field: "price", operator: ">", value: float64(10)}},
-						&conditionNode{cond: filterCondition{field: "category", operator: "==", value: "book"}},
-					},
-				},
-			},
+			path: "$[?(@.price > 10 && @.category == 'book')]",
 			value: []interface{}{
 				map[string]interface{}{"price": float64(15), "category": "book"},
 				map[string]interface{}{"price": float64(5), "category": "book"},
@@ -1593,18 +1428,10 @@ func TestFilterSegmentEvaluate(t *testing.T) {
 				map[string]interface{}{"price": float64(15), "category": "book"},
 				map[string]interface{}{"price": float64(25), "category": "book"},
 			},
-			wantErr: false,
 		},
 		{
 			name: "multiple conditions with OR",
-			segment: &filterSegment{
-				expr: &orNode{
-					children: []exprNode{
-						&conditionNode{cond: filterCondition{field: "price", operator: "<", value: float64(10)}},
-						&conditionNode{cond: filterCondition{field: "price", operator: ">", value: float64(20)}},
-					},
-				},
-			},
+			path: "$[?(@.price < 10 || @.price > 20)]",
 			value: []interface{}{
 				map[string]interface{}{"price": float64(5)},
 				map[string]interface{}{"price": float64(15)},
@@ -1614,15 +1441,10 @@ func TestFilterSegmentEvaluate(t *testing.T) {
 				map[string]interface{}{"price": float64(5)},
 				map[string]interface{}{"price": float64(25)},
 			},
-			wantErr: false,
 		},
 		{
 			name: "regex match condition",
-			segment: &filterSegment{
-				expr: &conditionNode{
-					cond: filterCondition{field: "name", operator: "match", value: "^J.*n$"},
-				},
-			},
+			path: "$[?match(@.name, 'J.*n')]",
 			value: []interface{}{
 				map[string]interface{}{"name": "John"},
 				map[string]interface{}{"name": "Jane"},
@@ -1631,115 +1453,86 @@ func TestFilterSegmentEvaluate(t *testing.T) {
 			want: []interface{}{
 				map[string]interface{}{"name": "John"},
 			},
-			wantErr: false,
 		},
 		{
 			name: "invalid regex pattern",
-			segment: &filterSegment{
-				expr: &conditionNode{
-					cond: filterCondition{field: "name", operator: "match", value: "[invalid"},
-				},
-			},
+			path: "$[?match(@.name, '[invalid')]",
 			value: []interface{}{
 				map[string]interface{}{"name": "John"},
 			},
-			want:    nil,
-			wantErr: false,
+			want: nil,
 		},
 		{
 			name: "field not found",
-			segment: &filterSegment{
-				expr: &conditionNode{
-					cond: filterCondition{field: "missing", operator: ">", value: float64(10)},
-				},
-			},
+			path: "$[?(@.missing > 10)]",
 			value: []interface{}{
 				map[string]interface{}{"price": float64(15)},
 			},
-			want:    nil,
-			wantErr: false,
+			want: nil,
 		},
 		{
 			name: "invalid operator",
-			segment: &filterSegment{
-				expr: &conditionNode{
-					cond: filterCondition{field: "price", operator: "invalid", value: float64(10)},
-				},
-			},
+			path: "$[?(@.price ~= 10)]",
 			value: []interface{}{
 				map[string]interface{}{"price": float64(15)},
 			},
-			want:    nil,
 			wantErr: true,
 		},
 		{
 			name: "non-object array elements",
-			segment: &filterSegment{
-				expr: &conditionNode{
-					cond: filterCondition{field: "price", operator: ">", value: float64(10)},
-				},
-			},
+			path: "$[?(@.price > 10)]",
 			value: []interface{}{
 				"string",
 				42,
 				true,
 			},
-			want:    nil,
-			wantErr: false,
-		},
-		{
-			name: "nil value",
-			segment: &filterSegment{
-				expr: &conditionNode{
-					cond: filterCondition{field: "price", operator: ">", value: float64(10)},
-				},
-			},
-			value:   nil,
-			want:    nil,
-			wantErr: false,
+			want: nil,
 		},
 		{
-			name: "non-array/object value",
-			segment: &filterSegment{
-				expr: &conditionNode{
-					cond: filterCondition{field: "price", operator: ">", value: float64(10)},
-				},
-			},
-			value:   "not an array or object",
-			want:    nil,
-			wantErr: false,
+			name:  "nil value",
+			path:  "$[?(@.price > 10)]",
+			value: nil,
+			want:  nil,
 		},
 		{
-			name: "empty conditions",
-			segment: &filterSegment{
-				expr: nil,
-			},
-			value: []interface{}{
-				map[string]interface{}{"price": float64(15)},
-			},
-			want:    nil,
-			wantErr: false,
+			name:  "non-array/object value",
+			path:  "$[?(@.price > 10)]",
+			value: "not an array or object",
+			want:  nil,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.segment.evaluate(tt.value)
+			c, err := Compile(tt.path)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("filterSegment.evaluate() error = %v, wantErr %v", err, tt.wantErr)
+				t.Fatalf("Compile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
 				return
 			}
-			if !tt.wantErr {
-				if tt.want == nil {
-					if len(got) != 0 {
-						t.Errorf("filterSegment.evaluate() = %v, want nil or empty slice", got)
-					}
-				} else if !reflect.DeepEqual(got, tt.want) {
-					t.Errorf("filterSegment.evaluate() = %v, want %v", got, tt.want)
+			got, err := evalSegment(c.segments[0], tt.value)
+			if err != nil {
+				t.Fatalf("filterSegmentV3.evaluate() error = %v", err)
+			}
+			if tt.want == nil {
+				if len(got) != 0 {
+					t.Errorf("filterSegmentV3.evaluate() = %v, want nil or empty slice", got)
 				}
+			} else if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterSegmentV3.evaluate() = %v, want %v", got, tt.want)
 			}
 		})
 	}
+
+	t.Run("empty conditions", func(t *testing.T) {
+		got, err := evalSegment(&filterSegmentV3{}, []interface{}{
+			map[string]interface{}{"price": float64(15)},
+		})
+		if err != nil || len(got) != 0 {
+			t.Errorf("filterSegmentV3.evaluate() = %v, %v, want empty result", got, err)
+		}
+	})
 }
 
 func TestRecursiveDescentIncludesRoot(t *testing.T) {
@@ -1775,8 +1568,8 @@ func TestRecursiveDescentIncludesRoot(t *testing.T) {
 				"a": 1,
 				"b": 2,
 			},
-			path:     `$..*`,
-			expected: []interface{}{1, 2},
+			path:      `$..*`,
+			expected:  []interface{}{1, 2},
 			unordered: true,
 		},
 	}
@@ -1825,3 +1618,35 @@ func TestRecursiveDescentIncludesRoot(t *testing.T) {
 		})
 	}
 }
+
+func TestObjectTraversalOrder(t *testing.T) {
+	data := map[string]interface{}{
+		"d": 4.0, "b": 2.0, "a": map[string]interface{}{"z": 26.0, "y": 25.0}, "c": 3.0,
+	}
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"$.*", []string{"$['a']", "$['b']", "$['c']", "$['d']"}},
+		{"$..*", []string{"$['a']", "$['b']", "$['c']", "$['d']", "$['a']['y']", "$['a']['z']"}},
+		{"$[?@ > 1]", []string{"$['b']", "$['c']", "$['d']"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			// Repeat to make sure map iteration order never leaks into results
+			for i := 0; i < 20; i++ {
+				result, err := Query(data, tt.path)
+				if err != nil {
+					t.Fatalf("Query() error = %v", err)
+				}
+				got := make([]string, len(result))
+				for j, n := range result {
+					got[j] = n.Location
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("Query() locations = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -2,20 +2,23 @@ package jsonpath
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/davidhoo/jsonpath/ast"
 )
 
 // segmentV3 is the new segment interface using Node/NodeList
 type segmentV3 interface {
-	evaluate(node Node) (NodeList, error)
+	evaluate(ctx *evalContext, node Node) (NodeList, error)
 	String() string
 }
 
 // wildcardSegmentV3 implements wildcard (*) for the v3 interface
 type wildcardSegmentV3 struct{}
 
-func (s *wildcardSegmentV3) evaluate(node Node) (NodeList, error) {
+func (s *wildcardSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
 	switch v := node.Value.(type) {
 	case []interface{}:
 		result := make(NodeList, len(v))
@@ -24,16 +27,19 @@ func (s *wildcardSegmentV3) evaluate(node Node) (NodeList, error) {
 				Location: node.Location + "[" + strconv.Itoa(i) + "]",
 				Value:    item,
 				Root:     node.Root,
+				computed: node.computed,
 			}
 		}
 		return result, nil
 	case map[string]interface{}:
 		result := make(NodeList, 0, len(v))
-		for key, val := range v {
+		for _, key := range sortedKeys(v) {
+			val := v[key]
 			result = append(result, Node{
 				Location: node.Location + "['" + escapeNormalizedPathKey(key) + "']",
 				Value:    val,
 				Root:     node.Root,
+				computed: node.computed,
 			})
 		}
 		return result, nil
@@ -42,59 +48,65 @@ func (s *wildcardSegmentV3) evaluate(node Node) (NodeList, error) {
 	}
 }
 
-func (s *wildcardSegmentV3) String() string { return "*" }
+func (s *wildcardSegmentV3) String() string { return "[*]" }
 
 // nameSegmentV3 implements member access (.name) for the v3 interface
 type nameSegmentV3 struct {
 	name string
 }
 
-func (s *nameSegmentV3) evaluate(node Node) (NodeList, error) {
-	if strings.Contains(s.name, "(") {
-		return s.evaluateFunction(node)
-	}
+func (s *nameSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
 	obj, ok := node.Value.(map[string]interface{})
 	if !ok {
 		return NodeList{}, nil
 	}
-	val, exists := obj[s.name]
+	val, key, exists := ctx.lookupMember(obj, s.name)
 	if !exists {
 		return NodeList{}, nil
 	}
 	return NodeList{{
-		Location: node.Location + "['" + escapeNormalizedPathKey(s.name) + "']",
+		Location: node.Location + "['" + escapeNormalizedPathKey(key) + "']",
 		Value:    val,
 		Root:     node.Root,
+		computed: node.computed,
 	}}, nil
 }
 
-func (s *nameSegmentV3) evaluateFunction(node Node) (NodeList, error) {
-	openParen := strings.Index(s.name, "(")
-	closeParen := strings.LastIndex(s.name, ")")
-	if openParen == -1 || closeParen == -1 || openParen > closeParen {
-		return nil, fmt.Errorf("invalid function call syntax: malformed function call")
-	}
-	funcName := s.name[:openParen]
-	argsStr := s.name[openParen+1 : closeParen]
-	fn, err := GetFunction(funcName)
+func (s *nameSegmentV3) String() string {
+	return "[" + canonicalName(s.name) + "]"
+}
+
+// callSegmentV3 implements a function segment such as .length() or
+// .occurrences(2), which calls the function with the input node's value
+// followed by the literal arguments. A result of Nothing selects nothing.
+type callSegmentV3 struct {
+	name string
+	args []interface{}
+}
+
+func (s *callSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
+	fn, err := ctx.opts.parse.function(s.name)
 	if err != nil {
-		return nil, fmt.Errorf("unknown function: %s", funcName)
+		return nil, fmt.Errorf("unknown function: %s", s.name)
 	}
-	var args []interface{}
-	if argsStr != "" {
-		parsedArgs, err := parseFunctionArgs(argsStr)
-		if err != nil {
-			return nil, fmt.Errorf("invalid argument: %v", err)
+	args := make([]interface{}, 0, len(s.args)+1)
+	args = append(args, node.Value)
+	for _, arg := range s.args {
+		if q, ok := arg.(queryArg); ok {
+			arg = &boundQuery{segments: q, ctx: ctx}
 		}
-		args = append([]interface{}{node.Value}, parsedArgs...)
-	} else {
-		args = []interface{}{node.Value}
+		args = append(args, arg)
 	}
 	result, err := fn.Call(args)
 	if err != nil {
-		return nil, fmt.Errorf("invalid argument: %v", err)
+		if _, ok := err.(*Error); ok {
+			return nil, err
+		}
+		return nil, NewError(ErrInvalidArgument, fmt.Sprintf("invalid argument: %v", err), s.name)
 	}
 	switch v := result.(type) {
+	case Nothing:
+		return NodeList{}, nil
 	case int:
 		result = float64(v)
 	case int64:
@@ -104,17 +116,27 @@ func (s *nameSegmentV3) evaluateFunction(node Node) (NodeList, error) {
 	case float32:
 		result = float64(v)
 	}
-	return NodeList{{Location: node.Location, Value: result, Root: node.Root}}, nil
+	return NodeList{{Location: node.Location, Value: result, Root: node.Root, computed: true}}, nil
 }
 
-func (s *nameSegmentV3) String() string { return s.name }
+func (s *callSegmentV3) String() string {
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		if q, ok := arg.(queryArg); ok {
+			args[i] = canonicalSegments("@", q)
+			continue
+		}
+		args[i] = (&ast.Literal{Value: arg}).String()
+	}
+	return "." + s.name + "(" + strings.Join(args, ", ") + ")"
+}
 
 // indexSegmentV3 implements array index access ([i]) for the v3 interface
 type indexSegmentV3 struct {
 	index int
 }
 
-func (s *indexSegmentV3) evaluate(node Node) (NodeList, error) {
+func (s *indexSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
 	arr, ok := node.Value.([]interface{})
 	if !ok {
 		return NodeList{}, nil
@@ -127,6 +149,7 @@ func (s *indexSegmentV3) evaluate(node Node) (NodeList, error) {
 		Location: node.Location + "[" + strconv.Itoa(idx) + "]",
 		Value:    arr[idx],
 		Root:     node.Root,
+		computed: node.computed,
 	}}, nil
 }
 
@@ -147,7 +170,10 @@ type sliceSegmentV3 struct {
 	hasStart, hasEnd bool
 }
 
-func (s *sliceSegmentV3) evaluate(node Node) (NodeList, error) {
+func (s *sliceSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
+	if str, ok := node.Value.(string); ok && ctx != nil && ctx.opts.stringSlices {
+		return s.sliceString(node, str), nil
+	}
 	arr, ok := node.Value.([]interface{})
 	if !ok {
 		return NodeList{}, nil
@@ -168,12 +194,31 @@ func (s *sliceSegmentV3) evaluate(node Node) (NodeList, error) {
 				Location: node.Location + "[" + strconv.Itoa(idx) + "]",
 				Value:    arr[idx],
 				Root:     node.Root,
+				computed: node.computed,
 			})
 		}
 	}
 	return result, nil
 }
 
+// sliceString selects the characters of str as an array slice would select
+// elements, yielding one node holding the substring at the location of the
+// string itself. The substring is not a value found in the document.
+func (s *sliceSegmentV3) sliceString(node Node, str string) NodeList {
+	if s.step == 0 {
+		return NodeList{}
+	}
+	runes := []rune(str)
+	start, end, step := s.normalizeRange(len(runes))
+	var b strings.Builder
+	for _, idx := range generateIndices(start, end, step) {
+		if idx >= 0 && idx < len(runes) {
+			b.WriteRune(runes[idx])
+		}
+	}
+	return NodeList{{Location: node.Location, Value: b.String(), Root: node.Root, computed: true}}
+}
+
 func (s *sliceSegmentV3) normalizeRange(length int) (start, end, step int) {
 	step = s.step
 	if step == 0 {
@@ -231,9 +276,13 @@ func (s *sliceSegmentV3) normalizeRange(length int) (start, end, step int) {
 func (s *sliceSegmentV3) String() string {
 	var result strings.Builder
 	result.WriteString("[")
-	result.WriteString(strconv.Itoa(s.start))
+	if s.hasStart {
+		result.WriteString(strconv.Itoa(s.start))
+	}
 	result.WriteString(":")
-	result.WriteString(strconv.Itoa(s.end))
+	if s.hasEnd {
+		result.WriteString(strconv.Itoa(s.end))
+	}
 	if s.step != 1 {
 		result.WriteString(":")
 		result.WriteString(strconv.Itoa(s.step))
@@ -242,12 +291,52 @@ func (s *sliceSegmentV3) String() string {
 	return result.String()
 }
 
+// 根据步长生成索引序列
+func generateIndices(start, end, step int) []int {
+	// 处理零步长
+	if step == 0 {
+		step = 1
+	}
+
+	// 检查无效的范围
+	if step > 0 && start >= end {
+		return nil
+	}
+	if step < 0 && start <= end {
+		return nil
+	}
+
+	// 计算需要生成的索引数量
+	count := 0
+	if step > 0 {
+		count = (end - start + step - 1) / step
+	} else {
+		count = (start - end - step - 1) / (-step)
+	}
+
+	// 预分配切片
+	indices := make([]int, 0, count)
+
+	// 生成索引
+	if step > 0 {
+		for i := start; i < end; i += step {
+			indices = append(indices, i)
+		}
+	} else {
+		for i := start; i > end; i += step {
+			indices = append(indices, i)
+		}
+	}
+
+	return indices
+}
+
 // multiIndexSegmentV3 implements multi-index ([i,j,k]) for the v3 interface
 type multiIndexSegmentV3 struct {
 	indices []int
 }
 
-func (s *multiIndexSegmentV3) evaluate(node Node) (NodeList, error) {
+func (s *multiIndexSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
 	arr, ok := node.Value.([]interface{})
 	if !ok {
 		return NodeList{}, nil
@@ -263,6 +352,7 @@ func (s *multiIndexSegmentV3) evaluate(node Node) (NodeList, error) {
 				Location: node.Location + "[" + strconv.Itoa(idx) + "]",
 				Value:    arr[idx],
 				Root:     node.Root,
+				computed: node.computed,
 			})
 		}
 	}
@@ -282,18 +372,19 @@ type multiNameSegmentV3 struct {
 	names []string
 }
 
-func (s *multiNameSegmentV3) evaluate(node Node) (NodeList, error) {
+func (s *multiNameSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
 	obj, ok := node.Value.(map[string]interface{})
 	if !ok {
 		return NodeList{}, nil
 	}
 	var result NodeList
 	for _, name := range s.names {
-		if val, exists := obj[name]; exists {
+		if val, key, exists := ctx.lookupMember(obj, name); exists {
 			result = append(result, Node{
-				Location: node.Location + "['" + escapeNormalizedPathKey(name) + "']",
+				Location: node.Location + "['" + escapeNormalizedPathKey(key) + "']",
 				Value:    val,
 				Root:     node.Root,
+				computed: node.computed,
 			})
 		}
 	}
@@ -303,44 +394,76 @@ func (s *multiNameSegmentV3) evaluate(node Node) (NodeList, error) {
 func (s *multiNameSegmentV3) String() string {
 	names := make([]string, len(s.names))
 	for i, name := range s.names {
-		names[i] = fmt.Sprintf("'%s'", name)
+		names[i] = canonicalName(name)
 	}
-	return fmt.Sprintf("[%s]", strings.Join(names, ","))
+	return "[" + strings.Join(names, ",") + "]"
 }
 
 // recursiveSegmentV3 implements recursive descent (..) for the v3 interface
 type recursiveSegmentV3 struct{}
 
-func (s *recursiveSegmentV3) evaluate(node Node) (NodeList, error) {
+func (s *recursiveSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
 	var result NodeList
 	result = append(result, node)
-	s.collect(node, &result)
+	if err := s.collect(ctx, node, 1, &result); err != nil {
+		return nil, err
+	}
 	return result, nil
 }
 
-func (s *recursiveSegmentV3) collect(node Node, result *NodeList) {
+func (s *recursiveSegmentV3) collect(ctx *evalContext, node Node, depth int, result *NodeList) error {
 	switch v := node.Value.(type) {
 	case []interface{}:
+		if len(v) > 0 {
+			if err := ctx.checkDepth(depth); err != nil {
+				return err
+			}
+		}
 		for i, item := range v {
+			if err := ctx.step(); err != nil {
+				return err
+			}
 			child := Node{
 				Location: node.Location + "[" + strconv.Itoa(i) + "]",
 				Value:    item,
 				Root:     node.Root,
+				computed: node.computed,
 			}
 			*result = append(*result, child)
-			s.collect(child, result)
+			if err := ctx.checkResults(len(*result)); err != nil {
+				return err
+			}
+			if err := s.collect(ctx, child, depth+1, result); err != nil {
+				return err
+			}
 		}
 	case map[string]interface{}:
-		for key, val := range v {
+		if len(v) > 0 {
+			if err := ctx.checkDepth(depth); err != nil {
+				return err
+			}
+		}
+		for _, key := range sortedKeys(v) {
+			val := v[key]
+			if err := ctx.step(); err != nil {
+				return err
+			}
 			child := Node{
 				Location: node.Location + "['" + escapeNormalizedPathKey(key) + "']",
 				Value:    val,
 				Root:     node.Root,
+				computed: node.computed,
 			}
 			*result = append(*result, child)
-			s.collect(child, result)
+			if err := ctx.checkResults(len(*result)); err != nil {
+				return err
+			}
+			if err := s.collect(ctx, child, depth+1, result); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
 }
 
 func (s *recursiveSegmentV3) String() string { return ".." }
@@ -350,7 +473,7 @@ type filterSegmentV3 struct {
 	expr exprNode
 }
 
-func (s *filterSegmentV3) evaluate(node Node) (NodeList, error) {
+func (s *filterSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
 	if s.expr == nil {
 		return nil, nil
 	}
@@ -362,11 +485,29 @@ func (s *filterSegmentV3) evaluate(node Node) (NodeList, error) {
 	if root == nil {
 		root = node.Value
 	}
+	defer func(key interface{}) { ctx.key = key }(ctx.key)
 	if m, ok := node.Value.(map[string]interface{}); ok {
+		if ctx.opts.objectFilterSelf {
+			ctx.key = nil
+			// Legacy behavior: test the object itself
+			if err := ctx.step(); err != nil {
+				return nil, err
+			}
+			result, err := s.expr.evaluate(ctx, m, root)
+			if err != nil || !result {
+				return nil, err
+			}
+			return NodeList{node}, nil
+		}
 		// RFC 9535: filter on object iterates through object values
 		var results NodeList
-		for key, item := range m {
-			result, err := s.expr.evaluate(item, root)
+		for _, key := range sortedKeys(m) {
+			item := m[key]
+			if err := ctx.step(); err != nil {
+				return nil, err
+			}
+			ctx.key = key
+			result, err := s.expr.evaluate(ctx, item, root)
 			if err != nil {
 				return nil, err
 			}
@@ -375,6 +516,7 @@ func (s *filterSegmentV3) evaluate(node Node) (NodeList, error) {
 					Location: node.Location + "['" + escapeNormalizedPathKey(key) + "']",
 					Value:    item,
 					Root:     node.Root,
+					computed: node.computed,
 				})
 			}
 		}
@@ -383,7 +525,11 @@ func (s *filterSegmentV3) evaluate(node Node) (NodeList, error) {
 	if arr, ok := node.Value.([]interface{}); ok {
 		var results NodeList
 		for i, item := range arr {
-			result, err := s.expr.evaluate(item, root)
+			if err := ctx.step(); err != nil {
+				return nil, err
+			}
+			ctx.key = i
+			result, err := s.expr.evaluate(ctx, item, root)
 			if err != nil {
 				return nil, err
 			}
@@ -392,6 +538,7 @@ func (s *filterSegmentV3) evaluate(node Node) (NodeList, error) {
 					Location: node.Location + "[" + strconv.Itoa(i) + "]",
 					Value:    item,
 					Root:     node.Root,
+					computed: node.computed,
 				})
 			}
 		}
@@ -401,17 +548,39 @@ func (s *filterSegmentV3) evaluate(node Node) (NodeList, error) {
 }
 
 func (s *filterSegmentV3) String() string {
-	return "[?" + exprToString(s.expr) + "]"
+	return "[?" + canonicalExpr(s.expr) + "]"
 }
 
 // functionSegmentV3 implements function calls for the v3 interface
 type functionSegmentV3 struct {
 	name string
 	args []interface{}
+	call *ast.FunctionCall
 }
 
-func (s *functionSegmentV3) evaluate(node Node) (NodeList, error) {
-	fn, err := GetFunction(s.name)
+// queryArg is a query argument of a function call, compiled along with
+// the rest of the expression
+type queryArg []segmentV3
+
+// boundQuery is a relative query argument handed to a function segment.
+// It runs within the evaluation of the whole expression, so it counts
+// against the same limits and honours the same options.
+type boundQuery struct {
+	segments []segmentV3
+	ctx      *evalContext
+}
+
+// values returns the values the query selects from item
+func (q *boundQuery) values(item interface{}) ([]interface{}, error) {
+	nodes, err := q.ctx.evaluate(q.segments, item)
+	if err != nil {
+		return nil, err
+	}
+	return nodeValues(nodes), nil
+}
+
+func (s *functionSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
+	fn, err := ctx.opts.parse.function(s.name)
 	if err != nil {
 		return nil, err
 	}
@@ -419,19 +588,18 @@ func (s *functionSegmentV3) evaluate(node Node) (NodeList, error) {
 	if len(s.args) == 0 {
 		args = []interface{}{node.Value}
 	} else {
-		// 解析路径参数
 		args = make([]interface{}, len(s.args))
 		for i, arg := range s.args {
-			if pathStr, ok := arg.(string); ok && len(pathStr) > 0 && pathStr[0] == '$' {
-				// 这是一个路径引用，需要解析并求值
-				resolved, err := resolvePath(pathStr, node)
-				if err != nil {
-					return nil, fmt.Errorf("failed to resolve path %q: %v", pathStr, err)
-				}
-				args[i] = resolved
-			} else {
+			q, ok := arg.(queryArg)
+			if !ok {
 				args[i] = arg
+				continue
+			}
+			resolved, err := resolveQuery(ctx, q, node)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve path %q: %v", s.call.Args[i].String(), err)
 			}
+			args[i] = resolved
 		}
 	}
 	result, err := fn.Call(args)
@@ -451,55 +619,27 @@ func (s *functionSegmentV3) evaluate(node Node) (NodeList, error) {
 	if arr, ok := result.([]interface{}); ok {
 		nl := make(NodeList, len(arr))
 		for i, item := range arr {
-			nl[i] = Node{Location: node.Location, Value: item, Root: node.Root}
+			nl[i] = Node{Location: node.Location, Value: item, Root: node.Root, computed: true}
 		}
 		return nl, nil
 	}
-	return NodeList{{Location: node.Location, Value: result, Root: node.Root}}, nil
+	return NodeList{{Location: node.Location, Value: result, Root: node.Root, computed: true}}, nil
 }
 
-// resolvePath 解析并求值 JSONPath 表达式
-func resolvePath(pathStr string, currentNode Node) (interface{}, error) {
-	// 解析路径
-	segments, err := parse(pathStr)
+// resolveQuery evaluates a query argument against the root of a top-level
+// call. It returns nil when nothing matches, the value of a single match,
+// and an array of values otherwise.
+func resolveQuery(ctx *evalContext, q queryArg, root Node) (interface{}, error) {
+	nodeList, err := ctx.evaluateFrom(q, root)
 	if err != nil {
 		return nil, err
 	}
-
-	// 转换为 v3 segments
-	v3Segments := wrapSegments(segments)
-
-	// 使用根节点开始求值
-	// 注意：这里我们需要找到根节点的值
-	// 由于我们只有当前节点，我们需要从当前节点向上遍历
-	// 但在这个实现中，我们假设路径是从根开始的
-	// 所以我们需要从根节点开始求值
-
-	// 对于顶层函数调用，当前节点就是根节点
-	root := currentNode
-
-	// 求值
-	nodeList := NodeList{root}
-	for _, seg := range v3Segments {
-		var newNodeList NodeList
-		for _, n := range nodeList {
-			evaluated, err := seg.evaluate(n)
-			if err != nil {
-				return nil, err
-			}
-			newNodeList = append(newNodeList, evaluated...)
-		}
-		nodeList = newNodeList
-	}
-
-	// 返回结果
 	if len(nodeList) == 0 {
 		return nil, nil
 	}
 	if len(nodeList) == 1 {
 		return nodeList[0].Value, nil
 	}
-	// 返回所有值作为数组
 	values := make([]interface{}, len(nodeList))
 	for i, n := range nodeList {
 		values[i] = n.Value
@@ -508,24 +648,19 @@ func resolvePath(pathStr string, currentNode Node) (interface{}, error) {
 }
 
 func (s *functionSegmentV3) String() string {
-	args := make([]string, len(s.args))
-	for i, arg := range s.args {
-		switch v := arg.(type) {
-		case string:
-			args[i] = fmt.Sprintf("'%s'", v)
-		case float64:
-			args[i] = strconv.FormatFloat(v, 'f', -1, 64)
-		case int:
-			args[i] = strconv.Itoa(v)
-		case bool:
-			args[i] = strconv.FormatBool(v)
-		case nil:
-			args[i] = "null"
-		default:
-			args[i] = fmt.Sprintf("%v", v)
-		}
+	return s.call.String()
+}
+
+// sortedKeys returns the keys of an object in ascending order.
+// Object members are always visited in this order so that wildcard,
+// descendant and filter results are reproducible across runs.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
-	return fmt.Sprintf("%s(%s)", s.name, strings.Join(args, ","))
+	sort.Strings(keys)
+	return keys
 }
 
 // escapeNormalizedPathKey escapes a key for use in Normalized Path
@@ -546,63 +681,161 @@ func escapeNormalizedPathKey(key string) string {
 	return result.String()
 }
 
-// wrapSegments converts old segment types to new segmentV3 types
-func wrapSegments(oldSegs []segment) []segmentV3 {
-	newSegs := make([]segmentV3, len(oldSegs))
-	for i, seg := range oldSegs {
+// nodeListSegment is implemented by segments that consume the whole
+// nodelist selected by the preceding segments rather than one node at a
+// time
+type nodeListSegment interface {
+	evaluateAll(ctx *evalContext, nodes NodeList) (NodeList, error)
+}
+
+// aggregateSegmentV3 applies an aggregate function such as sum() to the
+// values of all nodes selected by the preceding segments. The result is
+// located at the closest common ancestor of those nodes.
+type aggregateSegmentV3 struct {
+	call *callSegmentV3
+}
+
+func (s *aggregateSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
+	return s.evaluateAll(ctx, NodeList{node})
+}
+
+func (s *aggregateSegmentV3) evaluateAll(ctx *evalContext, nodes NodeList) (NodeList, error) {
+	if len(nodes) == 0 {
+		return NodeList{}, nil
+	}
+	values := make([]interface{}, len(nodes))
+	for i, n := range nodes {
+		values[i] = n.Value
+	}
+	location := commonAncestor(nodes)
+	return s.call.evaluate(ctx, Node{Location: location, Value: values, Root: nodes[0].Root})
+}
+
+func (s *aggregateSegmentV3) String() string {
+	return s.call.String()
+}
+
+// fallbackSegmentV3 applies a fallback function such as default() to the
+// node selected from each input node by a chain of names and indices. When
+// the chain selects nothing the function is called with Nothing, and its
+// result is located where the chain would have led.
+type fallbackSegmentV3 struct {
+	chain []segmentV3
+	call  *callSegmentV3
+}
+
+func (s *fallbackSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
+	nodes, err := ctx.evaluateFrom(s.chain, node)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		nodes = NodeList{{Location: missingLocation(node.Location, s.chain), Value: Nothing{}, Root: node.Root}}
+	}
+	return s.call.evaluate(ctx, nodes[0])
+}
+
+func (s *fallbackSegmentV3) String() string {
+	return canonicalSegments("", s.chain) + s.call.String()
+}
+
+// missingLocation returns the location reached from base by a chain of
+// names and indices, or base when the chain has a negative index
+func missingLocation(base string, chain []segmentV3) string {
+	location := base
+	for _, seg := range chain {
 		switch s := seg.(type) {
-		case *wildcardSegment:
-			newSegs[i] = &wildcardSegmentV3{}
-		case *nameSegment:
-			newSegs[i] = &nameSegmentV3{name: s.name}
-		case *indexSegment:
-			newSegs[i] = &indexSegmentV3{index: s.index}
-		case *sliceSegment:
-			newSegs[i] = &sliceSegmentV3{start: s.start, end: s.end, step: s.step, hasStart: s.hasStart, hasEnd: s.hasEnd}
-		case *multiIndexSegment:
-			newSegs[i] = &multiIndexSegmentV3{indices: s.indices}
-		case *multiNameSegment:
-			newSegs[i] = &multiNameSegmentV3{names: s.names}
-		case *recursiveSegment:
-			newSegs[i] = &recursiveSegmentV3{}
-		case *filterSegment:
-			newSegs[i] = &filterSegmentV3{expr: s.expr}
-		case *functionSegment:
-			newSegs[i] = &functionSegmentV3{name: s.name, args: s.args}
-		case *unionSegment:
-			unionV3 := &unionSegmentV3{selectors: make([]segmentV3, len(s.selectors))}
-			for j, sel := range s.selectors {
-				wrapped := wrapSegments([]segment{sel})
-				unionV3.selectors[j] = wrapped[0]
+		case *nameSegmentV3:
+			location += "['" + escapeNormalizedPathKey(s.name) + "']"
+		case *indexSegmentV3:
+			if s.index < 0 {
+				return base
 			}
-			newSegs[i] = unionV3
-		default:
-			// Fallback: wrap in adapter
-			newSegs[i] = &oldSegmentAdapter{seg: s}
+			location += "[" + strconv.Itoa(s.index) + "]"
 		}
 	}
-	return newSegs
+	return location
 }
 
-// oldSegmentAdapter wraps an old segment to satisfy segmentV3
-type oldSegmentAdapter struct {
-	seg segment
+// commonAncestor returns the Normalized Path of the closest node that is
+// an ancestor of, or equal to, every node in nodes
+func commonAncestor(nodes NodeList) string {
+	common, err := locationSteps(nodes[0].Location)
+	if err != nil {
+		return "$"
+	}
+	for _, n := range nodes[1:] {
+		steps, err := locationSteps(n.Location)
+		if err != nil {
+			return "$"
+		}
+		i := 0
+		for i < len(common) && i < len(steps) && common[i].String() == steps[i].String() {
+			i++
+		}
+		common = common[:i]
+	}
+	return canonicalSegments("$", common)
 }
 
-func (a *oldSegmentAdapter) evaluate(node Node) (NodeList, error) {
-	results, err := a.seg.evaluate(node.Value)
-	if err != nil {
-		return nil, err
+// parentSegmentV3 implements the non-standard parent segment (^), which
+// selects the node containing each input node. A parent shared by several
+// input nodes is selected once. The root has no parent.
+type parentSegmentV3 struct{}
+
+func (s *parentSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
+	return s.evaluateAll(ctx, NodeList{node})
+}
+
+func (s *parentSegmentV3) evaluateAll(ctx *evalContext, nodes NodeList) (NodeList, error) {
+	result := NodeList{}
+	seen := make(map[string]bool)
+	for _, n := range nodes {
+		steps, err := locationSteps(n.Location)
+		if err != nil || len(steps) == 0 {
+			continue
+		}
+		parents, err := ctx.evaluate(steps[:len(steps)-1], n.Root)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range parents {
+			if !seen[p.Location] {
+				seen[p.Location] = true
+				result = append(result, p)
+			}
+		}
 	}
-	nl := make(NodeList, len(results))
-	for i, r := range results {
-		nl[i] = Node{Location: node.Location, Value: r, Root: node.Root}
+	return result, nil
+}
+
+func (s *parentSegmentV3) String() string { return "^" }
+
+// queryUnionSegmentV3 implements the non-standard union of whole queries
+// ($.a | $.b). Each query is evaluated from the input node, the root, and
+// the nodelists are concatenated in order.
+type queryUnionSegmentV3 struct {
+	queries [][]segmentV3
+}
+
+func (s *queryUnionSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
+	result := NodeList{}
+	for _, q := range s.queries {
+		nodes, err := ctx.evaluateFrom(q, node)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, nodes...)
 	}
-	return nl, nil
+	return result, nil
 }
 
-func (a *oldSegmentAdapter) String() string {
-	return a.seg.String()
+func (s *queryUnionSegmentV3) String() string {
+	parts := make([]string, len(s.queries))
+	for i, q := range s.queries {
+		parts[i] = canonicalSegments("$", q)
+	}
+	return strings.Join(parts, " | ")
 }
 
 // unionSegmentV3 implements mixed selector types for the v3 interface
@@ -610,10 +843,10 @@ type unionSegmentV3 struct {
 	selectors []segmentV3
 }
 
-func (s *unionSegmentV3) evaluate(node Node) (NodeList, error) {
+func (s *unionSegmentV3) evaluate(ctx *evalContext, node Node) (NodeList, error) {
 	var result NodeList
 	for _, sel := range s.selectors {
-		items, err := sel.evaluate(node)
+		items, err := sel.evaluate(ctx, node)
 		if err != nil {
 			return nil, err
 		}
@@ -625,7 +858,7 @@ func (s *unionSegmentV3) evaluate(node Node) (NodeList, error) {
 func (s *unionSegmentV3) String() string {
 	parts := make([]string, len(s.selectors))
 	for i, sel := range s.selectors {
-		parts[i] = sel.String()
+		parts[i] = canonicalSelector(sel)
 	}
-	return fmt.Sprintf("[%s]", strings.Join(parts, ","))
+	return "[" + strings.Join(parts, ",") + "]"
 }
@@ -0,0 +1,101 @@
+package jsonpath
+
+import "fmt"
+
+// Set replaces the value of every node selected by path with value and
+// returns the document together with the number of values replaced. A
+// document given as a JSON string is decoded first; any other document is
+// modified in place, and is returned unchanged unless path selects the
+// root, in which case value becomes the document. A node inside another
+// selected node is replaced along with it and not counted separately.
+// Paths with function segments are rejected, since their results are not
+// values found in the document, and so are substrings selected with
+// WithStringSlices. Nothing is modified when Set fails.
+func Set(data interface{}, path string, value interface{}, opts ...Option) (interface{}, int, error) {
+	c, err := Compile(path, opts...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid path: %v", err)
+	}
+	return c.Set(data, value)
+}
+
+// Set evaluates the compiled expression like Execute and replaces the
+// value of each selected node. See the package-level Set.
+func (c *Compiled) Set(data interface{}, value interface{}, opts ...Option) (interface{}, int, error) {
+	if hasFunctionSegment(c.segments) {
+		return nil, 0, NewError(ErrInvalidPath, "function segments do not select values in the document", c.path)
+	}
+	data, err := decodeDocument(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	nodes, err := c.execute(data, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	// Resolve every location before writing, so that a node that cannot
+	// be replaced leaves the document untouched
+	refs := make([]*Ref, 0, len(nodes))
+	selected := make(map[string]bool, len(nodes))
+	replaceRoot := false
+	for _, n := range nodes {
+		if n.Location == "$" && !n.computed {
+			replaceRoot = true
+			continue
+		}
+		ref, err := n.Ref()
+		if err != nil {
+			return nil, 0, err
+		}
+		key := GenerateNormalizedPath(ref.steps)
+		if selected[key] {
+			continue
+		}
+		selected[key] = true
+		refs = append(refs, ref)
+	}
+	if replaceRoot {
+		return value, 1, nil
+	}
+	changed := 0
+	for _, ref := range refs {
+		// Replacing an ancestor replaces the node along with it
+		if hasSelectedAncestor(ref.steps, selected) {
+			continue
+		}
+		if err := ref.Set(value); err != nil {
+			return nil, 0, err
+		}
+		changed++
+	}
+	return data, changed, nil
+}
+
+// hasSelectedAncestor reports whether a location above the one reached by
+// steps is among the selected locations
+func hasSelectedAncestor(steps []interface{}, selected map[string]bool) bool {
+	for i := 1; i < len(steps); i++ {
+		if selected[GenerateNormalizedPath(steps[:i])] {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFunctionSegment reports whether segments, or the queries of a union
+// among them, apply a function
+func hasFunctionSegment(segments []segmentV3) bool {
+	for _, seg := range segments {
+		switch s := seg.(type) {
+		case *callSegmentV3, *aggregateSegmentV3, *fallbackSegmentV3, *functionSegmentV3:
+			return true
+		case *queryUnionSegmentV3:
+			for _, q := range s.queries {
+				if hasFunctionSegment(q) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package jsonpath
+
+import (
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	doc := `{"store":{"book":[{"title":"A","price":8},{"title":"B","price":12}],"bicycle":{"price":20}}}`
+
+	tests := []struct {
+		path    string
+		value   interface{}
+		want    string
+		changed int
+	}{
+		{"$.store.book[0].price", 9.5, `{"store":{"bicycle":{"price":20},"book":[{"price":9.5,"title":"A"},{"price":12,"title":"B"}]}}`, 1},
+		{"$..price", 0, `{"store":{"bicycle":{"price":0},"book":[{"price":0,"title":"A"},{"price":0,"title":"B"}]}}`, 3},
+		{"$.store.book[?@.price > 10].title", "sale", `{"store":{"bicycle":{"price":20},"book":[{"price":8,"title":"A"},{"price":12,"title":"sale"}]}}`, 1},
+		{"$.store.book[0,0,-2].title", nil, `{"store":{"bicycle":{"price":20},"book":[{"price":8,"title":null},{"price":12,"title":"B"}]}}`, 1},
+		{"$.store.bicycle.color", "red", `{"store":{"bicycle":{"price":20},"book":[{"price":8,"title":"A"},{"price":12,"title":"B"}]}}`, 0},
+		{"$.store.book[*].price^", map[string]interface{}{}, `{"store":{"bicycle":{"price":20},"book":[{},{}]}}`, 2},
+		{"$", []interface{}{1}, `[1]`, 1},
+		{"$..*", 0, `{"store":0}`, 1},
+		{"$.store.bicycle | $..price", 1, `{"store":{"bicycle":1,"book":[{"price":1,"title":"A"},{"price":1,"title":"B"}]}}`, 3},
+		{"$..price | $", "x", `"x"`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, changed, err := Set(doc, tt.path, tt.value)
+			if err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			if changed != tt.changed {
+				t.Errorf("Set() changed %d values, want %d", changed, tt.changed)
+			}
+			if s := encodeTestJSON(t, got); s != tt.want {
+				t.Errorf("Set() = %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetInPlace(t *testing.T) {
+	data := decodeTestJSON(t, `{"users":[{"name":"ann"},{"name":"bob"}]}`)
+
+	_, changed, err := MustCompile("$.users[*].active").Set(data, true)
+	if err != nil || changed != 0 {
+		t.Fatalf("Set() = %d, %v, want 0 changes", changed, err)
+	}
+	got, changed, err := MustCompile("$.users[*].name").Set(data, "x")
+	if err != nil || changed != 2 {
+		t.Fatalf("Set() = %d, %v, want 2 changes", changed, err)
+	}
+	want := `{"users":[{"name":"x"},{"name":"x"}]}`
+	if s := encodeTestJSON(t, data); s != want {
+		t.Errorf("document after Set() = %s, want %s", s, want)
+	}
+	if s := encodeTestJSON(t, got); s != want {
+		t.Errorf("Set() returned %s, want %s", s, want)
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	doc := `{"a":[1,2],"b":{"c":1}}`
+	for _, path := range []string{
+		"$.a.length()",
+		"$.a[*].sum()",
+		"$.b.d.default(1)",
+		"$.a | $.b.keys()",
+		"$[",
+	} {
+		if _, _, err := Set(doc, path, 0); err == nil {
+			t.Errorf("Set(%q) succeeded, want an error", path)
+		}
+	}
+	if _, _, err := Set(`{"a":`, "$.a", 0); err == nil {
+		t.Error("Set() of invalid JSON succeeded, want an error")
+	}
+
+	// Substrings are not values in the document, and a failed Set leaves
+	// the document as it was
+	data := decodeTestJSON(t, `{"a":{"b":1},"s":"abc"}`)
+	for _, path := range []string{"$.s[0:2]", "$.a.b | $.s[1:]"} {
+		if _, _, err := Set(data, path, 0, WithStringSlices()); err == nil {
+			t.Errorf("Set(%q) succeeded, want an error", path)
+		}
+	}
+	if s, want := encodeTestJSON(t, data), `{"a":{"b":1},"s":"abc"}`; s != want {
+		t.Errorf("document after failed Set() = %s, want %s", s, want)
+	}
+}
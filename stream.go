@@ -0,0 +1,361 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/davidhoo/jsonpath/ast"
+)
+
+// ExecuteDecoder evaluates the compiled expression against the next JSON
+// value read from dec, without building the whole document in memory.
+// Member names, non-negative indices, wildcards and forward slices are
+// matched directly on the token stream and unselected values are skipped;
+// only the selected subtrees are decoded. The first other segment, such as
+// a filter or a descendant segment, is evaluated on its decoded subtree,
+// and aggregates such as sum() are applied to all the nodes selected
+// before them.
+//
+// Expressions whose filters refer to the root ($), and expressions with a
+// parent segment (^), need the whole document and are evaluated after
+// decoding it. Nodes produced from the stream have
+// no Root, so Ref and RelativePointer cannot move above them. Numbers are
+// decoded according to the settings of dec, e.g. UseNumber. When dec has no
+// more values, ExecuteDecoder returns io.EOF, so a stream of concatenated
+// or newline-delimited documents can be processed in a loop.
+func (c *Compiled) ExecuteDecoder(dec *json.Decoder, opts ...Option) (NodeList, error) {
+	o := c.resolveOptions(opts)
+	if err := c.checkParams(o); err != nil {
+		return nil, err
+	}
+	ctx := &evalContext{opts: o}
+	if !dec.More() {
+		if _, err := dec.Token(); err != nil && err != io.EOF {
+			return nil, streamError(err)
+		}
+		return nil, io.EOF
+	}
+
+	if !c.streamable() {
+		var data interface{}
+		if err := dec.Decode(&data); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+		return ctx.evaluate(c.segments, data)
+	}
+
+	// Segments from the first one consuming a whole nodelist, such as an
+	// aggregate, are applied to the nodes streamed by those before it
+	streamed, rest := c.segments, []segmentV3(nil)
+	for i, seg := range c.segments {
+		if _, ok := seg.(nodeListSegment); ok {
+			streamed, rest = c.segments[:i], c.segments[i:]
+			break
+		}
+	}
+	s := &streamEvaluator{ctx: ctx, dec: dec, segments: streamed}
+	result, err := s.value(0, "$")
+	if err == nil && rest != nil {
+		result, err = ctx.evaluateList(rest, result)
+	}
+	if err != nil {
+		if ctx.err != nil {
+			return nil, ctx.err
+		}
+		return nil, err
+	}
+	if err := ctx.checkResults(len(result)); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// streamable reports whether the expression can be evaluated without the
+// document root, i.e. it has no filter query starting with $ and no parent
+// segment, which climbs above the streamed subtrees
+func (c *Compiled) streamable() bool {
+	q, err := c.AST()
+	if err != nil {
+		return false
+	}
+	usesRoot := false
+	ast.Inspect(q, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.Query:
+			usesRoot = x != q && !x.Relative
+		case *ast.ParentSelector:
+			usesRoot = true
+		}
+		return !usesRoot
+	})
+	return !usesRoot
+}
+
+// streamEvaluator evaluates segments against a JSON token stream
+type streamEvaluator struct {
+	ctx      *evalContext
+	dec      *json.Decoder
+	segments []segmentV3
+}
+
+// value evaluates segments[i:] against the next value in the stream, whose
+// location is loc
+func (s *streamEvaluator) value(i int, loc string) (NodeList, error) {
+	if err := s.ctx.step(); err != nil {
+		return nil, err
+	}
+	if i == len(s.segments) {
+		v, err := s.decode()
+		if err != nil {
+			return nil, err
+		}
+		return NodeList{{Location: loc, Value: v}}, nil
+	}
+
+	seg := s.segments[i]
+	if !isStreamSegment(seg) {
+		v, err := s.decode()
+		if err != nil {
+			return nil, err
+		}
+		return s.ctx.evaluateFrom(s.segments[i:], Node{Location: loc, Value: v})
+	}
+
+	tok, err := s.dec.Token()
+	if err != nil {
+		return nil, streamError(err)
+	}
+	switch tok {
+	case json.Delim('{'):
+		return s.object(i, loc)
+	case json.Delim('['):
+		return s.array(i, loc)
+	default:
+		if str, ok := tok.(string); ok && s.ctx.opts.stringSlices {
+			if _, ok := seg.(*sliceSegmentV3); ok {
+				return s.ctx.evaluateFrom(s.segments[i:], Node{Location: loc, Value: str})
+			}
+		}
+		// Scalars have no children
+		return NodeList{}, nil
+	}
+}
+
+// object evaluates segment i against the members of an object whose
+// opening brace has been consumed
+func (s *streamEvaluator) object(i int, loc string) (NodeList, error) {
+	seg := s.segments[i]
+	matched := make(map[string]NodeList)
+	for s.dec.More() {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return nil, streamError(err)
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid JSON: unexpected token %v", tok)
+		}
+		if !s.selectsKey(seg, key) {
+			if err := s.skip(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		nodes, err := s.value(i+1, loc+"['"+escapeNormalizedPathKey(key)+"']")
+		if err != nil {
+			return nil, err
+		}
+		matched[key] = nodes
+	}
+	if _, err := s.dec.Token(); err != nil {
+		return nil, streamError(err)
+	}
+
+	var result NodeList
+	switch sel := seg.(type) {
+	case *wildcardSegmentV3:
+		keys := make([]string, 0, len(matched))
+		for key := range matched {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			result = append(result, matched[key]...)
+		}
+	case *nameSegmentV3:
+		if key, ok := s.pickKey(matched, sel.name); ok {
+			result = append(result, matched[key]...)
+		}
+	case *multiNameSegmentV3:
+		for _, name := range sel.names {
+			if key, ok := s.pickKey(matched, name); ok {
+				result = append(result, matched[key]...)
+			}
+		}
+	}
+	return result, s.ctx.checkResults(len(result))
+}
+
+// array evaluates segment i against the elements of an array whose opening
+// bracket has been consumed
+func (s *streamEvaluator) array(i int, loc string) (NodeList, error) {
+	seg := s.segments[i]
+	matched := make(map[int]NodeList)
+	var result NodeList
+	for idx := 0; s.dec.More(); idx++ {
+		if !selectsIndex(seg, idx) {
+			if err := s.skip(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		nodes, err := s.value(i+1, loc+"["+strconv.Itoa(idx)+"]")
+		if err != nil {
+			return nil, err
+		}
+		if _, isMulti := seg.(*multiIndexSegmentV3); isMulti {
+			matched[idx] = nodes
+		} else {
+			// Other selectors select in ascending index order
+			result = append(result, nodes...)
+		}
+	}
+	if _, err := s.dec.Token(); err != nil {
+		return nil, streamError(err)
+	}
+
+	if multi, ok := seg.(*multiIndexSegmentV3); ok {
+		for _, idx := range multi.indices {
+			result = append(result, matched[idx]...)
+		}
+	}
+	return result, s.ctx.checkResults(len(result))
+}
+
+// selectsKey reports whether an object member may be selected by seg
+func (s *streamEvaluator) selectsKey(seg segmentV3, key string) bool {
+	switch sel := seg.(type) {
+	case *wildcardSegmentV3:
+		return true
+	case *nameSegmentV3:
+		return s.nameMatches(sel.name, key)
+	case *multiNameSegmentV3:
+		for _, name := range sel.names {
+			if s.nameMatches(name, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *streamEvaluator) nameMatches(name, key string) bool {
+	return name == key || (s.ctx.opts.caseInsensitiveNames && strings.EqualFold(name, key))
+}
+
+// pickKey chooses among the matched keys for name the same member as
+// lookupMember would: the exact key, else the first case-folded one
+func (s *streamEvaluator) pickKey(matched map[string]NodeList, name string) (string, bool) {
+	if _, ok := matched[name]; ok {
+		return name, true
+	}
+	if !s.ctx.opts.caseInsensitiveNames {
+		return "", false
+	}
+	found := false
+	var best string
+	for key := range matched {
+		if strings.EqualFold(key, name) && (!found || key < best) {
+			best, found = key, true
+		}
+	}
+	return best, found
+}
+
+// selectsIndex reports whether an array element may be selected by seg
+func selectsIndex(seg segmentV3, idx int) bool {
+	switch sel := seg.(type) {
+	case *wildcardSegmentV3:
+		return true
+	case *indexSegmentV3:
+		return sel.index == idx
+	case *multiIndexSegmentV3:
+		for _, i := range sel.indices {
+			if i == idx {
+				return true
+			}
+		}
+	case *sliceSegmentV3:
+		start := 0
+		if sel.hasStart {
+			start = sel.start
+		}
+		if idx < start || (sel.hasEnd && idx >= sel.end) {
+			return false
+		}
+		return (idx-start)%sel.step == 0
+	}
+	return false
+}
+
+// isStreamSegment reports whether seg can be matched on the token stream,
+// which requires knowing neither the array length nor sibling values
+func isStreamSegment(seg segmentV3) bool {
+	switch sel := seg.(type) {
+	case *wildcardSegmentV3, *nameSegmentV3, *multiNameSegmentV3:
+		return true
+	case *indexSegmentV3:
+		return sel.index >= 0
+	case *multiIndexSegmentV3:
+		for _, i := range sel.indices {
+			if i < 0 {
+				return false
+			}
+		}
+		return true
+	case *sliceSegmentV3:
+		return sel.step > 0 && (!sel.hasStart || sel.start >= 0) && (!sel.hasEnd || sel.end >= 0)
+	}
+	return false
+}
+
+// decode reads the next value from the stream
+func (s *streamEvaluator) decode() (interface{}, error) {
+	var v interface{}
+	if err := s.dec.Decode(&v); err != nil {
+		return nil, streamError(err)
+	}
+	return v, nil
+}
+
+// skip discards the next value in the stream
+func (s *streamEvaluator) skip() error {
+	depth := 0
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return streamError(err)
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// streamError reports a malformed or truncated stream
+func streamError(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("invalid JSON: %v", err)
+}
@@ -0,0 +1,161 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const streamTestDoc = `{
+	"metadata": {"id": "abc", "Version": 2},
+	"store": {
+		"book": [
+			{"title": "A", "price": 8.95, "tags": ["x", "y"]},
+			{"title": "B", "price": 12.99, "tags": []},
+			{"title": "C", "price": 8.99, "tags": ["z"]},
+			{"title": "D", "price": 22.99}
+		],
+		"bicycle": {"color": "red", "price": 19.95}
+	},
+	"zeta": 1, "alpha": [1, [2, 3], {"k": 4}],
+	"limit": 10,
+	"pairs": [{"key": "a", "value": 1}, {"key": "b", "value": 2}]
+}`
+
+func TestExecuteDecoder(t *testing.T) {
+	paths := []string{
+		"$",
+		"$.metadata.id",
+		"$.metadata",
+		"$.store.book[1].title",
+		"$.store.book[*].title",
+		"$.store.*",
+		"$.*",
+		"$.store.book[0,2,0].title",
+		"$['zeta','alpha','zeta']",
+		"$.store.book[1:3].title",
+		"$.store.book[::2].title",
+		"$.store.book[1:].price",
+		"$.store.book[-1].title",
+		"$.store.book[:-1].title",
+		"$.store.book[::-1].title",
+		"$.store.book[?@.price < 10].title",
+		"$.store.book[?@.price < $.limit].title",
+		"$.store..price",
+		"$..tags[0]",
+		"$.alpha[1][0]",
+		"$.alpha[*].k",
+		"$.missing.deeper",
+		"$.zeta.x",
+		"$.store.book[10]",
+		"$.store.book.length()",
+		"$.store.book[*].price.min()",
+		"$.store.book[*].price.max()",
+		"$.store.book[*].price.avg()",
+		"$.store.book[*].price.sum()",
+		"$.store.book[1:3].price.sum()",
+		"$.store.book[*].price.product()",
+		"$.store.book[*].price.median()",
+		"$.store.book[*].price.variance()",
+		"$.store.book[*].price.stddev()",
+		"$.store.book[*].price.mode()",
+		"$.store.book[*].price.percentile(50)",
+		"$.store.book[*].price.quantile(0.5)",
+		"$.store..price.occurrences(8.99)",
+		"$.store.book[*].tags.sort()",
+		"$.store.book.sort_by('price')",
+		"$.store.book[*].tags.unique()",
+		"$.store.book.map('tags').distinct()",
+		"$.store.book[*].tags.first()",
+		"$.store.book[*].tags.last()",
+		"$.store.book.map('title')",
+		"$.store.book[*].tags.join(',')",
+		"$.pairs.from_entries()",
+		"$.store.book.map('price').sort().last()",
+		"$.store.book[?@.price < 10].price.sum()",
+		"$.store.book[*].price.sum().abs()",
+		"$.store.book[*].title^",
+		"$.store.book[0].price^",
+		"$.store.book[*].tags[0]^^",
+	}
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			c := MustCompile(path)
+			want, err := c.Execute(streamTestDoc)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			got, err := c.ExecuteDecoder(json.NewDecoder(strings.NewReader(streamTestDoc)))
+			if err != nil {
+				t.Fatalf("ExecuteDecoder() error = %v", err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("ExecuteDecoder() returned %d nodes, want %d: %v", len(got), len(want), got)
+			}
+			for i := range want {
+				if got[i].Location != want[i].Location || !reflect.DeepEqual(got[i].Value, want[i].Value) {
+					t.Errorf("node %d = %s %v, want %s %v", i, got[i].Location, got[i].Value, want[i].Location, want[i].Value)
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteDecoderOptions(t *testing.T) {
+	c := MustCompile("$.Metadata.version", WithCaseInsensitiveNames())
+	got, err := c.ExecuteDecoder(json.NewDecoder(strings.NewReader(streamTestDoc)))
+	if err != nil {
+		t.Fatalf("ExecuteDecoder() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Location != "$['metadata']['Version']" {
+		t.Errorf("ExecuteDecoder() = %v, want $['metadata']['Version']", got)
+	}
+
+	c = MustCompile("$.store.book[*].title", WithMaxResults(2))
+	if _, err := c.ExecuteDecoder(json.NewDecoder(strings.NewReader(streamTestDoc))); err == nil {
+		t.Error("ExecuteDecoder() expected result limit error")
+	}
+}
+
+func TestExecuteDecoderStream(t *testing.T) {
+	input := `{"id": 1, "skip": {"a": [1, 2, {"b": 3}]}}
+{"id": 2}
+{"other": true}
+`
+	dec := json.NewDecoder(strings.NewReader(input))
+	c := MustCompile("$.id")
+
+	var ids []interface{}
+	for {
+		result, err := c.ExecuteDecoder(dec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ExecuteDecoder() error = %v", err)
+		}
+		for _, n := range result {
+			ids = append(ids, n.Value)
+		}
+	}
+	if want := []interface{}{float64(1), float64(2)}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+}
+
+func TestExecuteDecoderInvalid(t *testing.T) {
+	inputs := []string{
+		`{"id": `,
+		`{"a": [1, 2}`,
+		`{"a" 1}`,
+	}
+	c := MustCompile("$.b")
+	for _, input := range inputs {
+		if _, err := c.ExecuteDecoder(json.NewDecoder(strings.NewReader(input))); err == nil || err == io.EOF {
+			t.Errorf("ExecuteDecoder(%q) error = %v, want invalid JSON", input, err)
+		}
+	}
+}
@@ -299,8 +299,8 @@ func TestCompareStringsFunction(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := compareStrings(tt.a, tt.operator, tt.b); got != tt.want {
-				t.Errorf("compareStrings() = %v, want %v", got, tt.want)
+			if got := standardCompareStrings(tt.a, tt.operator, tt.b); got != tt.want {
+				t.Errorf("standardCompareStrings() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -0,0 +1,1080 @@
+package jsonpath
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/davidhoo/jsonpath/ast"
+)
+
+// maxSafeInteger bounds indices and slice parameters to the I-JSON range
+// required by RFC 9535
+const maxSafeInteger = 1<<53 - 1
+
+var (
+	integerPattern = regexp.MustCompile(`^(0|-?[1-9][0-9]*)$`)
+	numberPattern  = regexp.MustCompile(`^(-?0|-?[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+)
+
+// parser is a recursive-descent parser over the tokens of an expression.
+// It produces an ast tree whose nodes record their source offsets.
+type parser struct {
+	src    string
+	tokens []token
+	next   int // index of the current token
+	filter int // nesting depth of filter selectors
+	opts   parseOptions
+}
+
+// parseOptions bounds the size of expressions accepted by the parser,
+// enables syntax extensions and supplies functions. Zero limits mean no
+// limit.
+type parseOptions struct {
+	maxLength      int       // bytes in the expression
+	maxNesting     int       // depth of nested brackets, parentheses and braces
+	maxFilterDepth int       // depth of nested filter selectors
+	arithmetic     bool      // arithmetic operators in filter operands
+	functions      *Registry // functions given with WithFunctions
+}
+
+// function returns the function called name, looking in the registry
+// given with WithFunctions before the global functions
+func (o parseOptions) function(name string) (Function, error) {
+	if o.functions != nil {
+		if fn, ok := o.functions.Lookup(name); ok {
+			return fn, nil
+		}
+	}
+	return GetFunction(name)
+}
+
+// parsePath parses a JSONPath expression. The result is an *ast.Query,
+// an *ast.Union for the non-standard union of queries $.a | $.b or, for
+// the non-standard top-level call form such as length($.a), an
+// *ast.FunctionCall. Expressions exceeding limits are rejected before
+// they are parsed.
+func parsePath(src string, opts parseOptions) (ast.Node, error) {
+	// An empty expression is the root query
+	if src == "" {
+		return &ast.Query{}, nil
+	}
+	if opts.maxLength > 0 && len(src) > opts.maxLength {
+		return nil, syntaxError(ErrSyntax, fmt.Sprintf("expression length limit exceeded: %d", opts.maxLength), src, opts.maxLength)
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, tokens: tokens, opts: opts}
+	if err := p.checkNesting(); err != nil {
+		return nil, err
+	}
+
+	var node ast.Node
+	first := p.peek()
+	switch {
+	case first.pos != 0:
+		return nil, p.errorAt(0, "path must start with $")
+	case first.kind == tokenName && p.nextAdjacent(tokenLParen):
+		node, err = p.parseTopLevelCall()
+	case first.kind == tokenRoot:
+		node, err = p.parseUnion()
+	default:
+		return nil, p.errorAt(0, "path must start with $")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.unexpected(tok)
+	}
+	if last := p.tokens[len(p.tokens)-2]; last.end != len(src) {
+		return nil, p.errorAt(last.end, "trailing whitespace is not allowed")
+	}
+	return node, nil
+}
+
+// parseUnion parses a query or a union of queries separated by |
+func (p *parser) parseUnion() (ast.Node, error) {
+	q, err := p.parseQuery()
+	if err != nil || p.peek().kind != tokenPipe {
+		return q, err
+	}
+	union := &ast.Union{Queries: []*ast.Query{q}, Offset: q.Offset}
+	for {
+		if _, ok := p.accept(tokenPipe); !ok {
+			return union, nil
+		}
+		if tok := p.peek(); tok.kind != tokenRoot {
+			return nil, p.errorAt(tok.pos, fmt.Sprintf("expected query starting with $ after \"|\", found %s", tok))
+		}
+		if q, err = p.parseQuery(); err != nil {
+			return nil, err
+		}
+		union.Queries = append(union.Queries, q)
+	}
+}
+
+// checkNesting rejects expressions whose brackets, parentheses and braces
+// nest deeper than the limit, so that deeply nested input is refused
+// before the parser recurses into it
+func (p *parser) checkNesting() error {
+	if p.opts.maxNesting <= 0 {
+		return nil
+	}
+	depth := 0
+	for _, tok := range p.tokens {
+		switch tok.kind {
+		case tokenLBracket, tokenLParen, tokenLBrace:
+			if depth++; depth > p.opts.maxNesting {
+				return syntaxError(ErrSyntax, fmt.Sprintf("nesting limit exceeded: %d", p.opts.maxNesting), p.src, tok.pos)
+			}
+		case tokenRBracket, tokenRParen, tokenRBrace:
+			depth--
+		}
+	}
+	return nil
+}
+
+// peek returns the current token without consuming it
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+// peekAt returns the token n positions after the current one
+func (p *parser) peekAt(n int) token {
+	if p.next+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.next+n]
+}
+
+// advance consumes and returns the current token
+func (p *parser) advance() token {
+	tok := p.tokens[p.next]
+	if tok.kind != tokenEOF {
+		p.next++
+	}
+	return tok
+}
+
+// accept consumes the current token if it has the given kind
+func (p *parser) accept(kind tokenKind) (token, bool) {
+	if tok := p.peek(); tok.kind == kind {
+		return p.advance(), true
+	}
+	return token{}, false
+}
+
+// expect consumes a token of the given kind or reports what was found
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.peek()
+	if tok.kind != kind {
+		return tok, p.errorAt(tok.pos, fmt.Sprintf("expected %s, found %s", what, tok))
+	}
+	return p.advance(), nil
+}
+
+// nextAdjacent reports whether the current token is followed by a token
+// of the given kind without intervening whitespace
+func (p *parser) nextAdjacent(kind tokenKind) bool {
+	tok, next := p.peek(), p.peekAt(1)
+	return next.kind == kind && next.pos == tok.end
+}
+
+// errorAt reports a syntax error at a byte offset. Errors inside filter
+// selectors are reported as invalid filters.
+func (p *parser) errorAt(offset int, msg string) error {
+	typ := ErrSyntax
+	if p.filter > 0 {
+		typ = ErrInvalidFilter
+	}
+	return syntaxError(typ, msg, p.src, offset)
+}
+
+// unexpected reports an unexpected token
+func (p *parser) unexpected(tok token) error {
+	return p.errorAt(tok.pos, fmt.Sprintf("unexpected %s", tok))
+}
+
+// parseQuery parses a query starting at $ or @
+func (p *parser) parseQuery() (*ast.Query, error) {
+	start := p.advance()
+	q := &ast.Query{Relative: start.kind == tokenCurrent, Offset: start.pos}
+	for {
+		seg, err := p.parseSegment()
+		if err != nil {
+			return nil, err
+		}
+		if seg == nil {
+			return q, nil
+		}
+		q.Segments = append(q.Segments, seg)
+	}
+}
+
+// parseSegment parses the next child, descendant or parent segment, returning nil
+// when the query has no more segments
+func (p *parser) parseSegment() (*ast.Segment, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokenDot:
+		p.advance()
+		sel, err := p.parseShorthand(tok, "dot")
+		if err != nil {
+			return nil, err
+		}
+		return &ast.Segment{Selectors: []ast.Selector{sel}, Offset: tok.pos}, nil
+	case tokenDotDot:
+		p.advance()
+		seg := &ast.Segment{Descendant: true, Offset: tok.pos}
+		next := p.peek()
+		if next.kind == tokenLBracket && next.pos == tok.end {
+			selectors, err := p.parseBracketed()
+			if err != nil {
+				return nil, err
+			}
+			seg.Selectors = selectors
+			return seg, nil
+		}
+		if next.kind == tokenEOF {
+			return nil, p.errorAt(tok.pos, "bare recursive descent is not allowed")
+		}
+		sel, err := p.parseShorthand(tok, "recursive descent")
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := sel.(*ast.FunctionSelector); ok {
+			return nil, p.errorAt(sel.Pos(), "function segments cannot follow recursive descent")
+		}
+		seg.Selectors = []ast.Selector{sel}
+		return seg, nil
+	case tokenLBracket:
+		selectors, err := p.parseBracketed()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.Segment{Selectors: selectors, Offset: tok.pos}, nil
+	case tokenCaret:
+		if p.filter > 0 {
+			return nil, p.errorAt(tok.pos, "parent segments are not allowed in filters")
+		}
+		p.advance()
+		sel := &ast.ParentSelector{Offset: tok.pos}
+		return &ast.Segment{Selectors: []ast.Selector{sel}, Offset: tok.pos}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// parseShorthand parses the wildcard, member name or function call that
+// follows "." or ".." without intervening whitespace
+func (p *parser) parseShorthand(dot token, what string) (ast.Selector, error) {
+	tok := p.peek()
+	if tok.pos != dot.end && (tok.kind == tokenName || tok.kind == tokenStar) {
+		return nil, p.errorAt(dot.end, fmt.Sprintf("whitespace is not allowed between %s and member name", what))
+	}
+	switch tok.kind {
+	case tokenStar:
+		p.advance()
+		return &ast.WildcardSelector{Offset: tok.pos}, nil
+	case tokenName:
+		if p.filter == 0 && p.nextAdjacent(tokenLParen) {
+			return p.parseFunctionSegment()
+		}
+		p.advance()
+		return &ast.NameSelector{Name: tok.text, Offset: tok.pos}, nil
+	default:
+		return nil, p.errorAt(tok.pos, fmt.Sprintf("invalid member name: %s", tok))
+	}
+}
+
+// parseBracketed parses a bracketed selection: [selector, ...]
+func (p *parser) parseBracketed() ([]ast.Selector, error) {
+	open := p.advance()
+	if tok := p.peek(); tok.kind == tokenRBracket {
+		return nil, p.errorAt(open.pos, "empty bracket segment")
+	}
+	var selectors []ast.Selector
+	for {
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+		if _, ok := p.accept(tokenComma); ok {
+			continue
+		}
+		tok := p.peek()
+		if tok.kind == tokenEOF {
+			return nil, p.errorAt(open.pos, "unclosed bracket")
+		}
+		if tok.kind != tokenRBracket {
+			return nil, p.errorAt(tok.pos, fmt.Sprintf("expected \",\" or \"]\", found %s", tok))
+		}
+		p.advance()
+		return selectors, nil
+	}
+}
+
+// parseSelector parses one selector inside brackets
+func (p *parser) parseSelector() (ast.Selector, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokenString:
+		p.advance()
+		return &ast.NameSelector{Name: tok.value, Offset: tok.pos}, nil
+	case tokenStar:
+		p.advance()
+		return &ast.WildcardSelector{Offset: tok.pos}, nil
+	case tokenQuestion:
+		p.advance()
+		p.filter++
+		if max := p.opts.maxFilterDepth; max > 0 && p.filter > max {
+			return nil, syntaxError(ErrSyntax, fmt.Sprintf("filter depth limit exceeded: %d", max), p.src, tok.pos)
+		}
+		expr, err := p.parseLogical()
+		p.filter--
+		if err != nil {
+			return nil, err
+		}
+		return &ast.FilterSelector{Expr: expr, Offset: tok.pos}, nil
+	case tokenNumber, tokenColon:
+		return p.parseIndexOrSlice()
+	case tokenName:
+		if p.atLast() {
+			return p.parseIndexOrSlice()
+		}
+		return nil, p.errorAt(tok.pos, fmt.Sprintf("invalid selector: %s", tok))
+	case tokenCurrent, tokenRoot:
+		return nil, p.errorAt(tok.pos, fmt.Sprintf("%s is only allowed inside filter expressions", tok.text))
+	case tokenEOF:
+		return nil, p.errorAt(tok.pos, "unclosed bracket")
+	default:
+		return nil, p.errorAt(tok.pos, fmt.Sprintf("invalid selector: %s", tok))
+	}
+}
+
+// parseIndexOrSlice parses an index selector or a slice selector
+// [start]:[end][:[step]]
+func (p *parser) parseIndexOrSlice() (ast.Selector, error) {
+	offset := p.peek().pos
+	start, err := p.parseOptionalInt("index")
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept(tokenColon); !ok {
+		return &ast.IndexSelector{Index: *start, Offset: offset}, nil
+	}
+	sel := &ast.SliceSelector{Start: start, Offset: offset}
+	if sel.End, err = p.parseOptionalInt("slice end"); err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept(tokenColon); ok {
+		if p.atLast() {
+			return nil, p.errorAt(p.peek().pos, "last() is not allowed as a slice step")
+		}
+		if sel.Step, err = p.parseOptionalInt("slice step"); err != nil {
+			return nil, err
+		}
+	}
+	return sel, nil
+}
+
+// parseOptionalInt parses an integer if the current token is a number or
+// last()
+func (p *parser) parseOptionalInt(what string) (*int, error) {
+	if p.atLast() {
+		return p.parseLast(what)
+	}
+	tok, ok := p.accept(tokenNumber)
+	if !ok {
+		return nil, nil
+	}
+	if !integerPattern.MatchString(tok.text) {
+		return nil, p.errorAt(tok.pos, fmt.Sprintf("invalid %s: %s", what, tok.text))
+	}
+	n, err := strconv.ParseInt(tok.text, 10, 64)
+	if err != nil || n > maxSafeInteger || n < -maxSafeInteger {
+		return nil, p.errorAt(tok.pos, fmt.Sprintf("%s out of range: %s", what, tok.text))
+	}
+	i := int(n)
+	return &i, nil
+}
+
+// atLast reports whether the parser is at a last() call
+func (p *parser) atLast() bool {
+	return p.peek().kind == tokenName && p.peek().text == "last" && p.peekAt(1).kind == tokenLParen
+}
+
+// parseLast parses last() or last()-N, the index of the last element or of
+// the element N before it. It is parsed as the equivalent negative index,
+// so last() is -1 and last()-1 is -2.
+func (p *parser) parseLast(what string) (*int, error) {
+	p.advance()
+	p.advance()
+	if _, err := p.expect(tokenRParen, "\")\" after last("); err != nil {
+		return nil, err
+	}
+	i := -1
+	tok := p.peek()
+	if tok.kind != tokenNumber || tok.text[0] != '-' {
+		return &i, nil
+	}
+	p.advance()
+	text := tok.text[1:]
+	if text == "" {
+		// last() - N with blanks around the minus sign
+		num, err := p.expect(tokenNumber, "number after \"-\"")
+		if err != nil {
+			return nil, err
+		}
+		text = num.text
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || !integerPattern.MatchString(text) || text[0] == '-' {
+		return nil, p.errorAt(tok.pos, fmt.Sprintf("invalid %s: last()-%s", what, text))
+	}
+	if n >= maxSafeInteger {
+		return nil, p.errorAt(tok.pos, fmt.Sprintf("%s out of range: last()-%s", what, text))
+	}
+	i -= int(n)
+	return &i, nil
+}
+
+// parseLogical parses a logical-or expression, the lowest precedence
+// level of a filter expression
+func (p *parser) parseLogical() (ast.Expr, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	operands := []ast.Expr{first}
+	for {
+		if _, ok := p.accept(tokenOr); !ok {
+			break
+		}
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, next)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &ast.OrExpr{Operands: operands, Offset: first.Pos()}, nil
+}
+
+// parseAnd parses a logical-and expression
+func (p *parser) parseAnd() (ast.Expr, error) {
+	first, err := p.parseBasic()
+	if err != nil {
+		return nil, err
+	}
+	operands := []ast.Expr{first}
+	for {
+		if _, ok := p.accept(tokenAnd); !ok {
+			break
+		}
+		next, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, next)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &ast.AndExpr{Operands: operands, Offset: first.Pos()}, nil
+}
+
+// parseBasic parses a negation, a parenthesized expression, a comparison
+// or a test expression. As an extension to RFC 9535, ! also applies to a
+// comparison, so !@.a == 1 means !(@.a == 1).
+func (p *parser) parseBasic() (ast.Expr, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokenNot:
+		p.advance()
+		expr, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.NotExpr{Expr: expr, Offset: tok.pos}, nil
+	case tokenLParen:
+		if p.opts.arithmetic {
+			// (@.a + 1) * 2 > 3 starts with a parenthesized operand
+			// rather than a parenthesized logical expression
+			if expr, ok := p.tryComparison(); ok {
+				return expr, nil
+			}
+		}
+		p.advance()
+		expr, err := p.parseLogical()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, "\")\""); err != nil {
+			return nil, err
+		}
+		if next := p.peek(); next.isComparison() {
+			return nil, p.errorAt(next.pos, "a parenthesized expression cannot be compared")
+		}
+		return expr, nil
+	}
+
+	left, err := p.parseComparable()
+	if err != nil {
+		return nil, err
+	}
+	if !p.atComparison() {
+		return left, p.checkTest(left)
+	}
+	return p.parseComparison(left)
+}
+
+// keywordOperators are the non-standard comparison operators written as
+// names
+var keywordOperators = map[string]bool{
+	"in": true, "nin": true, "contains": true, "subsetof": true, "anyof": true,
+}
+
+// atComparison reports whether the current token starts a comparison
+// operator, including keyword operators and not in
+func (p *parser) atComparison() bool {
+	tok := p.peek()
+	if tok.isComparison() || tok.kind == tokenOperator {
+		return true
+	}
+	if tok.kind != tokenName {
+		return false
+	}
+	if tok.text == "not" {
+		next := p.peekAt(1)
+		return next.kind == tokenName && next.text == "in"
+	}
+	if _, ok := lookupOperator(tok.text); ok {
+		return true
+	}
+	return keywordOperators[tok.text]
+}
+
+// parseComparison parses the operator and right operand of a comparison
+// whose left operand has been parsed. The right operand of a keyword or
+// custom operator may also be a JSON array literal; not in is recorded as
+// nin.
+func (p *parser) parseComparison(left ast.Expr) (ast.Expr, error) {
+	tok := p.advance()
+	op := tok.text
+	if op == "not" {
+		p.advance()
+		op = "nin"
+	}
+	var right ast.Expr
+	var err error
+	next := p.peek()
+	_, custom := lookupOperator(op)
+	switch {
+	case (keywordOperators[op] || custom) && next.kind == tokenLBracket:
+		var list interface{}
+		if list, err = p.parseJSONValue(); err != nil {
+			return nil, err
+		}
+		right = &ast.Literal{Value: list, Offset: next.pos}
+	case op == "=~" && next.kind == tokenRegex:
+		p.advance()
+		right = &ast.Literal{Value: next.value, Offset: next.pos}
+	default:
+		if right, err = p.parseComparable(); err != nil {
+			return nil, err
+		}
+	}
+	for _, operand := range []ast.Expr{left, right} {
+		if err := p.checkComparable(operand); err != nil {
+			return nil, err
+		}
+	}
+	if lit, ok := right.(*ast.Literal); ok && op == "=~" {
+		pattern, ok := lit.Value.(string)
+		if !ok {
+			return nil, p.errorAt(lit.Offset, "=~ requires a string or regex pattern")
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, p.errorAt(lit.Offset, fmt.Sprintf("invalid regular expression: %v", err))
+		}
+	}
+	return &ast.ComparisonExpr{Left: left, Op: op, Right: right, Offset: left.Pos()}, nil
+}
+
+// tryComparison parses a comparison whose left operand starts with a
+// parenthesis. It reports false, consuming nothing, when the input is not
+// such a comparison.
+func (p *parser) tryComparison() (ast.Expr, bool) {
+	next, tokens, filter := p.next, p.tokens, p.filter
+	left, err := p.parseComparable()
+	if err == nil && p.atComparison() {
+		if expr, err := p.parseComparison(left); err == nil {
+			return expr, true
+		}
+	}
+	p.next, p.tokens, p.filter = next, tokens, filter
+	return nil, false
+}
+
+// parseComparable parses an operand of a comparison or a function
+// argument. With the arithmetic extension it is an arithmetic expression
+// whose operands are parsed by parsePrimary.
+func (p *parser) parseComparable() (ast.Expr, error) {
+	if p.opts.arithmetic {
+		return p.parseArithmetic(0)
+	}
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if op, ok := p.arithmeticOperator(); ok {
+		return nil, p.errorAt(p.peek().pos, fmt.Sprintf("arithmetic operator %s requires WithArithmetic", op))
+	}
+	return expr, nil
+}
+
+// arithmeticPrecedence maps arithmetic operators to their precedence
+var arithmeticPrecedence = map[string]int{"+": 1, "-": 1, "*": 2, "/": 2, "%": 2}
+
+// parseArithmetic parses an arithmetic expression whose operators bind at
+// least as tightly as minPrec. Operators of equal precedence associate to
+// the left.
+func (p *parser) parseArithmetic(minPrec int) (ast.Expr, error) {
+	var left ast.Expr
+	var err error
+	if open, ok := p.accept(tokenLParen); ok {
+		if left, err = p.parseArithmetic(0); err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, "\")\""); err != nil {
+			return nil, err
+		}
+		if a, ok := left.(*ast.ArithmeticExpr); ok {
+			a.Offset = open.pos
+		}
+	} else if left, err = p.parsePrimary(); err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.arithmeticOperator()
+		prec := arithmeticPrecedence[op]
+		if !ok || prec < minPrec {
+			return left, nil
+		}
+		p.advance()
+		right, err := p.parseArithmetic(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		for _, operand := range []ast.Expr{left, right} {
+			if err := p.checkComparable(operand); err != nil {
+				return nil, err
+			}
+		}
+		left = &ast.ArithmeticExpr{Left: left, Op: op, Right: right, Offset: left.Pos()}
+	}
+}
+
+// arithmeticOperator returns the arithmetic operator at the current token.
+// The lexer reads "-5" in "@.a -5" as a negative number, which is split
+// into the operator and the number.
+func (p *parser) arithmeticOperator() (string, bool) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokenPlus, tokenStar, tokenSlash, tokenPercent:
+		return tok.text, true
+	case tokenNumber:
+		if tok.text[0] != '-' {
+			return "", false
+		}
+		if tok.text != "-" {
+			minus := token{kind: tokenNumber, pos: tok.pos, end: tok.pos + 1, text: "-"}
+			number := token{kind: tokenNumber, pos: tok.pos + 1, end: tok.end, text: tok.text[1:]}
+			// Copy so that tryComparison can restore the original tokens
+			tokens := make([]token, 0, len(p.tokens)+1)
+			tokens = append(tokens, p.tokens[:p.next]...)
+			tokens = append(tokens, minus, number)
+			p.tokens = append(tokens, p.tokens[p.next+1:]...)
+		}
+		return "-", true
+	}
+	return "", false
+}
+
+// parsePrimary parses a literal, a query, a function call or a parameter
+// placeholder
+func (p *parser) parsePrimary() (ast.Expr, error) {
+	tok := p.peek()
+	switch tok.kind {
+	case tokenRoot, tokenCurrent:
+		return p.parseQuery()
+	case tokenString:
+		p.advance()
+		return &ast.Literal{Value: tok.value, Offset: tok.pos}, nil
+	case tokenNumber:
+		p.advance()
+		return p.numberLiteral(tok)
+	case tokenLBrace:
+		return p.parseParameter()
+	case tokenName:
+		if p.nextAdjacent(tokenLParen) {
+			return p.parseFunctionCall()
+		}
+		p.advance()
+		switch tok.text {
+		case "true":
+			return &ast.Literal{Value: true, Offset: tok.pos}, nil
+		case "false":
+			return &ast.Literal{Value: false, Offset: tok.pos}, nil
+		case "null":
+			return &ast.Literal{Value: nil, Offset: tok.pos}, nil
+		}
+		return nil, p.errorAt(tok.pos, fmt.Sprintf("unexpected name %s, queries start with @ or $", tok))
+	case tokenEOF:
+		return nil, p.errorAt(tok.pos, "unexpected end of filter expression")
+	default:
+		return nil, p.unexpected(tok)
+	}
+}
+
+// numberLiteral converts a number token to a literal
+func (p *parser) numberLiteral(tok token) (*ast.Literal, error) {
+	if !numberPattern.MatchString(tok.text) {
+		return nil, p.errorAt(tok.pos, fmt.Sprintf("invalid number: %s", tok.text))
+	}
+	f, err := strconv.ParseFloat(tok.text, 64)
+	if err != nil {
+		return nil, p.errorAt(tok.pos, fmt.Sprintf("number out of range: %s", tok.text))
+	}
+	return &ast.Literal{Value: f, Offset: tok.pos}, nil
+}
+
+// parseParameter parses a named placeholder such as {maxPrice}
+func (p *parser) parseParameter() (ast.Expr, error) {
+	open := p.advance()
+	name := p.peek()
+	if name.kind != tokenName || !isValidParamName(name.text) {
+		return nil, p.errorAt(name.pos, fmt.Sprintf("invalid parameter name: %s", name))
+	}
+	p.advance()
+	if _, err := p.expect(tokenRBrace, "\"}\""); err != nil {
+		return nil, err
+	}
+	return &ast.Parameter{Name: name.text, Offset: open.pos}, nil
+}
+
+// parseFunctionCall parses a function expression inside a filter and
+// checks its arguments against the function's signature
+func (p *parser) parseFunctionCall() (*ast.FunctionCall, error) {
+	name := p.advance()
+	p.advance() // (
+	call := &ast.FunctionCall{Name: name.text, Offset: name.pos}
+	if _, err := p.opts.function(name.text); err != nil && !p.isIndexFunction(name.text) {
+		return nil, p.errorAt(name.pos, fmt.Sprintf("unknown function: %s", name.text))
+	}
+	if _, ok := p.accept(tokenRParen); !ok {
+		for {
+			arg, err := p.parseComparable()
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+			if _, ok := p.accept(tokenComma); !ok {
+				break
+			}
+		}
+		if _, err := p.expect(tokenRParen, "\",\" or \")\""); err != nil {
+			return nil, err
+		}
+	}
+	return call, p.checkCall(call)
+}
+
+// isIndexFunction reports whether name is index(), position() or key()
+// used in a filter, where they are evaluated without a registered function
+func (p *parser) isIndexFunction(name string) bool {
+	_, ok := indexFunctions[name]
+	return (ok || name == "key") && p.filter > 0
+}
+
+// parseTopLevelCall parses the non-standard top-level function call form,
+// whose arguments are literals and absolute queries
+func (p *parser) parseTopLevelCall() (*ast.FunctionCall, error) {
+	name := p.advance()
+	p.advance() // (
+	call := &ast.FunctionCall{Name: name.text, Offset: name.pos}
+	if _, err := p.opts.function(name.text); err != nil {
+		return nil, p.errorAt(name.pos, fmt.Sprintf("unknown function: %s", name.text))
+	}
+	if _, ok := p.accept(tokenRParen); ok {
+		return call, nil
+	}
+	for {
+		var arg ast.Expr
+		var err error
+		switch tok := p.peek(); tok.kind {
+		case tokenRoot:
+			arg, err = p.parseQuery()
+		case tokenCurrent:
+			err = p.errorAt(tok.pos, "@ is only allowed inside filter expressions")
+		default:
+			arg, err = p.parseJSONLiteral()
+		}
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+		if _, ok := p.accept(tokenComma); !ok {
+			break
+		}
+	}
+	if _, err := p.expect(tokenRParen, "\",\" or \")\""); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// parseFunctionSegment parses a function segment such as .length() or
+// .occurrences({"id": 1}), whose arguments are JSON literals or relative
+// queries such as @.price
+func (p *parser) parseFunctionSegment() (*ast.FunctionSelector, error) {
+	name := p.advance()
+	p.advance() // (
+	sel := &ast.FunctionSelector{Name: name.text, Offset: name.pos}
+	if _, err := p.opts.function(name.text); err != nil {
+		return nil, p.errorAt(name.pos, fmt.Sprintf("unknown function: %s", name.text))
+	}
+	if _, ok := p.accept(tokenRParen); ok {
+		return sel, nil
+	}
+	for {
+		var arg ast.Expr
+		var err error
+		if p.peek().kind == tokenCurrent {
+			arg, err = p.parseQuery()
+		} else {
+			arg, err = p.parseJSONLiteral()
+		}
+		if err != nil {
+			return nil, err
+		}
+		sel.Args = append(sel.Args, arg)
+		if _, ok := p.accept(tokenComma); !ok {
+			break
+		}
+	}
+	if _, err := p.expect(tokenRParen, "\",\" or \")\""); err != nil {
+		return nil, err
+	}
+	return sel, nil
+}
+
+// parseJSONLiteral parses a JSON value: a string, number, true, false,
+// null, object or array
+func (p *parser) parseJSONLiteral() (*ast.Literal, error) {
+	tok := p.peek()
+	value, err := p.parseJSONValue()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.Literal{Value: value, Offset: tok.pos}, nil
+}
+
+func (p *parser) parseJSONValue() (interface{}, error) {
+	tok := p.advance()
+	switch tok.kind {
+	case tokenString:
+		return tok.value, nil
+	case tokenNumber:
+		lit, err := p.numberLiteral(tok)
+		if err != nil {
+			return nil, err
+		}
+		return lit.Value, nil
+	case tokenName:
+		switch tok.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+	case tokenLBracket:
+		arr := []interface{}{}
+		if _, ok := p.accept(tokenRBracket); ok {
+			return arr, nil
+		}
+		for {
+			v, err := p.parseJSONValue()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+			if _, ok := p.accept(tokenComma); !ok {
+				break
+			}
+		}
+		if _, err := p.expect(tokenRBracket, "\",\" or \"]\""); err != nil {
+			return nil, err
+		}
+		return arr, nil
+	case tokenLBrace:
+		obj := map[string]interface{}{}
+		if _, ok := p.accept(tokenRBrace); ok {
+			return obj, nil
+		}
+		for {
+			key, err := p.expect(tokenString, "object key")
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokenColon, "\":\""); err != nil {
+				return nil, err
+			}
+			v, err := p.parseJSONValue()
+			if err != nil {
+				return nil, err
+			}
+			obj[key.value] = v
+			if _, ok := p.accept(tokenComma); !ok {
+				break
+			}
+		}
+		if _, err := p.expect(tokenRBrace, "\",\" or \"}\""); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}
+	return nil, p.errorAt(tok.pos, fmt.Sprintf("invalid argument: %s", tok))
+}
+
+// argType is the declared type of a function parameter or result
+// (RFC 9535, section 2.4.1)
+type argType int
+
+const (
+	anyType     argType = iota // non-standard functions, not checked
+	valueType                  // a JSON value or Nothing
+	logicalType                // true or false
+	nodesType                  // a nodelist
+)
+
+// signature declares the parameter and result types of a function
+type signature struct {
+	params []argType
+	result argType
+}
+
+// standardSignatures are the signatures of the RFC 9535 function extensions
+// and of the non-standard functions checked the same way
+var standardSignatures = map[string]signature{
+	"length":   {params: []argType{valueType}, result: valueType},
+	"count":    {params: []argType{nodesType}, result: valueType},
+	"match":    {params: []argType{valueType, valueType}, result: logicalType},
+	"search":   {params: []argType{valueType, valueType}, result: logicalType},
+	"value":    {params: []argType{nodesType}, result: valueType},
+	"exists":   {params: []argType{nodesType}, result: logicalType},
+	"index":    {result: valueType},
+	"position": {result: valueType},
+	"key":      {result: valueType},
+}
+
+// resultType returns the declared result type of a function call
+func resultType(call *ast.FunctionCall) argType {
+	return standardSignatures[call.Name].result
+}
+
+// checkCall checks the arguments of a standard function call against its
+// signature
+func (p *parser) checkCall(call *ast.FunctionCall) error {
+	sig, ok := standardSignatures[call.Name]
+	if !ok {
+		return nil
+	}
+	if len(call.Args) != len(sig.params) && len(sig.params) == 0 {
+		return p.errorAt(call.Offset, fmt.Sprintf("%s() takes no arguments", call.Name))
+	}
+	if len(call.Args) != len(sig.params) {
+		plural := "s"
+		if len(sig.params) == 1 {
+			plural = ""
+		}
+		return p.errorAt(call.Offset, fmt.Sprintf("%s() requires exactly %d argument%s", call.Name, len(sig.params), plural))
+	}
+	for i, arg := range call.Args {
+		switch sig.params[i] {
+		case nodesType:
+			if _, ok := arg.(*ast.Query); !ok {
+				return p.errorAt(arg.Pos(), fmt.Sprintf("%s() argument must be a nodelist", call.Name))
+			}
+		case valueType:
+			switch a := arg.(type) {
+			case *ast.Query:
+				if !isSingularQuery(a) {
+					return p.errorAt(arg.Pos(), fmt.Sprintf("%s() argument must be a singular query", call.Name))
+				}
+			case *ast.FunctionCall:
+				if resultType(a) == logicalType {
+					return p.errorAt(arg.Pos(), fmt.Sprintf("%s() argument must be a value, not the result of %s()", call.Name, a.Name))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkComparable checks that an operand of a comparison yields a value
+func (p *parser) checkComparable(e ast.Expr) error {
+	switch x := e.(type) {
+	case *ast.Query:
+		if !isSingularQuery(x) {
+			return p.errorAt(x.Offset, "non-singular query is not allowed in comparison")
+		}
+	case *ast.FunctionCall:
+		if resultType(x) == logicalType {
+			return p.errorAt(x.Offset, fmt.Sprintf("%s() result cannot be compared", x.Name))
+		}
+	}
+	return nil
+}
+
+// checkTest checks that an expression used without a comparison is a
+// query or a function call yielding a logical result
+func (p *parser) checkTest(e ast.Expr) error {
+	switch x := e.(type) {
+	case *ast.Query:
+		return nil
+	case *ast.FunctionCall:
+		if resultType(x) == valueType {
+			return p.errorAt(x.Offset, fmt.Sprintf("%s() result must be compared", x.Name))
+		}
+		return nil
+	default:
+		return p.errorAt(e.Pos(), fmt.Sprintf("%s is not a valid filter test", e))
+	}
+}
+
+// isSingularQuery reports whether q consists only of child segments with a
+// single name or index selector, so that it selects at most one node
+func isSingularQuery(q *ast.Query) bool {
+	for _, seg := range q.Segments {
+		if seg.Descendant || len(seg.Selectors) != 1 {
+			return false
+		}
+		switch seg.Selectors[0].(type) {
+		case *ast.NameSelector, *ast.IndexSelector:
+		default:
+			return false
+		}
+	}
+	return true
+}
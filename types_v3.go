@@ -7,6 +7,8 @@ type Node struct {
 	Location string      `json:"location"`
 	Value    interface{} `json:"value"`
 	Root     interface{} `json:"-"` // document root, not serialized
+
+	computed bool // the value was produced by a function or a string slice, not found in Root
 }
 
 // NodeList represents a list of nodes
@@ -17,6 +19,15 @@ func (nl NodeList) MarshalJSON() ([]byte, error) {
 	return json.Marshal([]Node(nl))
 }
 
+// First returns the value of the first node. found is false for an empty
+// list, distinguishing it from a first node whose value is JSON null.
+func (nl NodeList) First() (value interface{}, found bool) {
+	if len(nl) == 0 {
+		return nil, false
+	}
+	return nl[0].Value, true
+}
+
 // Nothing represents Nothing value (different from null)
 type Nothing struct{}
 